@@ -7,149 +7,146 @@ SPDX-License-Identifier: Apache-2.0
 package main
 
 import (
+	"flag"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
 
+	"github.com/hyperledger/fabric-samples/test-application/go/client"
+	"github.com/hyperledger/fabric-samples/test-application/go/identity"
 	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
 	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
 )
 
+// options are the flags common to every command
+type options struct {
+	channel           string
+	chaincode         string
+	identity          *identity.Options
+	calls             *client.Options
+	connectionProfile string
+	output            string
+}
+
 func main() {
-	log.Println("============ application-golang starts ============")
+	os.Exit(run(os.Args[1:]))
+}
 
-	err := os.Setenv("DISCOVERY_AS_LOCALHOST", "true")
-	if err != nil {
-		log.Fatalf("Error setting DISCOVERY_AS_LOCALHOST environemnt variable: %v", err)
+// run runs the command in args, and returns the exit code
+func run(args []string) int {
+	opts, args, err := parseOptions(args)
+	if err == flag.ErrHelp {
+		return 0
 	}
-
-	wallet, err := gateway.NewFileSystemWallet("wallet")
 	if err != nil {
-		log.Fatalf("Failed to create wallet: %v", err)
+		return 2
 	}
-
-	if !wallet.Exists("appUser") {
-		err = populateWallet(wallet)
-		if err != nil {
-			log.Fatalf("Failed to populate wallet contents: %v", err)
-		}
-	}
-
-	ccpPath := filepath.Join(
-		"..",
-		"..",
-		"test-network",
-		"organizations",
-		"peerOrganizations",
-		"org1.example.com",
-		"connection-org1.yaml",
-	)
-
-	gw, err := gateway.Connect(
-		gateway.WithConfig(config.FromFile(filepath.Clean(ccpPath))),
-		gateway.WithIdentity(wallet, "appUser"),
-	)
-	if err != nil {
-		log.Fatalf("Failed to connect to gateway: %v", err)
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return 2
 	}
-	defer gw.Close()
 
-	network, err := gw.GetNetwork("mychannel")
-	if err != nil {
-		log.Fatalf("Failed to get network: %v", err)
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		usage(os.Stderr)
+		return 2
 	}
-
-	contract := network.GetContract("basic")
-
-	log.Println("--> Submit Transaction: InitLedger, function creates the initial set of assets on the ledger")
-	result, err := contract.SubmitTransaction("InitLedger")
-	if err != nil {
-		log.Fatalf("Failed to Submit transaction: %v", err)
+	if cmd.run != nil {
+		return cmd.run(opts, args[1:])
 	}
-	log.Println(string(result))
 
-	log.Println("--> Evaluate Transaction: GetAllAssets, function returns all the current assets on the ledger")
-	result, err = contract.EvaluateTransaction("GetAllAssets")
+	// the arguments are checked before connecting to the gateway
+	action, err := cmd.parse(args[1:])
 	if err != nil {
-		log.Fatalf("Failed to evaluate transaction: %v", err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] %s\n", filepath.Base(os.Args[0]), cmd.usage)
+		return 2
 	}
-	log.Println(string(result))
 
-	log.Println("--> Submit Transaction: CreateAsset, creates new asset with ID, color, owner, size, and appraisedValue arguments")
-	result, err = contract.SubmitTransaction("CreateAsset", "asset13", "yellow", "5", "Tom", "1300")
+	out, err := newPrinter(os.Stdout, opts.output)
 	if err != nil {
-		log.Fatalf("Failed to Submit transaction: %v", err)
+		fmt.Fprintln(os.Stderr, err)
+		return 2
 	}
-	log.Println(string(result))
 
-	log.Println("--> Evaluate Transaction: ReadAsset, function returns an asset with a given assetID")
-	result, err = contract.EvaluateTransaction("ReadAsset", "asset13")
+	err = os.Setenv("DISCOVERY_AS_LOCALHOST", "true")
 	if err != nil {
-		log.Fatalf("Failed to evaluate transaction: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error setting DISCOVERY_AS_LOCALHOST environemnt variable: %v\n", err)
+		return 1
 	}
-	log.Println(string(result))
 
-	log.Println("--> Evaluate Transaction: AssetExists, function returns 'true' if an asset with given assetID exist")
-	result, err = contract.EvaluateTransaction("AssetExists", "asset1")
+	gw, network, err := connectNetwork(opts)
 	if err != nil {
-		log.Fatalf("Failed to evaluate transaction: %v\n", err)
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
-	log.Println(string(result))
+	defer gw.Close()
 
-	log.Println("--> Submit Transaction: TransferAsset asset1, transfer to new owner of Tom")
-	_, err = contract.SubmitTransaction("TransferAsset", "asset1", "Tom")
+	err = action(client.New(network.GetContract(opts.chaincode), *opts.calls), out)
 	if err != nil {
-		log.Fatalf("Failed to Submit transaction: %v", err)
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
 
-	log.Println("--> Evaluate Transaction: ReadAsset, function returns 'asset1' attributes")
-	result, err = contract.EvaluateTransaction("ReadAsset", "asset1")
-	if err != nil {
-		log.Fatalf("Failed to evaluate transaction: %v", err)
-	}
-	log.Println(string(result))
-	log.Println("============ application-golang ends ============")
+	return 0
 }
 
-func populateWallet(wallet *gateway.Wallet) error {
-	log.Println("============ Populating wallet ============")
-	credPath := filepath.Join(
+// parseOptions parses the flags common to every command, and returns the command and its arguments
+func parseOptions(args []string) (*options, []string, error) {
+	opts := &options{}
+
+	flags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	flags.StringVar(&opts.channel, "channel", "mychannel", "name of the channel")
+	flags.StringVar(&opts.chaincode, "chaincode", "basic", "name of the chaincode")
+	flags.StringVar(&opts.connectionProfile, "connection-profile", filepath.Join(
 		"..",
 		"..",
 		"test-network",
 		"organizations",
 		"peerOrganizations",
 		"org1.example.com",
-		"users",
-		"User1@org1.example.com",
-		"msp",
-	)
+		"connection-org1.yaml",
+	), "path of the connection profile")
+	opts.identity = identity.Flags(flags, identity.TestNetworkUser(filepath.Join("..", "..")))
+	opts.calls = client.Flags(flags, client.DefaultOptions())
+	flags.StringVar(&opts.output, "output", outputTable, "output format, table or json")
+	flags.Usage = func() {
+		usage(flags.Output())
+		fmt.Fprintln(flags.Output(), "\nflags:")
+		flags.PrintDefaults()
+	}
 
-	certPath := filepath.Join(credPath, "signcerts", "cert.pem")
-	// read the certificate pem
-	cert, err := ioutil.ReadFile(filepath.Clean(certPath))
+	err := flags.Parse(args)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	keyDir := filepath.Join(credPath, "keystore")
-	// there's a single file in this dir containing the private key
-	files, err := ioutil.ReadDir(keyDir)
+	return opts, flags.Args(), nil
+}
+
+// connectNetwork connects to the gateway with the identity of opts, and returns the network of
+// the channel
+func connectNetwork(opts *options) (*gateway.Gateway, *gateway.Network, error) {
+	withIdentity, err := opts.identity.WithIdentity()
 	if err != nil {
-		return err
-	}
-	if len(files) != 1 {
-		return fmt.Errorf("keystore folder should have contain one file")
+		return nil, nil, fmt.Errorf("failed to load identity: %v", err)
 	}
-	keyPath := filepath.Join(keyDir, files[0].Name())
-	key, err := ioutil.ReadFile(filepath.Clean(keyPath))
+
+	gw, err := gateway.Connect(
+		gateway.WithConfig(config.FromFile(filepath.Clean(opts.connectionProfile))),
+		withIdentity,
+		opts.calls.WithTimeout(),
+	)
 	if err != nil {
-		return err
+		return nil, nil, fmt.Errorf("failed to connect to gateway: %v", err)
 	}
 
-	identity := gateway.NewX509Identity("Org1MSP", string(cert), string(key))
+	network, err := gw.GetNetwork(opts.channel)
+	if err != nil {
+		gw.Close()
+		return nil, nil, fmt.Errorf("failed to get network: %v", err)
+	}
 
-	return wallet.Put("appUser", identity)
+	return gw, network, nil
 }
@@ -0,0 +1,268 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// contract is the chaincode contract called by the commands, implemented by gateway.Contract
+type contract interface {
+	EvaluateTransaction(name string, args ...string) ([]byte, error)
+	SubmitTransaction(name string, args ...string) ([]byte, error)
+}
+
+// action runs a command with its parsed arguments
+type action func(contract contract, out *printer) error
+
+type command struct {
+	usage       string
+	description string
+	// parse checks the arguments of the command, and returns the action running it
+	parse func(args []string) (action, error)
+	// run runs a command which connects to the network by itself instead of running an
+	// action, and returns the exit code
+	run func(opts *options, args []string) int
+}
+
+var commands = map[string]command{
+	"init": {
+		usage:       "init",
+		description: "creates the initial set of assets on the ledger",
+		parse:       parseInit,
+	},
+	"list": {
+		usage:       "list",
+		description: "lists all the assets on the ledger",
+		parse:       parseList,
+	},
+	"get": {
+		usage:       "get <id>",
+		description: "shows the asset with the given ID",
+		parse:       parseGet,
+	},
+	"create": {
+		usage:       "create -id <id> -color <color> -size <size> -owner <owner> -value <appraised value>",
+		description: "creates a new asset",
+		parse:       parseCreate,
+	},
+	"update": {
+		usage:       "update -id <id> -color <color> -size <size> -owner <owner> -value <appraised value>",
+		description: "replaces the attributes of an asset",
+		parse:       parseUpdate,
+	},
+	"transfer": {
+		usage:       "transfer <id> <new owner>",
+		description: "transfers an asset to a new owner",
+		parse:       parseTransfer,
+	},
+	"delete": {
+		usage:       "delete <id>",
+		description: "deletes an asset",
+		parse:       parseDelete,
+	},
+	"exists": {
+		usage:       "exists <id>",
+		description: "shows whether an asset with the given ID exists",
+		parse:       parseExists,
+	},
+	"listen": {
+		usage:       listenUsage,
+		description: "writes the chaincode events as JSON lines, resuming from a checkpoint",
+		run:         runListen,
+	},
+}
+
+// usage writes the usage of the application and of its commands to w
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: %s [flags] <command> [arguments]\n\ncommands:\n", filepath.Base(os.Args[0]))
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\t%s\n", name, commands[name].description)
+	}
+	tw.Flush()
+}
+
+func parseInit(args []string) (action, error) {
+	err := checkArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(contract contract, out *printer) error {
+		_, err := contract.SubmitTransaction("InitLedger")
+		if err != nil {
+			return fmt.Errorf("failed to submit InitLedger: %v", err)
+		}
+
+		return out.outcome(outcome{Status: "initialized"}, "the ledger is initialized")
+	}, nil
+}
+
+func parseList(args []string) (action, error) {
+	err := checkArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(contract contract, out *printer) error {
+		result, err := contract.EvaluateTransaction("GetAllAssets")
+		if err != nil {
+			return fmt.Errorf("failed to evaluate GetAllAssets: %v", err)
+		}
+
+		return out.assets(result)
+	}, nil
+}
+
+func parseGet(args []string) (action, error) {
+	err := checkArgs(args, "id")
+	if err != nil {
+		return nil, err
+	}
+	id := args[0]
+
+	return func(contract contract, out *printer) error {
+		result, err := contract.EvaluateTransaction("ReadAsset", id)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate ReadAsset: %v", err)
+		}
+
+		return out.asset(result)
+	}, nil
+}
+
+func parseCreate(args []string) (action, error) {
+	return parseAssetFlags("create", "CreateAsset", "created", args)
+}
+
+func parseUpdate(args []string) (action, error) {
+	return parseAssetFlags("update", "UpdateAsset", "updated", args)
+}
+
+// parseAssetFlags parses the attributes of an asset, and returns the action submitting them
+// to function
+func parseAssetFlags(name string, function string, status string, args []string) (action, error) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	id := flags.String("id", "", "ID of the asset")
+	color := flags.String("color", "", "color of the asset")
+	size := flags.Int("size", 0, "size of the asset")
+	owner := flags.String("owner", "", "owner of the asset")
+	value := flags.Int("value", 0, "appraised value of the asset")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	err = checkArgs(flags.Args())
+	if err != nil {
+		return nil, err
+	}
+	if *id == "" {
+		return nil, errors.New("the -id flag is required")
+	}
+	if *owner == "" {
+		return nil, errors.New("the -owner flag is required")
+	}
+
+	return func(contract contract, out *printer) error {
+		_, err := contract.SubmitTransaction(function, *id, *color, strconv.Itoa(*size), *owner, strconv.Itoa(*value))
+		if err != nil {
+			return fmt.Errorf("failed to submit %s: %v", function, err)
+		}
+
+		return out.outcome(outcome{ID: *id, Status: status}, fmt.Sprintf("the asset %s is %s", *id, status))
+	}, nil
+}
+
+func parseTransfer(args []string) (action, error) {
+	err := checkArgs(args, "id", "new owner")
+	if err != nil {
+		return nil, err
+	}
+	id, newOwner := args[0], args[1]
+
+	return func(contract contract, out *printer) error {
+		_, err := contract.SubmitTransaction("TransferAsset", id, newOwner)
+		if err != nil {
+			return fmt.Errorf("failed to submit TransferAsset: %v", err)
+		}
+
+		return out.outcome(outcome{ID: id, Status: "transferred", Owner: newOwner}, fmt.Sprintf("the asset %s is transferred to %s", id, newOwner))
+	}, nil
+}
+
+func parseDelete(args []string) (action, error) {
+	err := checkArgs(args, "id")
+	if err != nil {
+		return nil, err
+	}
+	id := args[0]
+
+	return func(contract contract, out *printer) error {
+		_, err := contract.SubmitTransaction("DeleteAsset", id)
+		if err != nil {
+			return fmt.Errorf("failed to submit DeleteAsset: %v", err)
+		}
+
+		return out.outcome(outcome{ID: id, Status: "deleted"}, fmt.Sprintf("the asset %s is deleted", id))
+	}, nil
+}
+
+func parseExists(args []string) (action, error) {
+	err := checkArgs(args, "id")
+	if err != nil {
+		return nil, err
+	}
+	id := args[0]
+
+	return func(contract contract, out *printer) error {
+		result, err := contract.EvaluateTransaction("AssetExists", id)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate AssetExists: %v", err)
+		}
+
+		exists, err := strconv.ParseBool(string(result))
+		if err != nil {
+			return fmt.Errorf("failed to parse the result of AssetExists: %v", err)
+		}
+
+		message := fmt.Sprintf("the asset %s exists", id)
+		if !exists {
+			message = fmt.Sprintf("the asset %s does not exist", id)
+		}
+		return out.outcome(outcome{ID: id, Exists: &exists}, message)
+	}, nil
+}
+
+// checkArgs returns an error if args are not the arguments named names
+func checkArgs(args []string, names ...string) error {
+	if len(args) == len(names) {
+		return nil
+	}
+
+	if len(names) == 0 {
+		return fmt.Errorf("unexpected arguments: %v", args)
+	}
+	return fmt.Errorf("expected %d arguments, <%s>, got %d", len(names), strings.Join(names, "> <"), len(args))
+}
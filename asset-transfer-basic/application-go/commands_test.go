@@ -0,0 +1,177 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fakeContract records the transactions, and returns the results set by function name
+type fakeContract struct {
+	results map[string]string
+	err     error
+	calls   []string
+}
+
+func (c *fakeContract) EvaluateTransaction(name string, args ...string) ([]byte, error) {
+	return c.call("evaluate", name, args)
+}
+
+func (c *fakeContract) SubmitTransaction(name string, args ...string) ([]byte, error) {
+	return c.call("submit", name, args)
+}
+
+func (c *fakeContract) call(kind string, name string, args []string) ([]byte, error) {
+	c.calls = append(c.calls, strings.Join(append([]string{kind, name}, args...), " "))
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return []byte(c.results[name]), nil
+}
+
+const assetsJSON = `[{"ID":"asset1","Color":"blue","Size":5,"Owner":"Tomoko","AppraisedValue":300,"Version":2},` +
+	`{"ID":"asset2","Color":"red","Size":5,"Owner":"Brad","AppraisedValue":400,"Version":1}]`
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		args     []string
+		format   string
+		results  map[string]string
+		expected string
+		calls    []string
+	}{
+		{
+			args:   []string{"list"},
+			format: outputTable,
+			results: map[string]string{
+				"GetAllAssets": assetsJSON,
+			},
+			expected: "ID      COLOR  SIZE  OWNER   APPRAISED VALUE\n" +
+				"asset1  blue   5     Tomoko  300\n" +
+				"asset2  red    5     Brad    400\n",
+			calls: []string{"evaluate GetAllAssets"},
+		},
+		{
+			args:     []string{"list"},
+			format:   outputJSON,
+			expected: "[]\n",
+			calls:    []string{"evaluate GetAllAssets"},
+		},
+		{
+			args:   []string{"get", "asset1"},
+			format: outputJSON,
+			results: map[string]string{
+				"ReadAsset": `{"ID":"asset1","Owner":"Tomoko","Version":2}`,
+			},
+			expected: "{\n  \"ID\": \"asset1\",\n  \"Owner\": \"Tomoko\",\n  \"Version\": 2\n}\n",
+			calls:    []string{"evaluate ReadAsset asset1"},
+		},
+		{
+			args:     []string{"create", "-id", "asset13", "-color", "yellow", "-size", "5", "-owner", "Tom", "-value", "1300"},
+			format:   outputTable,
+			expected: "the asset asset13 is created\n",
+			calls:    []string{"submit CreateAsset asset13 yellow 5 Tom 1300"},
+		},
+		{
+			args:     []string{"update", "-id", "asset13", "-owner", "Tom"},
+			format:   outputJSON,
+			expected: "{\n  \"ID\": \"asset13\",\n  \"status\": \"updated\"\n}\n",
+			calls:    []string{"submit UpdateAsset asset13  0 Tom 0"},
+		},
+		{
+			args:     []string{"transfer", "asset1", "Tom"},
+			format:   outputJSON,
+			expected: "{\n  \"ID\": \"asset1\",\n  \"status\": \"transferred\",\n  \"owner\": \"Tom\"\n}\n",
+			calls:    []string{"submit TransferAsset asset1 Tom"},
+		},
+		{
+			args:     []string{"delete", "asset1"},
+			format:   outputTable,
+			expected: "the asset asset1 is deleted\n",
+			calls:    []string{"submit DeleteAsset asset1"},
+		},
+		{
+			args:   []string{"exists", "asset1"},
+			format: outputJSON,
+			results: map[string]string{
+				"AssetExists": "false",
+			},
+			expected: "{\n  \"ID\": \"asset1\",\n  \"exists\": false\n}\n",
+			calls:    []string{"evaluate AssetExists asset1"},
+		},
+		{
+			args:     []string{"init"},
+			format:   outputTable,
+			expected: "the ledger is initialized\n",
+			calls:    []string{"submit InitLedger"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " ")+" "+test.format, func(t *testing.T) {
+			action, err := commands[test.args[0]].parse(test.args[1:])
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var buf bytes.Buffer
+			out, err := newPrinter(&buf, test.format)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			contract := &fakeContract{results: test.results}
+			err = action(contract, out)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if buf.String() != test.expected {
+				t.Errorf("expected output:\n%s\ngot:\n%s", test.expected, buf.String())
+			}
+			if strings.Join(contract.calls, "\n") != strings.Join(test.calls, "\n") {
+				t.Errorf("expected calls %q, got %q", test.calls, contract.calls)
+			}
+		})
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"get"}, "expected 1 arguments, <id>, got 0"},
+		{[]string{"transfer", "asset1"}, "expected 2 arguments, <id> <new owner>, got 1"},
+		{[]string{"list", "all"}, "unexpected arguments: [all]"},
+		{[]string{"create", "-owner", "Tom"}, "the -id flag is required"},
+		{[]string{"create", "-id", "asset13", "-size", "big"}, "invalid value \"big\" for flag -size: parse error"},
+	}
+
+	for _, test := range tests {
+		_, err := commands[test.args[0]].parse(test.args[1:])
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("%v: expected error %q, got %v", test.args, test.expected, err)
+		}
+	}
+
+	action, err := parseDelete([]string{"asset1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, _ := newPrinter(&bytes.Buffer{}, outputTable)
+	err = action(&fakeContract{err: errors.New("the asset asset1 does not exist")}, out)
+	if err == nil || err.Error() != "failed to submit DeleteAsset: the asset asset1 does not exist" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = newPrinter(&bytes.Buffer{}, "yaml")
+	if err == nil || err.Error() != `unknown output format "yaml", expected table or json` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-samples/test-application/go/client"
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+)
+
+// chaincodeEvent is a chaincode event of a committed transaction, written as a JSON line
+type chaincodeEvent struct {
+	BlockNumber uint64          `json:"blockNumber"`
+	TxID        string          `json:"txId"`
+	EventName   string          `json:"eventName"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+}
+
+// newChaincodeEvent returns the event set by a transaction. The payloads of the basic chaincode
+// are JSON, any other payload is written as a base64 JSON string.
+func newChaincodeEvent(blockNumber uint64, txID string, eventName string, payload []byte) *chaincodeEvent {
+	event := &chaincodeEvent{
+		BlockNumber: blockNumber,
+		TxID:        txID,
+		EventName:   eventName,
+	}
+
+	if len(payload) > 0 {
+		if json.Valid(payload) {
+			event.Payload = payload
+		} else {
+			event.Payload, _ = json.Marshal(payload)
+		}
+	}
+
+	return event
+}
+
+// eventBlock is a committed block, with the chaincode events set by its valid transactions
+type eventBlock struct {
+	number uint64
+	events []*chaincodeEvent
+}
+
+// eventSource delivers the chaincode events of the contract
+type eventSource interface {
+	// height returns the number of blocks of the channel
+	height() (uint64, error)
+	// blockEvents returns the chaincode events set by the valid transactions of a block
+	blockEvents(blockNumber uint64) ([]*chaincodeEvent, error)
+	// subscribe returns the blocks committed from now on, with their events, including the
+	// blocks without events so that a missed block can be noticed. The channel is closed when
+	// unsubscribe is called.
+	subscribe() (blocks <-chan *eventBlock, unsubscribe func(), err error)
+}
+
+// gatewaySource is the eventSource of a contract of a gateway network. The events of past
+// blocks are read from the blocks returned by the query system chaincode.
+type gatewaySource struct {
+	channel   string
+	chaincode string
+	network   *gateway.Network
+	qscc      contract
+	filter    *regexp.Regexp
+}
+
+func newGatewaySource(network *gateway.Network, chaincode string, filter *regexp.Regexp, calls client.Options) *gatewaySource {
+	return &gatewaySource{
+		channel:   network.Name(),
+		chaincode: chaincode,
+		network:   network,
+		qscc:      client.New(network.GetContract("qscc"), calls),
+		filter:    filter,
+	}
+}
+
+func (s *gatewaySource) height() (uint64, error) {
+	result, err := s.qscc.EvaluateTransaction("GetChainInfo", s.channel)
+	if err != nil {
+		return 0, fmt.Errorf("failed to evaluate GetChainInfo: %v", err)
+	}
+
+	info := &common.BlockchainInfo{}
+	err = proto.Unmarshal(result, info)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse the chain information: %v", err)
+	}
+
+	return info.Height, nil
+}
+
+func (s *gatewaySource) blockEvents(blockNumber uint64) ([]*chaincodeEvent, error) {
+	result, err := s.qscc.EvaluateTransaction("GetBlockByNumber", s.channel, strconv.FormatUint(blockNumber, 10))
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate GetBlockByNumber: %v", err)
+	}
+
+	block := &common.Block{}
+	err = proto.Unmarshal(result, block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse block %d: %v", blockNumber, err)
+	}
+
+	return s.events(block)
+}
+
+// events returns the events of block matching the filter
+func (s *gatewaySource) events(block *common.Block) ([]*chaincodeEvent, error) {
+	events, err := blockEvents(block, s.chaincode)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []*chaincodeEvent
+	for _, event := range events {
+		if s.filter.MatchString(event.EventName) {
+			filtered = append(filtered, event)
+		}
+	}
+
+	return filtered, nil
+}
+
+// subscribe listens to every block rather than to the chaincode events, so that the listener
+// notices the blocks dropped by the event service when it is not read fast enough
+func (s *gatewaySource) subscribe() (<-chan *eventBlock, func(), error) {
+	registration, blockEvents, err := s.network.RegisterBlockEvent()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to register block listener: %v", err)
+	}
+
+	blocks := make(chan *eventBlock)
+	go func() {
+		defer close(blocks)
+		for blockEvent := range blockEvents {
+			events, err := s.events(blockEvent.Block)
+			if err != nil {
+				// the block is read again from the ledger, as a missed block
+				continue
+			}
+			blocks <- &eventBlock{number: blockEvent.Block.GetHeader().GetNumber(), events: events}
+		}
+	}()
+
+	unsubscribe := func() {
+		s.network.Unregister(registration)
+		// the blocks received before the registration is removed are dropped
+		for range blocks {
+		}
+	}
+
+	return blocks, unsubscribe, nil
+}
+
+// blockEvents returns the events set by chaincode in the valid endorser transactions of block
+func blockEvents(block *common.Block, chaincode string) ([]*chaincodeEvent, error) {
+	blockNumber := block.GetHeader().GetNumber()
+
+	var validationCodes []byte
+	if metadata := block.GetMetadata().GetMetadata(); len(metadata) > int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		validationCodes = metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+
+	var events []*chaincodeEvent
+	for i, data := range block.GetData().GetData() {
+		if i < len(validationCodes) && peer.TxValidationCode(validationCodes[i]) != peer.TxValidationCode_VALID {
+			continue
+		}
+
+		txID, txEvents, err := transactionEvents(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse transaction %d of block %d: %v", i, blockNumber, err)
+		}
+		for _, event := range txEvents {
+			if event.ChaincodeId == chaincode && event.EventName != "" {
+				events = append(events, newChaincodeEvent(blockNumber, txID, event.EventName, event.Payload))
+			}
+		}
+	}
+
+	return events, nil
+}
+
+// transactionEvents returns the ID and the chaincode events of the transaction envelope data,
+// other transactions than endorser transactions have no event
+func transactionEvents(data []byte) (string, []*peer.ChaincodeEvent, error) {
+	envelope := &common.Envelope{}
+	err := proto.Unmarshal(data, envelope)
+	if err != nil {
+		return "", nil, err
+	}
+	payload := &common.Payload{}
+	err = proto.Unmarshal(envelope.Payload, payload)
+	if err != nil {
+		return "", nil, err
+	}
+	channelHeader := &common.ChannelHeader{}
+	err = proto.Unmarshal(payload.GetHeader().GetChannelHeader(), channelHeader)
+	if err != nil {
+		return "", nil, err
+	}
+	if channelHeader.Type != int32(common.HeaderType_ENDORSER_TRANSACTION) {
+		return channelHeader.TxId, nil, nil
+	}
+
+	transaction := &peer.Transaction{}
+	err = proto.Unmarshal(payload.Data, transaction)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var events []*peer.ChaincodeEvent
+	for _, action := range transaction.Actions {
+		actionPayload := &peer.ChaincodeActionPayload{}
+		err = proto.Unmarshal(action.Payload, actionPayload)
+		if err != nil {
+			return "", nil, err
+		}
+		responsePayload := &peer.ProposalResponsePayload{}
+		err = proto.Unmarshal(actionPayload.GetAction().GetProposalResponsePayload(), responsePayload)
+		if err != nil {
+			return "", nil, err
+		}
+		chaincodeAction := &peer.ChaincodeAction{}
+		err = proto.Unmarshal(responsePayload.Extension, chaincodeAction)
+		if err != nil {
+			return "", nil, err
+		}
+		event := &peer.ChaincodeEvent{}
+		err = proto.Unmarshal(chaincodeAction.Events, event)
+		if err != nil {
+			return "", nil, err
+		}
+		events = append(events, event)
+	}
+
+	return channelHeader.TxId, events, nil
+}
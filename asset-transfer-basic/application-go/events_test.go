@@ -0,0 +1,98 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+func TestBlockEvents(t *testing.T) {
+	block := &common.Block{
+		Header: &common.BlockHeader{Number: 12},
+		Data: &common.BlockData{
+			Data: [][]byte{
+				endorserTransaction(t, "tx1", &peer.ChaincodeEvent{ChaincodeId: "basic", EventName: "AssetCreated", Payload: []byte(`{"ID":"asset1"}`)}),
+				endorserTransaction(t, "tx2", &peer.ChaincodeEvent{ChaincodeId: "basic", EventName: "AssetDeleted"}),
+				endorserTransaction(t, "tx3", &peer.ChaincodeEvent{ChaincodeId: "other", EventName: "AssetCreated"}),
+				endorserTransaction(t, "tx4", &peer.ChaincodeEvent{}),
+				envelope(t, "config", common.HeaderType_CONFIG, nil),
+			},
+		},
+		Metadata: &common.BlockMetadata{
+			Metadata: [][]byte{
+				common.BlockMetadataIndex_TRANSACTIONS_FILTER: {
+					byte(peer.TxValidationCode_VALID),
+					byte(peer.TxValidationCode_MVCC_READ_CONFLICT),
+					byte(peer.TxValidationCode_VALID),
+					byte(peer.TxValidationCode_VALID),
+					byte(peer.TxValidationCode_VALID),
+				},
+			},
+		},
+	}
+
+	events, err := blockEvents(block, "basic")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []*chaincodeEvent{
+		{BlockNumber: 12, TxID: "tx1", EventName: "AssetCreated", Payload: []byte(`{"ID":"asset1"}`)},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected events %+v, got %+v", expected, events)
+	}
+
+	block.Data.Data = append(block.Data.Data, []byte("not a transaction"))
+	_, err = blockEvents(block, "basic")
+	if err == nil {
+		t.Error("expected an error parsing an invalid transaction")
+	}
+}
+
+func endorserTransaction(t *testing.T, txID string, event *peer.ChaincodeEvent) []byte {
+	transaction := &peer.Transaction{
+		Actions: []*peer.TransactionAction{
+			{
+				Payload: marshal(t, &peer.ChaincodeActionPayload{
+					Action: &peer.ChaincodeEndorsedAction{
+						ProposalResponsePayload: marshal(t, &peer.ProposalResponsePayload{
+							Extension: marshal(t, &peer.ChaincodeAction{
+								Events: marshal(t, event),
+							}),
+						}),
+					},
+				}),
+			},
+		},
+	}
+
+	return envelope(t, txID, common.HeaderType_ENDORSER_TRANSACTION, marshal(t, transaction))
+}
+
+func envelope(t *testing.T, txID string, headerType common.HeaderType, data []byte) []byte {
+	return marshal(t, &common.Envelope{
+		Payload: marshal(t, &common.Payload{
+			Header: &common.Header{
+				ChannelHeader: marshal(t, &common.ChannelHeader{Type: int32(headerType), TxId: txID}),
+			},
+			Data: data,
+		}),
+	})
+}
+
+func marshal(t *testing.T, message proto.Message) []byte {
+	data, err := proto.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
@@ -3,8 +3,13 @@ module asset-transfer-basic
 go 1.14
 
 require (
+	github.com/golang/protobuf v1.3.3
+	github.com/hyperledger/fabric-protos-go v0.0.0-20200707132912-fee30f3ccd23
+	github.com/hyperledger/fabric-samples/test-application/go v0.0.0-00010101000000-000000000000
 	github.com/hyperledger/fabric-sdk-go v1.0.0-rc1
 	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9 // indirect
 	golang.org/x/tools v0.1.7 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 )
+
+replace github.com/hyperledger/fabric-samples/test-application/go => ../../test-application/go
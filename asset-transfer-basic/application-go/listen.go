@@ -0,0 +1,336 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"syscall"
+	"time"
+)
+
+const listenUsage = "listen [-checkpoint <file>] [-events <regexp>] [-forward <url>]"
+
+// listenOptions are the arguments of the listen command
+type listenOptions struct {
+	checkpointFile string
+	events         *regexp.Regexp
+	forward        string
+}
+
+func parseListen(args []string) (*listenOptions, error) {
+	flags := flag.NewFlagSet("listen", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	checkpointFile := flags.String("checkpoint", "checkpoint.json", "file recording the last processed block and transactions")
+	events := flags.String("events", ".*", "regular expression matching the names of the events")
+	forward := flags.String("forward", "", "URL the events are posted to, instead of being printed")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	err = checkArgs(flags.Args())
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := regexp.Compile(*events)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q for flag -events: %v", *events, err)
+	}
+
+	return &listenOptions{
+		checkpointFile: *checkpointFile,
+		events:         filter,
+		forward:        *forward,
+	}, nil
+}
+
+// runListen writes the chaincode events of the contract until the application is interrupted,
+// and returns the exit code
+func runListen(opts *options, args []string) int {
+	listenOpts, err := parseListen(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] %s\n", filepath.Base(os.Args[0]), listenUsage)
+		return 2
+	}
+
+	cp, err := loadCheckpoint(listenOpts.checkpointFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var sink eventSink = &jsonLinesSink{w: os.Stdout}
+	if listenOpts.forward != "" {
+		sink = &httpSink{url: listenOpts.forward, client: &http.Client{Timeout: 30 * time.Second}}
+	}
+
+	err = os.Setenv("DISCOVERY_AS_LOCALHOST", "true")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting DISCOVERY_AS_LOCALHOST environemnt variable: %v\n", err)
+		return 1
+	}
+
+	gw, network, err := connectNetwork(opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer gw.Close()
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	l := &listener{
+		source:         newGatewaySource(network, opts.chaincode, listenOpts.events, *opts.calls),
+		sink:           sink,
+		checkpointFile: listenOpts.checkpointFile,
+		checkpoint:     cp,
+	}
+	err = l.run(stop)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// checkpoint records the events written by the listener. The events of BlockNumber are written
+// for the transactions of TransactionIDs, the events of the previous blocks are all written.
+type checkpoint struct {
+	BlockNumber    uint64   `json:"blockNumber"`
+	TransactionIDs []string `json:"transactionIds"`
+}
+
+// loadCheckpoint returns the checkpoint saved in file, or nil if there is no such file
+func loadCheckpoint(file string) (*checkpoint, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(file))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+
+	cp := &checkpoint{}
+	err = json.Unmarshal(data, cp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %v", file, err)
+	}
+
+	return cp, nil
+}
+
+// save replaces file with the checkpoint, a checkpoint is never partially written
+func (cp *checkpoint) save(file string) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmp := file + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+	err = os.Rename(tmp, file)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+
+	return nil
+}
+
+// processed returns true if the checkpoint records event as written
+func (cp *checkpoint) processed(event *chaincodeEvent) bool {
+	if event.BlockNumber != cp.BlockNumber {
+		return event.BlockNumber < cp.BlockNumber
+	}
+
+	for _, txID := range cp.TransactionIDs {
+		if txID == event.TxID {
+			return true
+		}
+	}
+	return false
+}
+
+// eventSink is where the listener writes the events
+type eventSink interface {
+	write(event *chaincodeEvent) error
+}
+
+// jsonLinesSink writes each event on a line of JSON
+type jsonLinesSink struct {
+	w io.Writer
+}
+
+func (s *jsonLinesSink) write(event *chaincodeEvent) error {
+	return json.NewEncoder(s.w).Encode(event)
+}
+
+// httpSink posts each event as JSON to a URL
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *httpSink) write(event *chaincodeEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	response, err := s.client.Post(s.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to forward event of transaction %s: %v", event.TxID, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("failed to forward event of transaction %s: %s", event.TxID, response.Status)
+	}
+
+	return nil
+}
+
+// listener writes the events of a source to a sink, and records them in a checkpoint file so
+// that a restarted listener resumes after the last written event
+type listener struct {
+	source         eventSource
+	sink           eventSink
+	checkpointFile string
+	checkpoint     *checkpoint
+
+	// next is the number of the next block whose events are written
+	next uint64
+}
+
+// run writes the events until stop is closed. The events of the blocks committed since the
+// checkpoint are replayed before the events of the new blocks.
+func (l *listener) run(stop <-chan struct{}) error {
+	// the listener subscribes before replaying, so that no block is committed unnoticed between
+	// the replay and the subscription
+	blocks, unsubscribe, err := l.source.subscribe()
+	if err != nil {
+		return err
+	}
+	defer unsubscribe()
+
+	height, err := l.source.height()
+	if err != nil {
+		return err
+	}
+	// without a checkpoint, only the events of the new blocks are written
+	l.next = height
+	if l.checkpoint != nil {
+		l.next = l.checkpoint.BlockNumber
+	}
+	err = l.catchUp(height, stop)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case block, ok := <-blocks:
+			if !ok {
+				return errors.New("the event service closed the subscription")
+			}
+			if block.number < l.next {
+				continue
+			}
+			// the blocks missed by the subscription, which the event service drops when they
+			// are not read fast enough, are read from the ledger
+			err = l.catchUp(block.number, stop)
+			if err != nil {
+				return err
+			}
+			if l.next != block.number {
+				// the catch up was stopped
+				return nil
+			}
+			err = l.handleBlock(block.events)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// catchUp writes the events of the blocks from the next block to height, excluded, read from
+// the ledger
+func (l *listener) catchUp(height uint64, stop <-chan struct{}) error {
+	for l.next < height {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+
+		events, err := l.source.blockEvents(l.next)
+		if err != nil {
+			return err
+		}
+		err = l.handleBlock(events)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handleBlock writes the events of the next block, and moves to the block after it
+func (l *listener) handleBlock(events []*chaincodeEvent) error {
+	for _, event := range events {
+		err := l.handle(event)
+		if err != nil {
+			return err
+		}
+	}
+	l.next++
+
+	return nil
+}
+
+// handle writes event unless it is recorded in the checkpoint, and records it
+func (l *listener) handle(event *chaincodeEvent) error {
+	if l.checkpoint != nil && l.checkpoint.processed(event) {
+		return nil
+	}
+
+	err := l.sink.write(event)
+	if err != nil {
+		return err
+	}
+
+	if l.checkpoint == nil || l.checkpoint.BlockNumber != event.BlockNumber {
+		l.checkpoint = &checkpoint{BlockNumber: event.BlockNumber}
+	}
+	l.checkpoint.TransactionIDs = append(l.checkpoint.TransactionIDs, event.TxID)
+
+	return l.checkpoint.save(l.checkpointFile)
+}
@@ -0,0 +1,252 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeSource returns the events of blocks, and delivers the blocks sent to live
+type fakeSource struct {
+	chainHeight uint64
+	blocks      map[uint64][]*chaincodeEvent
+	live        chan *eventBlock
+}
+
+func (s *fakeSource) height() (uint64, error) {
+	return s.chainHeight, nil
+}
+
+func (s *fakeSource) blockEvents(blockNumber uint64) ([]*chaincodeEvent, error) {
+	return s.blocks[blockNumber], nil
+}
+
+func (s *fakeSource) subscribe() (<-chan *eventBlock, func(), error) {
+	return s.live, func() {}, nil
+}
+
+// recordingSink records the transaction IDs of the events, and stops the listener after the
+// given number of events
+type recordingSink struct {
+	txIDs []string
+	count int
+	stop  chan struct{}
+}
+
+func (s *recordingSink) write(event *chaincodeEvent) error {
+	s.txIDs = append(s.txIDs, event.TxID)
+	if len(s.txIDs) == s.count {
+		close(s.stop)
+	}
+
+	return nil
+}
+
+func event(blockNumber uint64, txID string) *chaincodeEvent {
+	return newChaincodeEvent(blockNumber, txID, "AssetCreated", []byte(`{"ID":"`+txID+`"}`))
+}
+
+// block returns the block blockNumber delivered by the subscription, with an event for each of txIDs
+func block(blockNumber uint64, txIDs ...string) *eventBlock {
+	b := &eventBlock{number: blockNumber}
+	for _, txID := range txIDs {
+		b.events = append(b.events, event(blockNumber, txID))
+	}
+
+	return b
+}
+
+func TestListenerResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	checkpointFile := filepath.Join(dir, "checkpoint.json")
+
+	source := &fakeSource{
+		chainHeight: 7,
+		blocks: map[uint64][]*chaincodeEvent{
+			4: {event(4, "tx1")},
+			5: {event(5, "tx2"), event(5, "tx3")},
+			6: {event(6, "tx4")},
+		},
+		live: make(chan *eventBlock, 2),
+	}
+	// block 6 is delivered by the subscription as well as by the replay
+	source.live <- block(6, "tx4")
+	source.live <- block(7, "tx5", "tx6")
+
+	sink := &recordingSink{count: 4, stop: make(chan struct{})}
+	l := &listener{
+		source:         source,
+		sink:           sink,
+		checkpointFile: checkpointFile,
+		checkpoint:     &checkpoint{BlockNumber: 5, TransactionIDs: []string{"tx2"}},
+	}
+	err = l.run(sink.stop)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"tx3", "tx4", "tx5", "tx6"}
+	if !reflect.DeepEqual(sink.txIDs, expected) {
+		t.Errorf("expected events %v, got %v", expected, sink.txIDs)
+	}
+
+	cp, err := loadCheckpoint(checkpointFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(cp, &checkpoint{BlockNumber: 7, TransactionIDs: []string{"tx5", "tx6"}}) {
+		t.Errorf("unexpected checkpoint %+v", cp)
+	}
+}
+
+func TestListenerWithoutCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := &fakeSource{
+		chainHeight: 5,
+		blocks: map[uint64][]*chaincodeEvent{
+			4: {event(4, "tx1")},
+		},
+		live: make(chan *eventBlock, 1),
+	}
+	source.live <- block(5, "tx2")
+
+	sink := &recordingSink{count: 1, stop: make(chan struct{})}
+	l := &listener{source: source, sink: sink, checkpointFile: filepath.Join(dir, "checkpoint.json")}
+	err = l.run(sink.stop)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(sink.txIDs, []string{"tx2"}) {
+		t.Errorf("expected only the new events, got %v", sink.txIDs)
+	}
+
+	close(source.live)
+	l.checkpoint = nil
+	err = l.run(make(chan struct{}))
+	if err == nil || err.Error() != "the event service closed the subscription" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// The blocks skipped by the subscription are read from the ledger before the next block
+func TestListenerMissedBlocks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	checkpointFile := filepath.Join(dir, "checkpoint.json")
+
+	source := &fakeSource{
+		chainHeight: 5,
+		blocks: map[uint64][]*chaincodeEvent{
+			5: {event(5, "tx1")},
+			6: {event(6, "tx2"), event(6, "tx3")},
+			7: {},
+			8: {event(8, "tx4")},
+		},
+		live: make(chan *eventBlock, 3),
+	}
+	source.live <- block(5, "tx1")
+	source.live <- block(8, "tx4")
+	source.live <- block(9, "tx5")
+
+	sink := &recordingSink{count: 5, stop: make(chan struct{})}
+	l := &listener{source: source, sink: sink, checkpointFile: checkpointFile}
+	err = l.run(sink.stop)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"tx1", "tx2", "tx3", "tx4", "tx5"}
+	if !reflect.DeepEqual(sink.txIDs, expected) {
+		t.Errorf("expected events %v, got %v", expected, sink.txIDs)
+	}
+
+	cp, err := loadCheckpoint(checkpointFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(cp, &checkpoint{BlockNumber: 9, TransactionIDs: []string{"tx5"}}) {
+		t.Errorf("unexpected checkpoint %+v", cp)
+	}
+}
+
+func TestSinks(t *testing.T) {
+	var buf bytes.Buffer
+	err := (&jsonLinesSink{w: &buf}).write(event(4, "tx1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `{"blockNumber":4,"txId":"tx1","eventName":"AssetCreated","payload":{"ID":"tx1"}}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	err = (&jsonLinesSink{w: &buf}).write(newChaincodeEvent(4, "tx1", "Raw", []byte{0xff}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"payload":"/w=="`) {
+		t.Errorf("expected a base64 payload, got %q", buf.String())
+	}
+
+	var posted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		posted = append(posted, r.Header.Get("Content-Type")+" "+string(body))
+		if strings.Contains(string(body), "tx2") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	sink := &httpSink{url: server.URL, client: server.Client()}
+	err = sink.write(event(4, "tx1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = sink.write(event(4, "tx2"))
+	if err == nil || err.Error() != "failed to forward event of transaction tx2: 503 Service Unavailable" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(posted) != 2 || posted[0] != "application/json "+strings.TrimSpace(expected) {
+		t.Errorf("unexpected requests %q", posted)
+	}
+}
+
+func TestParseListen(t *testing.T) {
+	opts, err := parseListen([]string{"-checkpoint", "events.json", "-events", "Asset(Created|Deleted)"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.checkpointFile != "events.json" || !opts.events.MatchString("AssetDeleted") || opts.events.MatchString("AssetUpdated") {
+		t.Errorf("unexpected options %+v", opts)
+	}
+
+	_, err = parseListen([]string{"-events", "("})
+	if err == nil || !strings.HasPrefix(err.Error(), `invalid value "(" for flag -events`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// Output formats of the commands
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// asset holds the attributes of an asset shown in a table, the JSON output keeps every
+// attribute returned by the chaincode
+type asset struct {
+	ID             string `json:"ID"`
+	Color          string `json:"Color"`
+	Size           int    `json:"Size"`
+	Owner          string `json:"Owner"`
+	AppraisedValue int    `json:"AppraisedValue"`
+}
+
+// outcome is the JSON output of the commands which do not return assets
+type outcome struct {
+	ID     string `json:"ID,omitempty"`
+	Status string `json:"status,omitempty"`
+	Owner  string `json:"owner,omitempty"`
+	Exists *bool  `json:"exists,omitempty"`
+}
+
+// printer writes the results of the commands in the output format
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	if format != outputTable && format != outputJSON {
+		return nil, fmt.Errorf("unknown output format %q, expected %s or %s", format, outputTable, outputJSON)
+	}
+
+	return &printer{w: w, format: format}, nil
+}
+
+// assets writes the JSON array of assets returned by the chaincode
+func (p *printer) assets(result []byte) error {
+	if p.format == outputJSON {
+		return p.json(result)
+	}
+
+	var assets []asset
+	// the chaincode returns an empty result when there is no asset
+	if len(bytes.TrimSpace(result)) > 0 {
+		err := json.Unmarshal(result, &assets)
+		if err != nil {
+			return fmt.Errorf("failed to parse assets: %v", err)
+		}
+	}
+
+	return p.table(assets)
+}
+
+// asset writes the JSON asset returned by the chaincode
+func (p *printer) asset(result []byte) error {
+	if p.format == outputJSON {
+		return p.json(result)
+	}
+
+	var a asset
+	err := json.Unmarshal(result, &a)
+	if err != nil {
+		return fmt.Errorf("failed to parse asset: %v", err)
+	}
+
+	return p.table([]asset{a})
+}
+
+// outcome writes o as JSON, or message in a table output
+func (p *printer) outcome(o outcome, message string) error {
+	if p.format == outputTable {
+		_, err := fmt.Fprintln(p.w, message)
+		return err
+	}
+
+	result, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	return p.json(result)
+}
+
+func (p *printer) json(result []byte) error {
+	if len(bytes.TrimSpace(result)) == 0 {
+		result = []byte("[]")
+	}
+
+	var indented bytes.Buffer
+	err := json.Indent(&indented, result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to parse result: %v", err)
+	}
+	indented.WriteByte('\n')
+
+	_, err = indented.WriteTo(p.w)
+	return err
+}
+
+func (p *printer) table(assets []asset) error {
+	tw := tabwriter.NewWriter(p.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCOLOR\tSIZE\tOWNER\tAPPRAISED VALUE")
+	for _, a := range assets {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%d\n", a.ID, a.Color, a.Size, a.Owner, a.AppraisedValue)
+	}
+
+	return tw.Flush()
+}
@@ -5,21 +5,29 @@ SPDX-License-Identifier: Apache-2.0
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
+	"net"
+	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/chaincode/serverconfig"
+	"github.com/hyperledger/fabric-samples/chaincode/txlog"
 )
 
-type serverConfig struct {
-	CCID    string
-	Address string
-}
+// Keys of the configuration specific to this chaincode server, see chaincode.env
+const (
+	keyMetricsAddress    = "CHAINCODE_METRICS_ADDRESS"
+	keyTLSReloadInterval = "CHAINCODE_TLS_RELOAD_INTERVAL"
+	keyShutdownTimeout   = "CHAINCODE_SHUTDOWN_TIMEOUT"
+)
 
 // SmartContract provides functions for managing an asset
 type SmartContract struct {
@@ -41,6 +49,54 @@ type QueryResult struct {
 	Record *Asset
 }
 
+// AssetEventSchemaVersion is the version of the AssetEvent payload.
+// See EVENTS.md at the root of asset-transfer-basic for the documented schema.
+const AssetEventSchemaVersion = 1
+
+// Names of the chaincode events emitted on asset lifecycle changes
+const (
+	AssetCreatedEvent     = "AssetCreated"
+	AssetUpdatedEvent     = "AssetUpdated"
+	AssetTransferredEvent = "AssetTransferred"
+	AssetDeletedEvent     = "AssetDeleted"
+)
+
+// AssetEvent is the payload of the event emitted when an asset changes.
+// Before is omitted for AssetCreated and After is omitted for AssetDeleted.
+type AssetEvent struct {
+	After         *EventAsset `json:"after,omitempty"`
+	Before        *EventAsset `json:"before,omitempty"`
+	ID            string      `json:"ID"`
+	SchemaVersion int         `json:"schemaVersion"`
+	TxID          string      `json:"txId"`
+	Type          string      `json:"type"`
+}
+
+// EventAsset is an asset in the payload of an event. It has the field names of the assets of
+// chaincode-go, so that both chaincodes emit events with the same schema.
+type EventAsset struct {
+	AppraisedValue int    `json:"AppraisedValue"`
+	Color          string `json:"Color"`
+	ID             string `json:"ID"`
+	Owner          string `json:"Owner"`
+	Size           int    `json:"Size"`
+}
+
+// newEventAsset returns the event encoding of an asset, nil if the asset is nil
+func newEventAsset(asset *Asset) *EventAsset {
+	if asset == nil {
+		return nil
+	}
+
+	return &EventAsset{
+		AppraisedValue: asset.AppraisedValue,
+		Color:          asset.Color,
+		ID:             asset.ID,
+		Owner:          asset.Owner,
+		Size:           asset.Size,
+	}
+}
+
 // InitLedger adds a base set of cars to the ledger
 func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
 	assets := []Asset{
@@ -69,13 +125,6 @@ func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface)
 
 // CreateAsset issues a new asset to the world state with given details.
 func (s *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface, id, color string, size int, owner string, appraisedValue int) error {
-	exists, err := s.AssetExists(ctx, id)
-	if err != nil {
-		return err
-	}
-	if exists {
-		return fmt.Errorf("the asset %s already exists", id)
-	}
 	asset := Asset{
 		ID:             id,
 		Color:          color,
@@ -83,23 +132,45 @@ func (s *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface,
 		Owner:          owner,
 		AppraisedValue: appraisedValue,
 	}
+	err := validateNewAsset(&asset)
+	if err != nil {
+		return err
+	}
+
+	exists, err := s.AssetExists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return alreadyExistsError(id)
+	}
 
 	assetJSON, err := json.Marshal(asset)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, assetJSON)
+	err = ctx.GetStub().PutState(id, assetJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitAssetEvent(ctx, AssetCreatedEvent, id, nil, &asset)
 }
 
 // ReadAsset returns the asset stored in the world state with given id.
 func (s *SmartContract) ReadAsset(ctx contractapi.TransactionContextInterface, id string) (*Asset, error) {
+	err := validateAssetID(id)
+	if err != nil {
+		return nil, err
+	}
+
 	assetJSON, err := ctx.GetStub().GetState(id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read from world state. %s", err.Error())
 	}
 	if assetJSON == nil {
-		return nil, fmt.Errorf("the asset %s does not exist", id)
+		return nil, notFoundError(id)
 	}
 
 	var asset Asset
@@ -113,14 +184,6 @@ func (s *SmartContract) ReadAsset(ctx contractapi.TransactionContextInterface, i
 
 // UpdateAsset updates an existing asset in the world state with provided parameters.
 func (s *SmartContract) UpdateAsset(ctx contractapi.TransactionContextInterface, id, color string, size int, owner string, appraisedValue int) error {
-	exists, err := s.AssetExists(ctx, id)
-	if err != nil {
-		return err
-	}
-	if !exists {
-		return fmt.Errorf("the asset %s does not exist", id)
-	}
-
 	// overwritting original asset with new asset
 	asset := Asset{
 		ID:             id,
@@ -129,30 +192,51 @@ func (s *SmartContract) UpdateAsset(ctx contractapi.TransactionContextInterface,
 		Owner:          owner,
 		AppraisedValue: appraisedValue,
 	}
+	err := validateAsset(&asset)
+	if err != nil {
+		return err
+	}
+
+	existing, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
 
 	assetJSON, err := json.Marshal(asset)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, assetJSON)
+	err = ctx.GetStub().PutState(id, assetJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitAssetEvent(ctx, AssetUpdatedEvent, id, existing, &asset)
 }
 
 // DeleteAsset deletes an given asset from the world state.
 func (s *SmartContract) DeleteAsset(ctx contractapi.TransactionContextInterface, id string) error {
-	exists, err := s.AssetExists(ctx, id)
+	asset, err := s.ReadAsset(ctx, id)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return fmt.Errorf("the asset %s does not exist", id)
+
+	err = ctx.GetStub().DelState(id)
+	if err != nil {
+		return err
 	}
 
-	return ctx.GetStub().DelState(id)
+	return emitAssetEvent(ctx, AssetDeletedEvent, id, asset, nil)
 }
 
 // AssetExists returns true when asset with given ID exists in world state
 func (s *SmartContract) AssetExists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	err := validateAssetID(id)
+	if err != nil {
+		return false, err
+	}
+
 	assetJSON, err := ctx.GetStub().GetState(id)
 	if err != nil {
 		return false, fmt.Errorf("failed to read from world state. %s", err.Error())
@@ -163,18 +247,51 @@ func (s *SmartContract) AssetExists(ctx contractapi.TransactionContextInterface,
 
 // TransferAsset updates the owner field of asset with given id in world state.
 func (s *SmartContract) TransferAsset(ctx contractapi.TransactionContextInterface, id string, newOwner string) error {
+	if newOwner == "" {
+		return newOwnerEmptyError(id)
+	}
+
 	asset, err := s.ReadAsset(ctx, id)
 	if err != nil {
 		return err
 	}
 
+	before := *asset
 	asset.Owner = newOwner
 	assetJSON, err := json.Marshal(asset)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, assetJSON)
+	err = ctx.GetStub().PutState(id, assetJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitAssetEvent(ctx, AssetTransferredEvent, id, &before, asset)
+}
+
+// emitAssetEvent sets the chaincode event of the transaction to the change of an asset
+func emitAssetEvent(ctx contractapi.TransactionContextInterface, eventType string, id string, before *Asset, after *Asset) error {
+	event := AssetEvent{
+		After:         newEventAsset(after),
+		Before:        newEventAsset(before),
+		ID:            id,
+		SchemaVersion: AssetEventSchemaVersion,
+		TxID:          ctx.GetStub().GetTxID(),
+		Type:          eventType,
+	}
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent(eventType, eventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
 }
 
 // GetAllAssets returns all assets found in world state
@@ -210,82 +327,108 @@ func (s *SmartContract) GetAllAssets(ctx contractapi.TransactionContextInterface
 }
 
 func main() {
-	// See chaincode.env.example
-	config := serverConfig{
-		CCID:    os.Getenv("CHAINCODE_ID"),
-		Address: os.Getenv("CHAINCODE_SERVER_ADDRESS"),
+	// See chaincode.env
+	config, err := serverconfig.Load(os.Args[1:], keyMetricsAddress, keyTLSReloadInterval, keyShutdownTimeout)
+	if err != nil {
+		log.Panicf("error loading asset-transfer-basic chaincode configuration: %s", err)
+	}
+	reloadInterval, err := config.Duration(keyTLSReloadInterval, 10*time.Second)
+	if err != nil {
+		log.Panicf("error loading asset-transfer-basic chaincode configuration: %s", err)
+	}
+	shutdownTimeout, err := config.Duration(keyShutdownTimeout, 30*time.Second)
+	if err != nil {
+		log.Panicf("error loading asset-transfer-basic chaincode configuration: %s", err)
 	}
 
-	chaincode, err := contractapi.NewChaincode(&SmartContract{})
+	// Every transaction is logged as a JSON line, and panics are returned as errors
+	logger := txlog.New(os.Stdout)
+	contract := &SmartContract{}
+	logger.Hook(&contract.Contract)
+
+	chaincode, err := contractapi.NewChaincode(contract)
 
 	if err != nil {
 		log.Panicf("error create asset-transfer-basic chaincode: %s", err)
 	}
 
-	server := &shim.ChaincodeServer{
-		CCID:    config.CCID,
-		Address: config.Address,
-		CC:      chaincode,
-		TLSProps: getTLSProperties(),
+	metrics := newChaincodeMetrics()
+	ready := &readiness{}
+	monitoring := startMonitoring(config.String(keyMetricsAddress, ""), metrics, ready)
+
+	stopReload := make(chan struct{})
+	var tlsConfig *tls.Config
+	if !config.TLSDisabled {
+		reloader, err := newTLSReloader(tlsFiles{
+			Key:          config.TLSKey,
+			Cert:         config.TLSCert,
+			ClientCACert: config.ClientCACert,
+		})
+		if err != nil {
+			log.Panicf("error loading TLS files: %s", err)
+		}
+		go reloader.watch(reloadInterval, stopReload)
+		tlsConfig = reloader.serverConfig()
 	}
 
-	if err := server.Start(); err != nil {
+	listener, err := net.Listen("tcp", config.Address)
+	if err != nil {
 		log.Panicf("error starting asset-transfer-basic chaincode: %s", err)
 	}
-}
+	server := newChaincodeGRPCServer(config.CCID, &instrumentedChaincode{cc: logger.Chaincode(chaincode), metrics: metrics}, tlsConfig)
 
-func getTLSProperties() shim.TLSProperties {
-	// Check if chaincode is TLS enabled
-	tlsDisabledStr := getEnvOrDefault("CHAINCODE_TLS_DISABLED", "true")
-	key := getEnvOrDefault("CHAINCODE_TLS_KEY", "")
-	cert := getEnvOrDefault("CHAINCODE_TLS_CERT", "")
-	clientCACert := getEnvOrDefault("CHAINCODE_CLIENT_CA_CERT", "")
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
 
-	// convert tlsDisabledStr to boolean
-	tlsDisabled := getBoolOrDefault(tlsDisabledStr, false)
-	var keyBytes, certBytes, clientCACertBytes []byte
-	var err error
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+	ready.set(true)
 
-	if !tlsDisabled {
-		keyBytes, err = ioutil.ReadFile(key)
-		if err != nil {
-			log.Panicf("error while reading the crypto file: %s", err)
-		}
-		certBytes, err = ioutil.ReadFile(cert)
-		if err != nil {
-			log.Panicf("error while reading the crypto file: %s", err)
-		}
+	select {
+	case err := <-served:
+		log.Panicf("error starting asset-transfer-basic chaincode: %s", err)
+	case sig := <-signals:
+		log.Printf("received %s, draining asset-transfer-basic chaincode", sig)
 	}
-	// Did not request for the peer cert verification
-	if clientCACert != "" {
-		clientCACertBytes, err = ioutil.ReadFile(clientCACert)
-		if err != nil {
-			log.Panicf("error while reading the crypto file: %s", err)
-		}
+
+	// report not ready first, so that no new peer connection is routed here
+	ready.set(false)
+	if !drain(server, metrics, shutdownTimeout) {
+		log.Printf("shutdown timeout expired with %d invocations in flight", metrics.inFlight())
 	}
+	close(stopReload)
 
-	return shim.TLSProperties{
-		Disabled: tlsDisabled,
-		Key: keyBytes,
-		Cert: certBytes,
-		ClientCACerts: clientCACertBytes,
+	if monitoring != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := monitoring.Shutdown(ctx); err != nil {
+			log.Printf("error stopping monitoring listener: %s", err)
+		}
 	}
+	log.Printf("asset-transfer-basic chaincode stopped")
 }
 
-func getEnvOrDefault(env, defaultVal string) string {
-	value, ok := os.LookupEnv(env)
-	if !ok {
-		value = defaultVal
+// startMonitoring serves the health, readiness and metrics endpoints on address, unless it is empty.
+// It returns the monitoring server, or nil if address is empty.
+func startMonitoring(address string, metrics *chaincodeMetrics, ready *readiness) *http.Server {
+	if address == "" {
+		return nil
 	}
-	return value
-}
 
-// Note that the method returns default value if the string
-// cannot be parsed!
-func getBoolOrDefault(value string, defaultVal bool) bool {
-	parsed, err := strconv.ParseBool(value)
-	if err!= nil {
-		return defaultVal
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Panicf("error starting monitoring listener: %s", err)
 	}
-	return parsed
+
+	server := &http.Server{Handler: newMonitoringHandler(metrics, ready)}
+	go func() {
+		err := server.Serve(listener)
+		if err != http.ErrServerClosed {
+			log.Panicf("error serving monitoring endpoints: %s", err)
+		}
+	}()
+
+	return server
 }
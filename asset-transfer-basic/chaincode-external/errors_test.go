@@ -0,0 +1,85 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The error messages are the JSON encoding of the errors, with the same schema as the errors of
+// chaincode-go
+func TestContractErrorJSON(t *testing.T) {
+	stub := newMockStub(t)
+
+	response := stub.MockInvoke("tx1", [][]byte{[]byte("ReadAsset"), []byte("asset1")})
+	require.EqualValues(t, 500, response.Status)
+	require.Equal(t, `{"code":"NOT_FOUND","ID":"asset1","message":"the asset asset1 does not exist"}`, response.Message)
+
+	var decoded ContractError
+	require.NoError(t, json.Unmarshal([]byte(response.Message), &decoded))
+	require.Equal(t, ContractError{Code: ErrorCodeNotFound, ID: "asset1", Message: "the asset asset1 does not exist"}, decoded)
+
+	invoke(t, stub, "tx2", "CreateAsset", "asset1", "blue", "5", "Tomoko", "300")
+	response = stub.MockInvoke("tx3", [][]byte{[]byte("CreateAsset"), []byte("asset1"), []byte("blue"), []byte("5"), []byte("Tomoko"), []byte("300")})
+	require.Equal(t, `{"code":"ALREADY_EXISTS","ID":"asset1","message":"the asset asset1 already exists"}`, response.Message)
+
+	err := newContractError(ErrorCodeInvalidArgument, "", "the asset ID must not be empty")
+	require.EqualError(t, err, `{"code":"INVALID_ARGUMENT","message":"the asset ID must not be empty"}`)
+}
+
+func TestAssetValidation(t *testing.T) {
+	stub := newMockStub(t)
+
+	for _, test := range []struct {
+		args    []string
+		message string
+	}{
+		{[]string{"CreateAsset", "", "blue", "5", "Tomoko", "300"}, "the asset ID must not be empty"},
+		{[]string{"CreateAsset", "asset 1", "blue", "5", "Tomoko", "300"}, "the asset ID asset 1 must only contain letters, digits, '-', '_' and '.'"},
+		{[]string{"CreateAsset", strings.Repeat("a", 65), "blue", "5", "Tomoko", "300"}, "the asset ID must not be longer than 64 characters"},
+		{[]string{"CreateAsset", "asset1", "blue", "-5", "Tomoko", "300"}, "the size of asset asset1 must not be negative"},
+		{[]string{"UpdateAsset", "asset1", "blue", "5", "Tomoko", "-300"}, "the appraised value of asset asset1 must not be negative"},
+		{[]string{"TransferAsset", "asset1", ""}, "the new owner of asset asset1 must not be empty"},
+		{[]string{"AssetExists", ""}, "the asset ID must not be empty"},
+	} {
+		args := make([][]byte, len(test.args))
+		for i, arg := range test.args {
+			args[i] = []byte(arg)
+		}
+		response := stub.MockInvoke("tx1", args)
+		require.EqualValues(t, 500, response.Status)
+
+		var decoded ContractError
+		require.NoError(t, json.Unmarshal([]byte(response.Message), &decoded), response.Message)
+		require.Equal(t, ErrorCodeInvalidArgument, decoded.Code)
+		require.Equal(t, test.message, decoded.Message)
+	}
+
+	invoke(t, stub, "tx2", "CreateAsset", "ASSET-1_a.b", "blue", "5", "Tomoko", "300")
+}
+
+// The assets created before asset IDs were restricted can still be read, updated, transferred and deleted
+func TestLegacyAssetID(t *testing.T) {
+	stub := newMockStub(t)
+
+	for i, id := range []string{"asset 1", "asset:1", strings.Repeat("a", 65)} {
+		assetJSON, err := json.Marshal(&Asset{ID: id, Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 300})
+		require.NoError(t, err)
+		stub.MockTransactionStart("seed")
+		require.NoError(t, stub.PutState(id, assetJSON))
+		stub.MockTransactionEnd("seed")
+
+		require.JSONEq(t, string(assetJSON), string(invoke(t, stub, fmt.Sprintf("tx%d-1", i), "ReadAsset", id)))
+		invoke(t, stub, fmt.Sprintf("tx%d-2", i), "UpdateAsset", id, "red", "5", "Tomoko", "300")
+		invoke(t, stub, fmt.Sprintf("tx%d-3", i), "TransferAsset", id, "Brad")
+		invoke(t, stub, fmt.Sprintf("tx%d-4", i), "DeleteAsset", id)
+		require.Equal(t, "false", string(invoke(t, stub, fmt.Sprintf("tx%d-5", i), "AssetExists", id)))
+	}
+}
@@ -0,0 +1,69 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssetLifecycleEvents(t *testing.T) {
+	stub := newMockStub(t)
+
+	invoke(t, stub, "tx1", "CreateAsset", "asset1", "blue", "5", "Tomoko", "300")
+	requireEvent(t, stub, "AssetCreated", `{"after":{"AppraisedValue":300,"Color":"blue","ID":"asset1","Owner":"Tomoko","Size":5},`+
+		`"ID":"asset1","schemaVersion":1,"txId":"tx1","type":"AssetCreated"}`)
+
+	invoke(t, stub, "tx2", "UpdateAsset", "asset1", "red", "10", "Tomoko", "500")
+	requireEvent(t, stub, "AssetUpdated", `{"after":{"AppraisedValue":500,"Color":"red","ID":"asset1","Owner":"Tomoko","Size":10},`+
+		`"before":{"AppraisedValue":300,"Color":"blue","ID":"asset1","Owner":"Tomoko","Size":5},`+
+		`"ID":"asset1","schemaVersion":1,"txId":"tx2","type":"AssetUpdated"}`)
+
+	invoke(t, stub, "tx3", "TransferAsset", "asset1", "Brad")
+	requireEvent(t, stub, "AssetTransferred", `{"after":{"AppraisedValue":500,"Color":"red","ID":"asset1","Owner":"Brad","Size":10},`+
+		`"before":{"AppraisedValue":500,"Color":"red","ID":"asset1","Owner":"Tomoko","Size":10},`+
+		`"ID":"asset1","schemaVersion":1,"txId":"tx3","type":"AssetTransferred"}`)
+
+	invoke(t, stub, "tx4", "DeleteAsset", "asset1")
+	requireEvent(t, stub, "AssetDeleted", `{"before":{"AppraisedValue":500,"Color":"red","ID":"asset1","Owner":"Brad","Size":10},`+
+		`"ID":"asset1","schemaVersion":1,"txId":"tx4","type":"AssetDeleted"}`)
+
+	// failed transactions do not emit events
+	response := stub.MockInvoke("tx5", [][]byte{[]byte("DeleteAsset"), []byte("asset1")})
+	require.EqualValues(t, 500, response.Status)
+	require.Empty(t, stub.ChaincodeEventsChannel)
+}
+
+// newMockStub returns a mock stub invoking the asset-transfer-basic chaincode
+func newMockStub(t *testing.T) *shimtest.MockStub {
+	chaincode, err := contractapi.NewChaincode(&SmartContract{})
+	require.NoError(t, err)
+
+	return shimtest.NewMockStub("basic", chaincode)
+}
+
+// invoke invokes function with args as transaction txID, and requires it to succeed
+func invoke(t *testing.T, stub *shimtest.MockStub, txID string, function string, args ...string) []byte {
+	invokeArgs := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		invokeArgs = append(invokeArgs, []byte(arg))
+	}
+
+	response := stub.MockInvoke(txID, invokeArgs)
+	require.EqualValues(t, 200, response.Status, response.Message)
+
+	return response.Payload
+}
+
+// requireEvent requires the next chaincode event of the stub to have the given name and JSON payload
+func requireEvent(t *testing.T, stub *shimtest.MockStub, name string, payload string) {
+	require.NotEmpty(t, stub.ChaincodeEventsChannel)
+	event := <-stub.ChaincodeEventsChannel
+	require.Equal(t, name, event.EventName)
+	require.JSONEq(t, payload, string(event.Payload))
+}
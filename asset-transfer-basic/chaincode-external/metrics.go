@@ -0,0 +1,193 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// maxFunctionLabels caps the number of function names tracked, as the name is chosen by the
+// client. Invocations of further functions are reported under otherFunctionLabel.
+const maxFunctionLabels = 100
+
+const otherFunctionLabel = "other"
+
+// latencyBuckets are the upper bounds, in seconds, of the invocation latency histogram
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// chaincodeMetrics collects invocation counts, error counts and latencies per function
+type chaincodeMetrics struct {
+	// invocationsInFlight is first to be 64-bit aligned for atomic operations
+	invocationsInFlight int64
+
+	mu        sync.Mutex
+	functions map[string]*functionMetrics
+}
+
+type functionMetrics struct {
+	invocations  uint64
+	errors       uint64
+	bucketCounts []uint64
+	latencySum   float64
+}
+
+func newChaincodeMetrics() *chaincodeMetrics {
+	return &chaincodeMetrics{functions: make(map[string]*functionMetrics)}
+}
+
+// inFlight returns the number of invocations in progress
+func (m *chaincodeMetrics) inFlight() int64 {
+	return atomic.LoadInt64(&m.invocationsInFlight)
+}
+
+// observe records an invocation of function that took latency, and failed if failed is true
+func (m *chaincodeMetrics) observe(function string, failed bool, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fm, ok := m.functions[function]
+	if !ok {
+		if len(m.functions) >= maxFunctionLabels {
+			function = otherFunctionLabel
+		}
+		fm, ok = m.functions[function]
+		if !ok {
+			fm = &functionMetrics{bucketCounts: make([]uint64, len(latencyBuckets))}
+			m.functions[function] = fm
+		}
+	}
+
+	seconds := latency.Seconds()
+	fm.invocations++
+	if failed {
+		fm.errors++
+	}
+	fm.latencySum += seconds
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			fm.bucketCounts[i]++
+		}
+	}
+}
+
+// writeTo writes the metrics in the Prometheus text exposition format
+func (m *chaincodeMetrics) writeTo(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.functions))
+	for name := range m.functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "# HELP chaincode_invocations_in_flight Number of chaincode invocations in progress.")
+	fmt.Fprintln(w, "# TYPE chaincode_invocations_in_flight gauge")
+	fmt.Fprintf(w, "chaincode_invocations_in_flight %d\n", m.inFlight())
+
+	fmt.Fprintln(w, "# HELP chaincode_invocations_total Number of chaincode invocations.")
+	fmt.Fprintln(w, "# TYPE chaincode_invocations_total counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "chaincode_invocations_total{function=\"%s\"} %d\n", escapeLabel(name), m.functions[name].invocations)
+	}
+
+	fmt.Fprintln(w, "# HELP chaincode_invocation_errors_total Number of chaincode invocations that returned an error.")
+	fmt.Fprintln(w, "# TYPE chaincode_invocation_errors_total counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "chaincode_invocation_errors_total{function=\"%s\"} %d\n", escapeLabel(name), m.functions[name].errors)
+	}
+
+	fmt.Fprintln(w, "# HELP chaincode_invocation_duration_seconds Latency of chaincode invocations.")
+	fmt.Fprintln(w, "# TYPE chaincode_invocation_duration_seconds histogram")
+	for _, name := range names {
+		fm := m.functions[name]
+		label := escapeLabel(name)
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "chaincode_invocation_duration_seconds_bucket{function=\"%s\",le=\"%g\"} %d\n", label, bound, fm.bucketCounts[i])
+		}
+		fmt.Fprintf(w, "chaincode_invocation_duration_seconds_bucket{function=\"%s\",le=\"+Inf\"} %d\n", label, fm.invocations)
+		fmt.Fprintf(w, "chaincode_invocation_duration_seconds_sum{function=\"%s\"} %g\n", label, fm.latencySum)
+		fmt.Fprintf(w, "chaincode_invocation_duration_seconds_count{function=\"%s\"} %d\n", label, fm.invocations)
+	}
+}
+
+// escapeLabel escapes a label value as required by the Prometheus text exposition format
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// instrumentedChaincode wraps a chaincode to record metrics of its invocations
+type instrumentedChaincode struct {
+	cc      shim.Chaincode
+	metrics *chaincodeMetrics
+}
+
+// Init calls Init of the wrapped chaincode
+func (c *instrumentedChaincode) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	return c.cc.Init(stub)
+}
+
+// Invoke calls Invoke of the wrapped chaincode, and records the latency and outcome of the call
+func (c *instrumentedChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	function, _ := stub.GetFunctionAndParameters()
+
+	atomic.AddInt64(&c.metrics.invocationsInFlight, 1)
+	defer atomic.AddInt64(&c.metrics.invocationsInFlight, -1)
+
+	start := time.Now()
+	response := c.cc.Invoke(stub)
+	c.metrics.observe(function, response.Status >= shim.ERRORTHRESHOLD, time.Since(start))
+
+	return response
+}
+
+// readiness reports whether the chaincode server is ready to accept connections from peers
+type readiness struct {
+	ready int32
+}
+
+func (r *readiness) set(ready bool) {
+	var value int32
+	if ready {
+		value = 1
+	}
+	atomic.StoreInt32(&r.ready, value)
+}
+
+func (r *readiness) isReady() bool {
+	return atomic.LoadInt32(&r.ready) == 1
+}
+
+// newMonitoringHandler returns the handler of the monitoring listener, serving /healthz,
+// /readyz and the metrics in Prometheus format on /metrics
+func newMonitoringHandler(metrics *chaincodeMetrics, ready *readiness) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !ready.isReady() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		metrics.writeTo(w)
+	})
+
+	return mux
+}
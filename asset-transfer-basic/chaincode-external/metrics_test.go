@@ -0,0 +1,76 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentedChaincode(t *testing.T) {
+	chaincode, err := contractapi.NewChaincode(&SmartContract{})
+	require.NoError(t, err)
+	metrics := newChaincodeMetrics()
+	stub := shimtest.NewMockStub("basic", &instrumentedChaincode{cc: chaincode, metrics: metrics})
+
+	response := stub.MockInvoke("tx1", [][]byte{[]byte("CreateAsset"), []byte("asset1"), []byte("blue"), []byte("5"), []byte("Tomoko"), []byte("300")})
+	require.EqualValues(t, 200, response.Status, response.Message)
+	response = stub.MockInvoke("tx2", [][]byte{[]byte("CreateAsset"), []byte("asset1"), []byte("blue"), []byte("5"), []byte("Tomoko"), []byte("300")})
+	require.EqualValues(t, 500, response.Status)
+	response = stub.MockInvoke("tx3", [][]byte{[]byte("ReadAsset"), []byte("asset1")})
+	require.EqualValues(t, 200, response.Status, response.Message)
+
+	body := scrape(t, newMonitoringHandler(metrics, &readiness{}), "/metrics", http.StatusOK)
+	require.Contains(t, body, "chaincode_invocations_total{function=\"CreateAsset\"} 2\n")
+	require.Contains(t, body, "chaincode_invocations_total{function=\"ReadAsset\"} 1\n")
+	require.Contains(t, body, "chaincode_invocation_errors_total{function=\"CreateAsset\"} 1\n")
+	require.Contains(t, body, "chaincode_invocation_errors_total{function=\"ReadAsset\"} 0\n")
+	require.Contains(t, body, "chaincode_invocation_duration_seconds_bucket{function=\"CreateAsset\",le=\"+Inf\"} 2\n")
+	require.Contains(t, body, "chaincode_invocation_duration_seconds_count{function=\"ReadAsset\"} 1\n")
+}
+
+func TestChaincodeMetrics(t *testing.T) {
+	metrics := newChaincodeMetrics()
+	metrics.observe("Slow\"Function", false, 300*time.Millisecond)
+	for i := 0; i < maxFunctionLabels+5; i++ {
+		metrics.observe(fmt.Sprintf("Function%d", i), true, time.Millisecond)
+	}
+
+	var body strings.Builder
+	metrics.writeTo(&body)
+	require.Contains(t, body.String(), "chaincode_invocation_duration_seconds_bucket{function=\"Slow\\\"Function\",le=\"0.25\"} 0\n")
+	require.Contains(t, body.String(), "chaincode_invocation_duration_seconds_bucket{function=\"Slow\\\"Function\",le=\"0.5\"} 1\n")
+	require.Contains(t, body.String(), "chaincode_invocation_duration_seconds_sum{function=\"Slow\\\"Function\"} 0.3\n")
+	require.Contains(t, body.String(), "chaincode_invocation_errors_total{function=\"other\"} 6\n")
+	require.NotContains(t, body.String(), "Function99\"")
+	require.Len(t, metrics.functions, maxFunctionLabels+1)
+}
+
+func TestMonitoringHandler(t *testing.T) {
+	ready := &readiness{}
+	handler := newMonitoringHandler(newChaincodeMetrics(), ready)
+
+	require.Equal(t, "ok\n", scrape(t, handler, "/healthz", http.StatusOK))
+	scrape(t, handler, "/readyz", http.StatusServiceUnavailable)
+
+	ready.set(true)
+	require.Equal(t, "ok\n", scrape(t, handler, "/readyz", http.StatusOK))
+}
+
+func scrape(t *testing.T, handler http.Handler, path string, expectedStatus int) string {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	require.Equal(t, expectedStatus, recorder.Code)
+
+	return recorder.Body.String()
+}
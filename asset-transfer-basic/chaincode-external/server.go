@@ -0,0 +1,68 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+// maxMessageSize is the maximum size of the messages exchanged with the peer, as in the peer
+const maxMessageSize = 100 * 1024 * 1024
+
+// drainPollInterval is how often drain checks whether in-flight invocations completed
+const drainPollInterval = 50 * time.Millisecond
+
+// newChaincodeGRPCServer returns a gRPC server serving the chaincode to peers, with the options
+// of shim.ChaincodeServer. Unlike shim.ChaincodeServer, the server can be stopped, and its TLS
+// configuration can be reloaded. TLS is disabled when tlsConfig is nil.
+func newChaincodeGRPCServer(ccid string, cc shim.Chaincode, tlsConfig *tls.Config) *grpc.Server {
+	serverOpts := []grpc.ServerOption{
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    1 * time.Minute,
+			Timeout: 20 * time.Second,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             1 * time.Minute,
+			PermitWithoutStream: true,
+		}),
+		grpc.ConnectionTimeout(5 * time.Second),
+		grpc.MaxSendMsgSize(maxMessageSize),
+		grpc.MaxRecvMsgSize(maxMessageSize),
+	}
+	if tlsConfig != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	server := grpc.NewServer(serverOpts...)
+	// shim.ChaincodeServer implements the Connect stream of the chaincode service
+	pb.RegisterChaincodeServer(server, &shim.ChaincodeServer{CCID: ccid, CC: cc})
+
+	return server
+}
+
+// drain stops the server gracefully. New connections are refused at once, and the server is
+// stopped, closing the streams of the peers, once no invocation is in flight or after timeout.
+// It returns false if invocations were still in flight when the server was stopped.
+func drain(server *grpc.Server, metrics *chaincodeMetrics, timeout time.Duration) bool {
+	// GracefulStop closes the listener, then waits for the peer streams to end, which
+	// only happens when the peers disconnect, so it is not waited for
+	go server.GracefulStop()
+
+	deadline := time.Now().Add(timeout)
+	for metrics.inFlight() > 0 && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+	drained := metrics.inFlight() == 0
+	server.Stop()
+
+	return drained
+}
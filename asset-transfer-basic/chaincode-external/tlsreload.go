@@ -0,0 +1,176 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sync"
+	"time"
+)
+
+// tlsFiles are the paths of the PEM files used by the chaincode server for TLS
+type tlsFiles struct {
+	Key          string
+	Cert         string
+	ClientCACert string
+}
+
+// tlsReloader serves the TLS configuration loaded from tlsFiles, and reloads it when the
+// content of the files changes, so that certificates can be rotated without a restart.
+// Connections established before a reload keep using the previous certificate.
+type tlsReloader struct {
+	files tlsFiles
+
+	mu      sync.RWMutex
+	config  *tls.Config
+	content [][]byte
+}
+
+// newTLSReloader returns a reloader serving the TLS configuration loaded from files
+func newTLSReloader(files tlsFiles) (*tlsReloader, error) {
+	r := &tlsReloader{files: files}
+	_, err := r.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// serverConfig returns the TLS configuration to pass to the gRPC server. The configuration
+// of every connection is the last one loaded.
+func (r *tlsReloader) serverConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.config, nil
+		},
+	}
+}
+
+// watch checks the files for changes every interval until stop is closed
+func (r *tlsReloader) watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			reloaded, err := r.reload()
+			if err != nil {
+				// the files may be in the middle of being replaced, keep the current
+				// configuration and try again on the next tick
+				log.Printf("failed to reload TLS files, keeping the current certificate: %s", err)
+			} else if reloaded {
+				log.Printf("reloaded TLS certificate from %s", r.files.Cert)
+			}
+		}
+	}
+}
+
+// reload loads the files, and replaces the configuration if their content changed.
+// It returns true if the configuration was replaced.
+func (r *tlsReloader) reload() (bool, error) {
+	content, err := readFiles(r.files.Key, r.files.Cert, r.files.ClientCACert)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	unchanged := r.content != nil && equalContent(r.content, content)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	config, err := newServerTLSConfig(content[0], content[1], content[2])
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	r.config = config
+	r.content = content
+	r.mu.Unlock()
+
+	return true, nil
+}
+
+// newServerTLSConfig returns the TLS configuration of the chaincode server, following the
+// defaults of the peer and of shim.ChaincodeServer. Peers must present a certificate signed by
+// clientCACert, unless it is empty.
+func newServerTLSConfig(key, cert, clientCACert []byte) (*tls.Config, error) {
+	keyPair, err := tls.X509KeyPair(cert, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key pair: %s", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:             tls.VersionTLS12,
+		Certificates:           []tls.Certificate{keyPair},
+		NextProtos:             []string{"h2"},
+		SessionTicketsDisabled: true,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+		},
+	}
+
+	if len(clientCACert) > 0 {
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(clientCACert) {
+			return nil, errors.New("failed to load client CA cert")
+		}
+		config.ClientCAs = clientCAs
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// readFiles returns the content of the files, an empty path is read as empty content
+func readFiles(paths ...string) ([][]byte, error) {
+	content := make([][]byte, len(paths))
+	for i, path := range paths {
+		if path == "" {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error while reading the crypto file: %s", err)
+		}
+		content[i] = data
+	}
+
+	return content, nil
+}
+
+func equalContent(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
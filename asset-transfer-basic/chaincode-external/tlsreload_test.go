@@ -0,0 +1,237 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsreload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := tlsFiles{
+		Key:  filepath.Join(dir, "server.key"),
+		Cert: filepath.Join(dir, "server.crt"),
+	}
+	writeSelfSignedCert(t, files, "chaincode-1")
+
+	reloader, err := newTLSReloader(files)
+	require.NoError(t, err)
+	address := serveTLS(t, reloader.serverConfig())
+	require.Equal(t, "chaincode-1", servedCommonName(t, address))
+
+	reloaded, err := reloader.reload()
+	require.NoError(t, err)
+	require.False(t, reloaded)
+
+	writeSelfSignedCert(t, files, "chaincode-2")
+	reloaded, err = reloader.reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+	require.Equal(t, "chaincode-2", servedCommonName(t, address))
+
+	// a key being replaced does not match the certificate, the current one is kept
+	require.NoError(t, ioutil.WriteFile(files.Key, []byte("partial"), 0600))
+	_, err = reloader.reload()
+	require.EqualError(t, err, "failed to parse key pair: tls: failed to find any PEM data in key input")
+	require.Equal(t, "chaincode-2", servedCommonName(t, address))
+
+	require.NoError(t, os.Remove(files.Key))
+	_, err = reloader.reload()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "error while reading the crypto file")
+	require.Equal(t, "chaincode-2", servedCommonName(t, address))
+}
+
+func TestTLSReloaderWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsreload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := tlsFiles{
+		Key:  filepath.Join(dir, "server.key"),
+		Cert: filepath.Join(dir, "server.crt"),
+	}
+	writeSelfSignedCert(t, files, "chaincode-1")
+
+	reloader, err := newTLSReloader(files)
+	require.NoError(t, err)
+	address := serveTLS(t, reloader.serverConfig())
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go reloader.watch(10*time.Millisecond, stop)
+
+	writeSelfSignedCert(t, files, "chaincode-2")
+	require.Eventually(t, func() bool {
+		return servedCommonName(t, address) == "chaincode-2"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestTLSReloaderClientCA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsreload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := tlsFiles{
+		Key:          filepath.Join(dir, "server.key"),
+		Cert:         filepath.Join(dir, "server.crt"),
+		ClientCACert: filepath.Join(dir, "client.crt"),
+	}
+	clientFiles := tlsFiles{
+		Key:  filepath.Join(dir, "client.key"),
+		Cert: files.ClientCACert,
+	}
+	writeSelfSignedCert(t, files, "chaincode")
+	writeSelfSignedCert(t, clientFiles, "peer")
+
+	reloader, err := newTLSReloader(files)
+	require.NoError(t, err)
+	address := serveTLS(t, reloader.serverConfig())
+
+	_, err = dialTLS(address, nil)
+	require.Error(t, err)
+
+	clientCert, err := tls.LoadX509KeyPair(clientFiles.Cert, clientFiles.Key)
+	require.NoError(t, err)
+	_, err = dialTLS(address, &clientCert)
+	require.NoError(t, err)
+}
+
+func TestDrain(t *testing.T) {
+	chaincode, err := contractapi.NewChaincode(&SmartContract{})
+	require.NoError(t, err)
+	metrics := newChaincodeMetrics()
+	server := newChaincodeGRPCServer("basic:1", &instrumentedChaincode{cc: chaincode, metrics: metrics}, nil)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+
+	// an invocation completing during the drain
+	atomic.StoreInt64(&metrics.invocationsInFlight, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		atomic.StoreInt64(&metrics.invocationsInFlight, 0)
+	}()
+
+	start := time.Now()
+	require.True(t, drain(server, metrics, 5*time.Second))
+	require.True(t, time.Since(start) < 5*time.Second)
+	// Serve returns once the server is stopped
+	<-served
+
+	_, err = net.DialTimeout("tcp", listener.Addr().String(), time.Second)
+	require.Error(t, err)
+}
+
+func TestDrainTimeout(t *testing.T) {
+	metrics := newChaincodeMetrics()
+	server := newChaincodeGRPCServer("basic:1", &instrumentedChaincode{metrics: metrics}, nil)
+
+	atomic.StoreInt64(&metrics.invocationsInFlight, 1)
+	require.False(t, drain(server, metrics, 100*time.Millisecond))
+}
+
+// writeSelfSignedCert writes a new key and a self-signed certificate for commonName to files
+func writeSelfSignedCert(t *testing.T, files tlsFiles, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+	require.NoError(t, ioutil.WriteFile(files.Key, keyPEM, 0600))
+	require.NoError(t, ioutil.WriteFile(files.Cert, certPEM, 0644))
+}
+
+// serveTLS completes TLS handshakes on a local listener with config, and returns its address
+func serveTLS(t *testing.T, config *tls.Config) string {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func dialTLS(address string, clientCert *tls.Certificate) (*tls.Conn, error) {
+	config := &tls.Config{InsecureSkipVerify: true}
+	if clientCert != nil {
+		config.Certificates = []tls.Certificate{*clientCert}
+	}
+
+	conn, err := tls.Dial("tcp", address, config)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// with TLS 1.3, a rejected client certificate is reported on the first read, the
+	// connection is otherwise closed by the server after the handshake
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	if err != io.EOF {
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// servedCommonName returns the common name of the certificate served on address
+func servedCommonName(t *testing.T, address string) string {
+	conn, err := dialTLS(address, nil)
+	require.NoError(t, err)
+
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
@@ -6,18 +6,67 @@ package main
 
 import (
 	"log"
+	"os"
+	"strconv"
+	"time"
 
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/chaincode/txlog"
 )
 
 func main() {
-	assetChaincode, err := contractapi.NewChaincode(&chaincode.SmartContract{})
+	// Owner authorization and soft delete are opt-in, set ASSET_OWNER_AUTHORIZATION=true
+	// or ASSET_SOFT_DELETE=true to enable them
+	assetContract := &chaincode.SmartContract{
+		OwnerAuthorization: getBoolEnv("ASSET_OWNER_AUTHORIZATION"),
+		SoftDelete:         getBoolEnv("ASSET_SOFT_DELETE"),
+		RetentionPeriod:    getDurationEnv("ASSET_RETENTION_PERIOD"),
+		AdminMSPID:         os.Getenv("ASSET_ADMIN_MSPID"),
+	}
+
+	// Every transaction is logged as a JSON line, and panics are returned as errors
+	logger := txlog.New(os.Stdout)
+	logger.Hook(&assetContract.Contract)
+
+	assetChaincode, err := contractapi.NewChaincode(assetContract)
 	if err != nil {
 		log.Panicf("Error creating asset-transfer-basic chaincode: %v", err)
 	}
 
-	if err := assetChaincode.Start(); err != nil {
+	if err := shim.Start(logger.Chaincode(assetChaincode)); err != nil {
 		log.Panicf("Error starting asset-transfer-basic chaincode: %v", err)
 	}
 }
+
+// getBoolEnv returns the boolean value of the environment variable, false if it is not set
+func getBoolEnv(name string) bool {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return false
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Panicf("Error parsing %s: %v", name, err)
+	}
+
+	return enabled
+}
+
+// getDurationEnv returns the duration value of the environment variable, such as 720h,
+// zero if it is not set
+func getDurationEnv(name string) time.Duration {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return 0
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Panicf("Error parsing %s: %v", name, err)
+	}
+
+	return duration
+}
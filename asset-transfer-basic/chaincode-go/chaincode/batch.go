@@ -0,0 +1,276 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// AssetTransfer describes a single ownership change requested as part of a batch
+type AssetTransfer struct {
+	ID       string `json:"ID"`
+	NewOwner string `json:"NewOwner"`
+}
+
+// BatchItemError describes why a single item of a batch failed validation
+type BatchItemError struct {
+	Index   int       `json:"index"`
+	ID      string    `json:"ID"`
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// BatchError is returned when a batch is rejected. It lists every item that failed
+// validation, and its message is the JSON encoding of the error with the code
+// INVALID_ARGUMENT, so that clients can parse it back into a BatchError.
+type BatchError struct {
+	Errors []BatchItemError `json:"errors"`
+}
+
+// Error returns the JSON encoding of the batch error
+func (e *BatchError) Error() string {
+	message := fmt.Sprintf("the batch was rejected with %d invalid items", len(e.Errors))
+
+	return encodeError(struct {
+		Code    ErrorCode        `json:"code"`
+		Message string           `json:"message"`
+		Errors  []BatchItemError `json:"errors"`
+	}{ErrorCodeInvalidArgument, message, e.Errors}, message)
+}
+
+// add records a validation failure for the item at the given index
+func (e *BatchError) add(index int, id string, code ErrorCode, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, BatchItemError{Index: index, ID: id, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// addError records err as the validation failure for the item at the given index,
+// keeping its code if it is a ContractError
+func (e *BatchError) addError(index int, id string, err error) {
+	var contractErr *ContractError
+	if errors.As(err, &contractErr) {
+		e.add(index, id, contractErr.Code, "%s", contractErr.Message)
+		return
+	}
+	e.add(index, id, ErrorCodeUnknown, "%v", err)
+}
+
+// CreateAssets issues a batch of new assets to the world state in a single transaction.
+// Every asset is validated before any is written; if one is invalid the whole batch is
+// rejected with a BatchError and nothing is written.
+func (s *SmartContract) CreateAssets(ctx contractapi.TransactionContextInterface, assets []Asset) error {
+	if len(assets) == 0 {
+		return newContractError(ErrorCodeInvalidArgument, "", "the batch must contain at least one asset")
+	}
+
+	batchErr := &BatchError{}
+	seen := make(map[string]bool)
+	for i, asset := range assets {
+		err := validateNewAsset(&assets[i])
+		if err != nil {
+			batchErr.addError(i, asset.ID, err)
+			continue
+		}
+		if seen[asset.ID] {
+			batchErr.add(i, asset.ID, ErrorCodeInvalidArgument, "the asset %s appears more than once in the batch", asset.ID)
+			continue
+		}
+		seen[asset.ID] = true
+
+		existing, err := getAsset(ctx, asset.ID)
+		if err != nil {
+			return err
+		}
+		err = checkAssetIDFree(existing)
+		if err != nil {
+			batchErr.addError(i, asset.ID, err)
+			continue
+		}
+
+		owner, err := s.bindOwner(ctx, asset.Owner)
+		if err != nil {
+			batchErr.addError(i, asset.ID, err)
+			continue
+		}
+		assets[i].Owner = owner
+	}
+	if len(batchErr.Errors) > 0 {
+		return batchErr
+	}
+
+	events := make([]AssetEvent, 0, len(assets))
+	for i := range assets {
+		err := putAsset(ctx, &assets[i], nil)
+		if err != nil {
+			return err
+		}
+		events = append(events, newAssetEvent(ctx, AssetCreatedEvent, nil, &assets[i]))
+	}
+
+	return emitAssetBatchEvent(ctx, events)
+}
+
+// UpdateAssets overwrites a batch of existing assets in the world state in a single transaction.
+// Every asset is validated before any is written; if one is invalid the whole batch is
+// rejected with a BatchError and nothing is written.
+func (s *SmartContract) UpdateAssets(ctx contractapi.TransactionContextInterface, assets []Asset) error {
+	if len(assets) == 0 {
+		return newContractError(ErrorCodeInvalidArgument, "", "the batch must contain at least one asset")
+	}
+
+	batchErr := &BatchError{}
+	seen := make(map[string]bool)
+	previous := make([]*Asset, len(assets))
+	for i, asset := range assets {
+		err := validateAsset(&assets[i])
+		if err != nil {
+			batchErr.addError(i, asset.ID, err)
+			continue
+		}
+		if seen[asset.ID] {
+			batchErr.add(i, asset.ID, ErrorCodeInvalidArgument, "the asset %s appears more than once in the batch", asset.ID)
+			continue
+		}
+		seen[asset.ID] = true
+
+		existing, err := getAsset(ctx, asset.ID)
+		if err != nil {
+			return err
+		}
+		if existing == nil || existing.isDeleted() {
+			batchErr.addError(i, asset.ID, notFoundError(asset.ID))
+			continue
+		}
+
+		err = s.authorizeOwner(ctx, existing)
+		if err != nil {
+			batchErr.addError(i, asset.ID, err)
+			continue
+		}
+		if s.OwnerAuthorization && asset.Owner != existing.Owner {
+			batchErr.addError(i, asset.ID, ownerChangeError(asset.ID))
+			continue
+		}
+		previous[i] = existing
+	}
+	if len(batchErr.Errors) > 0 {
+		return batchErr
+	}
+
+	events := make([]AssetEvent, 0, len(assets))
+	for i := range assets {
+		err := putAsset(ctx, &assets[i], previous[i])
+		if err != nil {
+			return err
+		}
+		events = append(events, newAssetEvent(ctx, AssetUpdatedEvent, previous[i], &assets[i]))
+	}
+
+	return emitAssetBatchEvent(ctx, events)
+}
+
+// TransferAssets updates the owner field of a batch of assets in a single transaction.
+// Every transfer is validated before any is written; if one is invalid the whole batch is
+// rejected with a BatchError and nothing is written.
+func (s *SmartContract) TransferAssets(ctx contractapi.TransactionContextInterface, transfers []AssetTransfer) error {
+	if len(transfers) == 0 {
+		return newContractError(ErrorCodeInvalidArgument, "", "the batch must contain at least one transfer")
+	}
+
+	batchErr := &BatchError{}
+	seen := make(map[string]bool)
+	assets := make([]*Asset, 0, len(transfers))
+	previous := make([]*Asset, 0, len(transfers))
+	for i, transfer := range transfers {
+		err := validateAssetID(transfer.ID)
+		if err != nil {
+			batchErr.addError(i, transfer.ID, err)
+			continue
+		}
+		if transfer.NewOwner == "" {
+			batchErr.addError(i, transfer.ID, newOwnerEmptyError(transfer.ID))
+			continue
+		}
+		if seen[transfer.ID] {
+			batchErr.add(i, transfer.ID, ErrorCodeInvalidArgument, "the asset %s appears more than once in the batch", transfer.ID)
+			continue
+		}
+		seen[transfer.ID] = true
+
+		asset, err := getAsset(ctx, transfer.ID)
+		if err != nil {
+			return err
+		}
+		if asset == nil || asset.isDeleted() {
+			batchErr.addError(i, transfer.ID, notFoundError(transfer.ID))
+			continue
+		}
+
+		err = s.authorizeOwner(ctx, asset)
+		if err != nil {
+			batchErr.addError(i, transfer.ID, err)
+			continue
+		}
+		before := *asset
+		asset.Owner = transfer.NewOwner
+		assets = append(assets, asset)
+		previous = append(previous, &before)
+	}
+	if len(batchErr.Errors) > 0 {
+		return batchErr
+	}
+
+	events := make([]AssetEvent, 0, len(assets))
+	for i, asset := range assets {
+		err := putAsset(ctx, asset, previous[i])
+		if err != nil {
+			return err
+		}
+		events = append(events, newAssetEvent(ctx, AssetTransferredEvent, previous[i], asset))
+	}
+
+	return emitAssetBatchEvent(ctx, events)
+}
+
+// getAsset reads the asset with given id from the world state, returning nil if it does not exist.
+// Unlike ReadAsset, the tombstones of soft deleted assets are returned.
+func getAsset(ctx contractapi.TransactionContextInterface, id string) (*Asset, error) {
+	assetJSON, err := ctx.GetStub().GetState(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if assetJSON == nil {
+		return nil, nil
+	}
+
+	var asset Asset
+	err = json.Unmarshal(assetJSON, &asset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &asset, nil
+}
+
+// putAsset writes the given asset to the world state under its ID, as the next version of
+// previous, or as the first version of a new asset when previous is nil
+func putAsset(ctx contractapi.TransactionContextInterface, asset *Asset, previous *Asset) error {
+	asset.Version = 1
+	if previous != nil {
+		asset.Version = previous.Version + 1
+	}
+	asset.LastTxID = ctx.GetStub().GetTxID()
+
+	assetJSON, err := json.Marshal(asset)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(asset.ID, assetJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put to world state. %v", err)
+	}
+
+	return nil
+}
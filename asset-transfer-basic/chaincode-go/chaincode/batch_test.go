@@ -0,0 +1,152 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateAssets(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	assets := []chaincode.Asset{
+		{ID: "asset1", Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 300},
+		{ID: "asset2", Color: "red", Size: 5, Owner: "Brad", AppraisedValue: 400},
+	}
+	assetTransfer := chaincode.SmartContract{}
+	err := assetTransfer.CreateAssets(transactionContext, assets)
+	require.NoError(t, err)
+	require.Equal(t, 2, chaincodeStub.PutStateCallCount())
+	key, value := chaincodeStub.PutStateArgsForCall(1)
+	require.Equal(t, "asset2", key)
+	expected, err := json.Marshal(assets[1])
+	require.NoError(t, err)
+	require.Equal(t, expected, value)
+
+	chaincodeStub = &mocks.ChaincodeStub{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	chaincodeStub.GetStateReturnsOnCall(1, assetJSON(t, &chaincode.Asset{ID: "asset2"}), nil)
+	err = assetTransfer.CreateAssets(transactionContext, []chaincode.Asset{
+		{ID: "asset1"},
+		{ID: "asset2"},
+		{ID: ""},
+		{ID: "asset1"},
+	})
+	var batchErr *chaincode.BatchError
+	require.True(t, errors.As(err, &batchErr))
+	require.Equal(t, []chaincode.BatchItemError{
+		{Index: 1, ID: "asset2", Code: chaincode.ErrorCodeAlreadyExists, Message: "the asset asset2 already exists"},
+		{Index: 2, ID: "", Code: chaincode.ErrorCodeInvalidArgument, Message: "the asset ID must not be empty"},
+		{Index: 3, ID: "asset1", Code: chaincode.ErrorCodeInvalidArgument, Message: "the asset asset1 appears more than once in the batch"},
+	}, batchErr.Errors)
+	require.Zero(t, chaincodeStub.PutStateCallCount())
+
+	var decoded chaincode.BatchError
+	require.NoError(t, json.Unmarshal([]byte(err.Error()), &decoded))
+	require.Equal(t, batchErr.Errors, decoded.Errors)
+	require.Contains(t, err.Error(), `"code":"INVALID_ARGUMENT"`)
+
+	err = assetTransfer.CreateAssets(transactionContext, nil)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the batch must contain at least one asset")
+
+	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
+	err = assetTransfer.CreateAssets(transactionContext, assets)
+	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
+
+	chaincodeStub.GetStateReturns(nil, nil)
+	chaincodeStub.PutStateReturns(fmt.Errorf("failed inserting key"))
+	err = assetTransfer.CreateAssets(transactionContext, assets)
+	require.EqualError(t, err, "failed to put to world state. failed inserting key")
+}
+
+func TestUpdateAssets(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	bytes, err := json.Marshal(&chaincode.Asset{ID: "asset1"})
+	require.NoError(t, err)
+
+	chaincodeStub.GetStateReturns(bytes, nil)
+	assets := []chaincode.Asset{
+		{ID: "asset1", Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 350},
+		{ID: "asset2", Color: "red", Size: 5, Owner: "Brad", AppraisedValue: 450},
+	}
+	assetTransfer := chaincode.SmartContract{}
+	err = assetTransfer.UpdateAssets(transactionContext, assets)
+	require.NoError(t, err)
+	require.Equal(t, 2, chaincodeStub.PutStateCallCount())
+
+	chaincodeStub = &mocks.ChaincodeStub{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	chaincodeStub.GetStateReturns(bytes, nil)
+	chaincodeStub.GetStateReturnsOnCall(0, nil, nil)
+	err = assetTransfer.UpdateAssets(transactionContext, assets)
+	var batchErr *chaincode.BatchError
+	require.True(t, errors.As(err, &batchErr))
+	require.Equal(t, []chaincode.BatchItemError{
+		{Index: 0, ID: "asset1", Code: chaincode.ErrorCodeNotFound, Message: "the asset asset1 does not exist"},
+	}, batchErr.Errors)
+	require.Zero(t, chaincodeStub.PutStateCallCount())
+
+	err = assetTransfer.UpdateAssets(transactionContext, []chaincode.Asset{})
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the batch must contain at least one asset")
+
+	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
+	err = assetTransfer.UpdateAssets(transactionContext, assets[1:])
+	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
+}
+
+func TestTransferAssets(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	asset := &chaincode.Asset{ID: "asset1", Owner: "Tomoko"}
+	bytes, err := json.Marshal(asset)
+	require.NoError(t, err)
+
+	chaincodeStub.GetStateReturns(bytes, nil)
+	assetTransfer := chaincode.SmartContract{}
+	err = assetTransfer.TransferAssets(transactionContext, []chaincode.AssetTransfer{{ID: "asset1", NewOwner: "Brad"}})
+	require.NoError(t, err)
+	require.Equal(t, 1, chaincodeStub.PutStateCallCount())
+	key, value := chaincodeStub.PutStateArgsForCall(0)
+	require.Equal(t, "asset1", key)
+	var transferred chaincode.Asset
+	require.NoError(t, json.Unmarshal(value, &transferred))
+	require.Equal(t, "Brad", transferred.Owner)
+
+	chaincodeStub = &mocks.ChaincodeStub{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	chaincodeStub.GetStateReturns(bytes, nil)
+	chaincodeStub.GetStateReturnsOnCall(1, nil, nil)
+	err = assetTransfer.TransferAssets(transactionContext, []chaincode.AssetTransfer{
+		{ID: "asset1", NewOwner: "Brad"},
+		{ID: "asset2", NewOwner: "Brad"},
+		{ID: "asset3", NewOwner: ""},
+		{ID: "asset1", NewOwner: "Max"},
+	})
+	var batchErr *chaincode.BatchError
+	require.True(t, errors.As(err, &batchErr))
+	require.Equal(t, []chaincode.BatchItemError{
+		{Index: 1, ID: "asset2", Code: chaincode.ErrorCodeNotFound, Message: "the asset asset2 does not exist"},
+		{Index: 2, ID: "asset3", Code: chaincode.ErrorCodeInvalidArgument, Message: "the new owner of asset asset3 must not be empty"},
+		{Index: 3, ID: "asset1", Code: chaincode.ErrorCodeInvalidArgument, Message: "the asset asset1 appears more than once in the batch"},
+	}, batchErr.Errors)
+	require.Zero(t, chaincodeStub.PutStateCallCount())
+
+	err = assetTransfer.TransferAssets(transactionContext, nil)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the batch must contain at least one transfer")
+
+	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
+	err = assetTransfer.TransferAssets(transactionContext, []chaincode.AssetTransfer{{ID: "asset9", NewOwner: "Brad"}})
+	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
+}
@@ -0,0 +1,161 @@
+package chaincode
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RestoreAsset restores a soft deleted asset to the state it had when it was deleted.
+// The asset can only be restored within the retention period of the contract, by the owner of
+// the asset at the time it was deleted or an operator approved by that owner when owner
+// authorization is enabled, and by the client which deleted the asset otherwise.
+func (s *SmartContract) RestoreAsset(ctx contractapi.TransactionContextInterface, id string) error {
+	tombstone, err := s.ReadDeletedAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.authorizeRestore(ctx, tombstone)
+	if err != nil {
+		return err
+	}
+
+	if s.RetentionPeriod > 0 {
+		deletedAt, err := time.Parse(time.RFC3339Nano, tombstone.DeletedAt)
+		if err != nil {
+			return fmt.Errorf("failed to parse the deletion time of asset %s: %v", id, err)
+		}
+		now, err := txTime(ctx)
+		if err != nil {
+			return err
+		}
+		expiry := deletedAt.Add(s.RetentionPeriod)
+		if now.After(expiry) {
+			return newContractError(ErrorCodeFailedPrecondition, id, "the retention period of asset %s expired at %s", id, expiry.Format(time.RFC3339))
+		}
+	}
+
+	asset := *tombstone
+	asset.DeletedAt = ""
+	asset.DeletedBy = ""
+	err = putAsset(ctx, &asset, tombstone)
+	if err != nil {
+		return err
+	}
+
+	return emitAssetEvent(ctx, AssetRestoredEvent, tombstone, &asset)
+}
+
+// authorizeRestore returns an error unless the submitting client may restore the tombstone.
+// Without owner authorization the owners of the assets are not client identities, so the
+// client which deleted the asset stands for its previous owner.
+func (s *SmartContract) authorizeRestore(ctx contractapi.TransactionContextInterface, tombstone *Asset) error {
+	if s.OwnerAuthorization {
+		return s.authorizeOwner(ctx, tombstone)
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+	if clientID != tombstone.DeletedBy {
+		return newContractError(ErrorCodePermissionDenied, tombstone.ID, "submitting client not authorized to restore asset %s, did not delete asset", tombstone.ID)
+	}
+
+	return nil
+}
+
+// PurgeAsset removes a soft deleted asset from the world state for good. It can only be
+// called by a client of the admin organization of the contract.
+func (s *SmartContract) PurgeAsset(ctx contractapi.TransactionContextInterface, id string) error {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	tombstone, err := s.ReadDeletedAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().DelState(id)
+	if err != nil {
+		return err
+	}
+
+	return emitAssetEvent(ctx, AssetPurgedEvent, tombstone, nil)
+}
+
+// ReadDeletedAsset returns the tombstone of the soft deleted asset with given id.
+func (s *SmartContract) ReadDeletedAsset(ctx contractapi.TransactionContextInterface, id string) (*Asset, error) {
+	err := validateAssetID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	asset, err := getAsset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if asset == nil {
+		return nil, notFoundError(id)
+	}
+	if !asset.isDeleted() {
+		return nil, newContractError(ErrorCodeFailedPrecondition, id, "the asset %s is not deleted", id)
+	}
+
+	return asset, nil
+}
+
+// softDeleteAsset replaces the asset with a tombstone recording when, and by whom, it was deleted
+func (s *SmartContract) softDeleteAsset(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	tombstone := *asset
+	tombstone.DeletedAt = now.Format(time.RFC3339Nano)
+	tombstone.DeletedBy = clientID
+	err = putAsset(ctx, &tombstone, asset)
+	if err != nil {
+		return err
+	}
+
+	return emitAssetEvent(ctx, AssetDeletedEvent, asset, nil)
+}
+
+// checkAssetIDFree returns an ALREADY_EXISTS error if an asset, or the tombstone of a soft
+// deleted asset, is stored under the ID of a new asset
+func checkAssetIDFree(existing *Asset) error {
+	if existing == nil {
+		return nil
+	}
+	if existing.isDeleted() {
+		return newContractError(ErrorCodeAlreadyExists, existing.ID, "the asset %s was deleted and must be purged before its ID is reused", existing.ID)
+	}
+
+	return alreadyExistsError(existing.ID)
+}
+
+// isDeleted returns true if the asset is the tombstone of a soft deleted asset
+func (a *Asset) isDeleted() bool {
+	return a.DeletedAt != "" || a.DeletedBy != ""
+}
+
+// txTime returns the timestamp of the transaction, which is the same on every endorsing peer
+func txTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+
+	return ptypes.Timestamp(timestamp)
+}
@@ -0,0 +1,212 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+const deletedAt = "2021-03-01T10:00:00Z"
+
+func TestSoftDeleteAsset(t *testing.T) {
+	asset := &chaincode.Asset{ID: "asset1", Color: "blue", Owner: ownerClientID, LastTxID: "tx0", Version: 1}
+	state := map[string][]byte{
+		"asset1": assetJSON(t, asset),
+	}
+	assetTransfer := chaincode.SmartContract{SoftDelete: true}
+
+	transactionContext, chaincodeStub := prepOwnerMocks(t, ownerClientID, state)
+	chaincodeStub.GetTxIDReturns("tx1")
+	chaincodeStub.GetTxTimestampReturns(txTimestamp(t, deletedAt), nil)
+	err := assetTransfer.DeleteAsset(transactionContext, "asset1")
+	require.NoError(t, err)
+	require.Zero(t, chaincodeStub.DelStateCallCount())
+
+	tombstone := putAssetArg(t, chaincodeStub, 0)
+	require.Equal(t, &chaincode.Asset{
+		ID: "asset1", Color: "blue", Owner: ownerClientID, LastTxID: "tx1", Version: 2,
+		DeletedAt: deletedAt, DeletedBy: ownerClientID,
+	}, tombstone)
+	requireAssetEvent(t, chaincodeStub, 0, chaincode.AssetEvent{
+		Before: asset, ID: "asset1", SchemaVersion: 1, TxID: "tx1", Type: "AssetDeleted",
+	})
+
+	state["asset1"] = assetJSON(t, tombstone)
+
+	_, err = assetTransfer.ReadAsset(transactionContext, "asset1")
+	requireContractError(t, err, chaincode.ErrorCodeNotFound, "the asset asset1 does not exist")
+
+	exists, err := assetTransfer.AssetExists(transactionContext, "asset1")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	deleted, err := assetTransfer.ReadDeletedAsset(transactionContext, "asset1")
+	require.NoError(t, err)
+	require.Equal(t, tombstone, deleted)
+
+	err = assetTransfer.DeleteAsset(transactionContext, "asset1")
+	requireContractError(t, err, chaincode.ErrorCodeNotFound, "the asset asset1 does not exist")
+
+	err = assetTransfer.CreateAsset(transactionContext, "asset1", "red", 5, "", 100)
+	requireContractError(t, err, chaincode.ErrorCodeAlreadyExists, "the asset asset1 was deleted and must be purged before its ID is reused")
+
+	err = assetTransfer.TransferAssets(transactionContext, []chaincode.AssetTransfer{{ID: "asset1", NewOwner: "Brad"}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `"code":"NOT_FOUND"`)
+
+	err = assetTransfer.PatchAsset(transactionContext, "asset1", `{"DeletedAt":null}`)
+	requireContractError(t, err, chaincode.ErrorCodeNotFound, "the asset asset1 does not exist")
+	require.Equal(t, 1, chaincodeStub.PutStateCallCount())
+}
+
+func TestSoftDeletedAssetQueries(t *testing.T) {
+	asset := &chaincode.Asset{ID: "asset1"}
+	tombstone := &chaincode.Asset{ID: "asset2", DeletedAt: deletedAt, DeletedBy: ownerClientID}
+	newIterator := func() *mocks.StateQueryIterator {
+		iterator := &mocks.StateQueryIterator{}
+		iterator.HasNextReturnsOnCall(0, true)
+		iterator.HasNextReturnsOnCall(1, true)
+		iterator.HasNextReturnsOnCall(2, false)
+		iterator.NextReturnsOnCall(0, &queryresult.KV{Key: "asset1", Value: assetJSON(t, asset)}, nil)
+		iterator.NextReturnsOnCall(1, &queryresult.KV{Key: "asset2", Value: assetJSON(t, tombstone)}, nil)
+		return iterator
+	}
+
+	chaincodeStub := &mocks.ChaincodeStub{}
+	chaincodeStub.GetStateByRangeReturns(newIterator(), nil)
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	assetTransfer := chaincode.SmartContract{SoftDelete: true}
+	assets, err := assetTransfer.GetAllAssets(transactionContext)
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.Asset{asset}, assets)
+
+	chaincodeStub.GetStateByRangeWithPaginationReturns(newIterator(), &peer.QueryResponseMetadata{FetchedRecordsCount: 2}, nil)
+	result, err := assetTransfer.GetAssetsWithPagination(transactionContext, 10, "", "")
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.Asset{asset}, result.Records)
+
+	chaincodeStub.GetStateByRangeWithPaginationReturns(newIterator(), &peer.QueryResponseMetadata{FetchedRecordsCount: 2}, nil)
+	result, err = assetTransfer.GetAssetsWithPagination(transactionContext, 10, "", `{"IncludeDeleted":true}`)
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.Asset{asset, tombstone}, result.Records)
+}
+
+func TestRestoreAsset(t *testing.T) {
+	tombstone := &chaincode.Asset{ID: "asset1", Owner: ownerClientID, Version: 2, DeletedAt: deletedAt, DeletedBy: operatorClientID}
+	state := map[string][]byte{
+		"asset1": assetJSON(t, tombstone),
+		"asset2": assetJSON(t, &chaincode.Asset{ID: "asset2", Owner: ownerClientID}),
+	}
+	assetTransfer := chaincode.SmartContract{OwnerAuthorization: true, SoftDelete: true, RetentionPeriod: 24 * time.Hour}
+
+	transactionContext, chaincodeStub := prepOwnerMocks(t, ownerClientID, state)
+	chaincodeStub.GetTxIDReturns("tx3")
+	chaincodeStub.GetTxTimestampReturns(txTimestamp(t, "2021-03-02T10:00:00Z"), nil)
+	err := assetTransfer.RestoreAsset(transactionContext, "asset1")
+	require.NoError(t, err)
+	restored := &chaincode.Asset{ID: "asset1", Owner: ownerClientID, LastTxID: "tx3", Version: 3}
+	require.Equal(t, restored, putAssetArg(t, chaincodeStub, 0))
+	requireAssetEvent(t, chaincodeStub, 0, chaincode.AssetEvent{
+		After: restored, Before: tombstone, ID: "asset1", SchemaVersion: 1, TxID: "tx3", Type: "AssetRestored",
+	})
+
+	chaincodeStub.GetTxTimestampReturns(txTimestamp(t, "2021-03-02T10:00:01Z"), nil)
+	err = assetTransfer.RestoreAsset(transactionContext, "asset1")
+	requireContractError(t, err, chaincode.ErrorCodeFailedPrecondition, "the retention period of asset asset1 expired at 2021-03-02T10:00:00Z")
+
+	err = assetTransfer.RestoreAsset(transactionContext, "asset2")
+	requireContractError(t, err, chaincode.ErrorCodeFailedPrecondition, "the asset asset2 is not deleted")
+
+	err = assetTransfer.RestoreAsset(transactionContext, "asset3")
+	requireContractError(t, err, chaincode.ErrorCodeNotFound, "the asset asset3 does not exist")
+
+	transactionContext, chaincodeStub = prepOwnerMocks(t, otherClientID, state)
+	chaincodeStub.GetTxTimestampReturns(txTimestamp(t, "2021-03-01T11:00:00Z"), nil)
+	err = assetTransfer.RestoreAsset(transactionContext, "asset1")
+	requireContractError(t, err, chaincode.ErrorCodePermissionDenied, "submitting client not authorized to update asset asset1, does not own asset")
+	require.Zero(t, chaincodeStub.PutStateCallCount())
+}
+
+func TestRestoreAssetWithoutOwnerAuthorization(t *testing.T) {
+	tombstone := &chaincode.Asset{ID: "asset1", Owner: "Tomoko", Version: 2, DeletedAt: deletedAt, DeletedBy: operatorClientID}
+	state := map[string][]byte{
+		"asset1": assetJSON(t, tombstone),
+	}
+	assetTransfer := chaincode.SmartContract{SoftDelete: true}
+
+	transactionContext, chaincodeStub := prepOwnerMocks(t, otherClientID, state)
+	err := assetTransfer.RestoreAsset(transactionContext, "asset1")
+	requireContractError(t, err, chaincode.ErrorCodePermissionDenied, "submitting client not authorized to restore asset asset1, did not delete asset")
+	require.Zero(t, chaincodeStub.PutStateCallCount())
+
+	transactionContext, chaincodeStub = prepOwnerMocks(t, operatorClientID, state)
+	chaincodeStub.GetTxIDReturns("tx3")
+	err = assetTransfer.RestoreAsset(transactionContext, "asset1")
+	require.NoError(t, err)
+	require.Equal(t, &chaincode.Asset{ID: "asset1", Owner: "Tomoko", LastTxID: "tx3", Version: 3}, putAssetArg(t, chaincodeStub, 0))
+}
+
+func TestPurgeAsset(t *testing.T) {
+	tombstone := &chaincode.Asset{ID: "asset1", Owner: ownerClientID, DeletedAt: deletedAt, DeletedBy: ownerClientID}
+	state := map[string][]byte{
+		"asset1": assetJSON(t, tombstone),
+		"asset2": assetJSON(t, &chaincode.Asset{ID: "asset2", Owner: ownerClientID}),
+	}
+	assetTransfer := chaincode.SmartContract{SoftDelete: true}
+
+	transactionContext, chaincodeStub := prepOwnerMocks(t, ownerClientID, state)
+	clientIdentity := transactionContext.GetClientIdentity().(*mocks.ClientIdentity)
+	clientIdentity.GetMSPIDReturns("Org1MSP", nil)
+	err := assetTransfer.PurgeAsset(transactionContext, "asset1")
+	requireContractError(t, err, chaincode.ErrorCodePermissionDenied, "admin operations are disabled, no admin MSP ID is configured")
+
+	assetTransfer.AdminMSPID = "Org2MSP"
+	err = assetTransfer.PurgeAsset(transactionContext, "asset1")
+	requireContractError(t, err, chaincode.ErrorCodePermissionDenied, "submitting client of MSP Org1MSP is not an admin")
+	require.Zero(t, chaincodeStub.DelStateCallCount())
+
+	assetTransfer.AdminMSPID = "Org1MSP"
+	err = assetTransfer.PurgeAsset(transactionContext, "asset2")
+	requireContractError(t, err, chaincode.ErrorCodeFailedPrecondition, "the asset asset2 is not deleted")
+
+	err = assetTransfer.PurgeAsset(transactionContext, "asset1")
+	require.NoError(t, err)
+	require.Equal(t, "asset1", chaincodeStub.DelStateArgsForCall(0))
+	requireAssetEvent(t, chaincodeStub, 0, chaincode.AssetEvent{
+		Before: tombstone, ID: "asset1", SchemaVersion: 1, Type: "AssetPurged",
+	})
+}
+
+func TestDeletionCannotBeSet(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	chaincodeStub.GetStateReturns(assetJSON(t, &chaincode.Asset{ID: "asset1"}), nil)
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	assetTransfer := chaincode.SmartContract{SoftDelete: true}
+
+	err := assetTransfer.PatchAsset(transactionContext, "asset1", `{"DeletedBy":"Tomoko"}`)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the deletion of asset asset1 is maintained by the contract and cannot be set")
+
+	err = assetTransfer.UpdateAssets(transactionContext, []chaincode.Asset{{ID: "asset1", DeletedAt: deletedAt}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "the deletion of asset asset1 is maintained by the contract and cannot be set")
+	require.Zero(t, chaincodeStub.PutStateCallCount())
+}
+
+func txTimestamp(t *testing.T, value string) *timestamp.Timestamp {
+	ts, err := time.Parse(time.RFC3339, value)
+	require.NoError(t, err)
+	protoTimestamp, err := ptypes.TimestampProto(ts)
+	require.NoError(t, err)
+
+	return protoTimestamp
+}
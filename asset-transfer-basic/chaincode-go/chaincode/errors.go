@@ -0,0 +1,136 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// ErrorCode classifies the errors returned by the contract, so that clients can handle them
+// without matching on the message. The codes are named after the gRPC canonical codes.
+// See ERRORS.md at the root of asset-transfer-basic for the documented codes.
+type ErrorCode string
+
+// Error codes returned by the contract
+const (
+	ErrorCodeNotFound           ErrorCode = "NOT_FOUND"
+	ErrorCodeAlreadyExists      ErrorCode = "ALREADY_EXISTS"
+	ErrorCodeInvalidArgument    ErrorCode = "INVALID_ARGUMENT"
+	ErrorCodePermissionDenied   ErrorCode = "PERMISSION_DENIED"
+	ErrorCodeFailedPrecondition ErrorCode = "FAILED_PRECONDITION"
+	ErrorCodeAborted            ErrorCode = "ABORTED"
+	ErrorCodeUnknown            ErrorCode = "UNKNOWN"
+)
+
+// maxAssetIDLength is the maximum number of characters of an asset ID
+const maxAssetIDLength = 64
+
+// assetIDPattern restricts asset IDs to characters that are safe to use in keys and URLs
+var assetIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ContractError is returned when a request is rejected by the contract. Its message is the
+// JSON encoding of the error, e.g. {"code":"NOT_FOUND","ID":"asset1","message":"..."}, which
+// clients can parse back into a ContractError.
+// Errors that are not ContractErrors, such as failures to access the world state, are
+// returned as plain text.
+type ContractError struct {
+	Code    ErrorCode `json:"code"`
+	ID      string    `json:"ID,omitempty"`
+	Message string    `json:"message"`
+}
+
+// Error returns the JSON encoding of the contract error
+func (e *ContractError) Error() string {
+	return encodeError(e, e.Message)
+}
+
+// newContractError returns a ContractError about the asset with given id, which may be empty
+func newContractError(code ErrorCode, id string, format string, args ...interface{}) *ContractError {
+	return &ContractError{Code: code, ID: id, Message: fmt.Sprintf(format, args...)}
+}
+
+func notFoundError(id string) *ContractError {
+	return newContractError(ErrorCodeNotFound, id, "the asset %s does not exist", id)
+}
+
+func alreadyExistsError(id string) *ContractError {
+	return newContractError(ErrorCodeAlreadyExists, id, "the asset %s already exists", id)
+}
+
+// ownerChangeError is returned when the owner of an asset is changed other than by a
+// transfer while owner authorization is enabled
+func ownerChangeError(id string) *ContractError {
+	return newContractError(ErrorCodeInvalidArgument, id, "the owner of asset %s can only be changed by TransferAsset", id)
+}
+
+func newOwnerEmptyError(id string) *ContractError {
+	return newContractError(ErrorCodeInvalidArgument, id, "the new owner of asset %s must not be empty", id)
+}
+
+// validateAssetID returns an INVALID_ARGUMENT error if id is empty. Only the IDs of new assets
+// are restricted further, so that the assets created before can still be read and deleted.
+func validateAssetID(id string) error {
+	if id == "" {
+		return newContractError(ErrorCodeInvalidArgument, id, "the asset ID must not be empty")
+	}
+
+	return nil
+}
+
+// validateNewAssetID returns an INVALID_ARGUMENT error unless id is a valid ID for a new asset
+func validateNewAssetID(id string) error {
+	err := validateAssetID(id)
+	if err != nil {
+		return err
+	}
+	if len(id) > maxAssetIDLength {
+		return newContractError(ErrorCodeInvalidArgument, id, "the asset ID must not be longer than %d characters", maxAssetIDLength)
+	}
+	if !assetIDPattern.MatchString(id) {
+		return newContractError(ErrorCodeInvalidArgument, id, "the asset ID %s must only contain letters, digits, '-', '_' and '.'", id)
+	}
+
+	return nil
+}
+
+// validateNewAsset returns an INVALID_ARGUMENT error unless the asset is valid and has a valid
+// ID for a new asset
+func validateNewAsset(asset *Asset) error {
+	err := validateNewAssetID(asset.ID)
+	if err != nil {
+		return err
+	}
+
+	return validateAsset(asset)
+}
+
+// validateAsset returns an INVALID_ARGUMENT error unless the asset has an ID, a
+// non-negative size and appraised value, and is not marked as deleted
+func validateAsset(asset *Asset) error {
+	err := validateAssetID(asset.ID)
+	if err != nil {
+		return err
+	}
+	if asset.isDeleted() {
+		return newContractError(ErrorCodeInvalidArgument, asset.ID, "the deletion of asset %s is maintained by the contract and cannot be set", asset.ID)
+	}
+	if asset.Size < 0 {
+		return newContractError(ErrorCodeInvalidArgument, asset.ID, "the size of asset %s must not be negative", asset.ID)
+	}
+	if asset.AppraisedValue < 0 {
+		return newContractError(ErrorCodeInvalidArgument, asset.ID, "the appraised value of asset %s must not be negative", asset.ID)
+	}
+
+	return nil
+}
+
+// encodeError returns the JSON encoding of a structured error, falling back to message
+// if the error cannot be encoded
+func encodeError(e interface{}, message string) string {
+	errorJSON, err := json.Marshal(e)
+	if err != nil {
+		return message
+	}
+
+	return string(errorJSON)
+}
@@ -0,0 +1,110 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContractErrorJSON(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	assetTransfer := chaincode.SmartContract{}
+	_, err := assetTransfer.ReadAsset(transactionContext, "asset1")
+	require.EqualError(t, err, `{"code":"NOT_FOUND","ID":"asset1","message":"the asset asset1 does not exist"}`)
+
+	var decoded chaincode.ContractError
+	require.NoError(t, json.Unmarshal([]byte(err.Error()), &decoded))
+	require.Equal(t, chaincode.ContractError{Code: chaincode.ErrorCodeNotFound, ID: "asset1", Message: "the asset asset1 does not exist"}, decoded)
+
+	err = &chaincode.VersionConflictError{ID: "asset1", ExpectedVersion: 1, CurrentVersion: 2}
+	require.JSONEq(t, `{
+		"code": "ABORTED",
+		"ID": "asset1",
+		"message": "the asset asset1 was modified, expected version 1 but found version 2",
+		"expectedVersion": 1,
+		"currentVersion": 2
+	}`, err.Error())
+}
+
+func TestAssetValidation(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	assetTransfer := chaincode.SmartContract{}
+
+	err := assetTransfer.CreateAsset(transactionContext, "", "blue", 5, "Tomoko", 300)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the asset ID must not be empty")
+
+	err = assetTransfer.CreateAsset(transactionContext, "asset 1", "blue", 5, "Tomoko", 300)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the asset ID asset 1 must only contain letters, digits, '-', '_' and '.'")
+
+	err = assetTransfer.CreateAsset(transactionContext, strings.Repeat("a", 65), "blue", 5, "Tomoko", 300)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the asset ID must not be longer than 64 characters")
+
+	err = assetTransfer.CreateAsset(transactionContext, "asset1", "blue", -5, "Tomoko", 300)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the size of asset asset1 must not be negative")
+
+	err = assetTransfer.UpdateAsset(transactionContext, "asset1", "blue", 5, "Tomoko", -300)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the appraised value of asset asset1 must not be negative")
+
+	err = assetTransfer.TransferAsset(transactionContext, "asset1", "")
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the new owner of asset asset1 must not be empty")
+
+	_, err = assetTransfer.AssetExists(transactionContext, "")
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the asset ID must not be empty")
+
+	require.Zero(t, chaincodeStub.GetStateCallCount())
+	require.Zero(t, chaincodeStub.PutStateCallCount())
+
+	err = assetTransfer.CreateAsset(transactionContext, "ASSET-1_a.b", "blue", 5, "Tomoko", 300)
+	require.NoError(t, err)
+}
+
+// The assets created before asset IDs were restricted can still be read, updated, transferred and deleted
+func TestLegacyAssetID(t *testing.T) {
+	for _, id := range []string{"asset 1", "asset:1", strings.Repeat("a", 65)} {
+		asset := &chaincode.Asset{ID: id, Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 300}
+		state := map[string][]byte{id: assetJSON(t, asset)}
+		transactionContext, chaincodeStub := prepOwnerMocks(t, "Tomoko", state)
+		chaincodeStub.PutStateStub = func(key string, value []byte) error {
+			state[key] = value
+			return nil
+		}
+		chaincodeStub.DelStateStub = func(key string) error {
+			delete(state, key)
+			return nil
+		}
+		assetTransfer := chaincode.SmartContract{}
+
+		read, err := assetTransfer.ReadAsset(transactionContext, id)
+		require.NoError(t, err)
+		require.Equal(t, asset, read)
+
+		err = assetTransfer.UpdateAsset(transactionContext, id, "red", 5, "Tomoko", 300)
+		require.NoError(t, err)
+		err = assetTransfer.TransferAsset(transactionContext, id, "Brad")
+		require.NoError(t, err)
+		err = assetTransfer.DeleteAsset(transactionContext, id)
+		require.NoError(t, err)
+
+		exists, err := assetTransfer.AssetExists(transactionContext, id)
+		require.NoError(t, err)
+		require.False(t, exists)
+	}
+}
+
+// requireContractError asserts that err is a ContractError with the given code and message
+func requireContractError(t *testing.T, err error, code chaincode.ErrorCode, message string) {
+	var contractErr *chaincode.ContractError
+	require.True(t, errors.As(err, &contractErr), "expected a ContractError, got %v", err)
+	require.Equal(t, code, contractErr.Code)
+	require.Equal(t, message, contractErr.Message)
+}
@@ -0,0 +1,90 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// AssetEventSchemaVersion is the version of the AssetEvent payload. It is incremented
+// whenever a field is removed or changes meaning, new fields may be added without a bump.
+// See EVENTS.md at the root of asset-transfer-basic for the documented schema.
+const AssetEventSchemaVersion = 1
+
+// Names of the chaincode events emitted on asset lifecycle changes
+const (
+	AssetCreatedEvent     = "AssetCreated"
+	AssetUpdatedEvent     = "AssetUpdated"
+	AssetTransferredEvent = "AssetTransferred"
+	AssetDeletedEvent     = "AssetDeleted"
+	AssetRestoredEvent    = "AssetRestored"
+	AssetPurgedEvent      = "AssetPurged"
+	AssetBatchEvent       = "AssetBatch"
+)
+
+// AssetEvent is the payload of the event emitted when a single asset changes.
+// Before is omitted for AssetCreated and After is omitted for AssetDeleted.
+type AssetEvent struct {
+	After         *Asset `json:"after,omitempty"`
+	Before        *Asset `json:"before,omitempty"`
+	ID            string `json:"ID"`
+	SchemaVersion int    `json:"schemaVersion"`
+	TxID          string `json:"txId"`
+	Type          string `json:"type"`
+}
+
+// AssetBatchEventPayload is the payload of the event emitted by the batch operations.
+// A transaction can only emit one chaincode event, so every change made by the batch
+// is reported in Events, in the order of the batch.
+type AssetBatchEventPayload struct {
+	Events        []AssetEvent `json:"events"`
+	SchemaVersion int          `json:"schemaVersion"`
+	TxID          string       `json:"txId"`
+}
+
+// newAssetEvent returns the event describing a change of an asset from before to after
+func newAssetEvent(ctx contractapi.TransactionContextInterface, eventType string, before *Asset, after *Asset) AssetEvent {
+	event := AssetEvent{
+		After:         after,
+		Before:        before,
+		SchemaVersion: AssetEventSchemaVersion,
+		TxID:          ctx.GetStub().GetTxID(),
+		Type:          eventType,
+	}
+	if after != nil {
+		event.ID = after.ID
+	} else if before != nil {
+		event.ID = before.ID
+	}
+
+	return event
+}
+
+// emitAssetEvent sets the chaincode event of the transaction to the change of an asset
+func emitAssetEvent(ctx contractapi.TransactionContextInterface, eventType string, before *Asset, after *Asset) error {
+	return setEvent(ctx, eventType, newAssetEvent(ctx, eventType, before, after))
+}
+
+// emitAssetBatchEvent sets the chaincode event of the transaction to the changes made by a batch
+func emitAssetBatchEvent(ctx contractapi.TransactionContextInterface, events []AssetEvent) error {
+	return setEvent(ctx, AssetBatchEvent, AssetBatchEventPayload{
+		Events:        events,
+		SchemaVersion: AssetEventSchemaVersion,
+		TxID:          ctx.GetStub().GetTxID(),
+	})
+}
+
+func setEvent(ctx contractapi.TransactionContextInterface, name string, payload interface{}) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent(name, payloadJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
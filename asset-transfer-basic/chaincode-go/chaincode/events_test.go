@@ -0,0 +1,94 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssetLifecycleEvents(t *testing.T) {
+	created := &chaincode.Asset{ID: "asset1", Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 300, LastTxID: "tx1", Version: 1}
+	asset := &chaincode.Asset{ID: "asset1", Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 300, LastTxID: "tx0", Version: 1}
+	updated := &chaincode.Asset{ID: "asset1", Color: "red", Size: 10, Owner: "Tomoko", AppraisedValue: 500, LastTxID: "tx1", Version: 2}
+	transferred := &chaincode.Asset{ID: "asset1", Color: "blue", Size: 5, Owner: "Brad", AppraisedValue: 300, LastTxID: "tx1", Version: 2}
+
+	chaincodeStub := &mocks.ChaincodeStub{}
+	chaincodeStub.GetTxIDReturns("tx1")
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	assetTransfer := chaincode.SmartContract{}
+
+	err := assetTransfer.CreateAsset(transactionContext, "asset1", "blue", 5, "Tomoko", 300)
+	require.NoError(t, err)
+	requireAssetEvent(t, chaincodeStub, 0, chaincode.AssetEvent{
+		After: created, ID: "asset1", SchemaVersion: 1, TxID: "tx1", Type: "AssetCreated",
+	})
+
+	chaincodeStub.GetStateReturns(assetJSON(t, asset), nil)
+	err = assetTransfer.UpdateAsset(transactionContext, "asset1", "red", 10, "Tomoko", 500)
+	require.NoError(t, err)
+	requireAssetEvent(t, chaincodeStub, 1, chaincode.AssetEvent{
+		After: updated, Before: asset, ID: "asset1", SchemaVersion: 1, TxID: "tx1", Type: "AssetUpdated",
+	})
+
+	err = assetTransfer.TransferAsset(transactionContext, "asset1", "Brad")
+	require.NoError(t, err)
+	requireAssetEvent(t, chaincodeStub, 2, chaincode.AssetEvent{
+		After: transferred, Before: asset, ID: "asset1", SchemaVersion: 1, TxID: "tx1", Type: "AssetTransferred",
+	})
+
+	err = assetTransfer.DeleteAsset(transactionContext, "asset1")
+	require.NoError(t, err)
+	requireAssetEvent(t, chaincodeStub, 3, chaincode.AssetEvent{
+		Before: asset, ID: "asset1", SchemaVersion: 1, TxID: "tx1", Type: "AssetDeleted",
+	})
+
+	chaincodeStub.SetEventReturns(fmt.Errorf("event too large"))
+	err = assetTransfer.DeleteAsset(transactionContext, "asset1")
+	require.EqualError(t, err, "failed to set event: event too large")
+}
+
+func TestAssetBatchEvent(t *testing.T) {
+	asset := &chaincode.Asset{ID: "asset1", Owner: "Tomoko"}
+
+	chaincodeStub := &mocks.ChaincodeStub{}
+	chaincodeStub.GetTxIDReturns("tx1")
+	chaincodeStub.GetStateReturns(assetJSON(t, asset), nil)
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	assetTransfer := chaincode.SmartContract{}
+	err := assetTransfer.TransferAssets(transactionContext, []chaincode.AssetTransfer{{ID: "asset1", NewOwner: "Brad"}})
+	require.NoError(t, err)
+
+	require.Equal(t, 1, chaincodeStub.SetEventCallCount())
+	name, payload := chaincodeStub.SetEventArgsForCall(0)
+	require.Equal(t, "AssetBatch", name)
+	var event chaincode.AssetBatchEventPayload
+	require.NoError(t, json.Unmarshal(payload, &event))
+	require.Equal(t, chaincode.AssetBatchEventPayload{
+		Events: []chaincode.AssetEvent{{
+			After:         &chaincode.Asset{ID: "asset1", Owner: "Brad", LastTxID: "tx1", Version: 1},
+			Before:        asset,
+			ID:            "asset1",
+			SchemaVersion: 1,
+			TxID:          "tx1",
+			Type:          "AssetTransferred",
+		}},
+		SchemaVersion: 1,
+		TxID:          "tx1",
+	}, event)
+}
+
+func requireAssetEvent(t *testing.T, chaincodeStub *mocks.ChaincodeStub, call int, expected chaincode.AssetEvent) {
+	name, payload := chaincodeStub.SetEventArgsForCall(call)
+	require.Equal(t, expected.Type, name)
+
+	var event chaincode.AssetEvent
+	require.NoError(t, json.Unmarshal(payload, &event))
+	require.Equal(t, expected, event)
+}
@@ -0,0 +1,149 @@
+package chaincode
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const approvalPrefix = "owner~operator"
+
+// GetSubmittingClientIdentity returns the name and issuer of the identity that
+// invokes the smart contract. This function base64 decodes the identity string
+// before returning the value to the client or smart contract.
+// When owner authorization is enabled, this is the value stored as the Owner of an asset.
+func (s *SmartContract) GetSubmittingClientIdentity(ctx contractapi.TransactionContextInterface) (string, error) {
+	b64ID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to read clientID: %v", err)
+	}
+	decodeID, err := base64.StdEncoding.DecodeString(b64ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to base64 decode clientID: %v", err)
+	}
+	return string(decodeID), nil
+}
+
+// SetApprovalForAll approves, or revokes the approval of, an operator to update, transfer
+// and delete every asset owned by the submitting client. The operator is identified by the
+// value GetSubmittingClientIdentity returns for it.
+func (s *SmartContract) SetApprovalForAll(ctx contractapi.TransactionContextInterface, operator string, approved bool) error {
+	owner, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if operator == "" {
+		return newContractError(ErrorCodeInvalidArgument, "", "the operator must not be empty")
+	}
+	if owner == operator {
+		return newContractError(ErrorCodeInvalidArgument, "", "setting approval status for self")
+	}
+
+	approvalKey, err := ctx.GetStub().CreateCompositeKey(approvalPrefix, []string{owner, operator})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", approvalPrefix, err)
+	}
+
+	if !approved {
+		return ctx.GetStub().DelState(approvalKey)
+	}
+
+	approvalJSON, err := json.Marshal(approved)
+	if err != nil {
+		return fmt.Errorf("failed to encode approval JSON of operator %s for owner %s: %v", operator, owner, err)
+	}
+
+	return ctx.GetStub().PutState(approvalKey, approvalJSON)
+}
+
+// IsApprovedForAll returns true if operator is approved to act on every asset of owner.
+func (s *SmartContract) IsApprovedForAll(ctx contractapi.TransactionContextInterface, owner string, operator string) (bool, error) {
+	approvalKey, err := ctx.GetStub().CreateCompositeKey(approvalPrefix, []string{owner, operator})
+	if err != nil {
+		return false, fmt.Errorf("failed to create the composite key for prefix %s: %v", approvalPrefix, err)
+	}
+
+	approvalBytes, err := ctx.GetStub().GetState(approvalKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read approval of operator %s for owner %s from world state: %v", operator, owner, err)
+	}
+
+	if approvalBytes == nil {
+		return false, nil
+	}
+
+	var approved bool
+	err = json.Unmarshal(approvalBytes, &approved)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode approval JSON of operator %s for owner %s: %v", operator, owner, err)
+	}
+
+	return approved, nil
+}
+
+// bindOwner returns the owner to record for a new asset. When owner authorization is
+// disabled the requested owner is returned as is, otherwise the asset is bound to the
+// submitting client, and a requested owner other than the client is rejected.
+func (s *SmartContract) bindOwner(ctx contractapi.TransactionContextInterface, owner string) (string, error) {
+	if !s.OwnerAuthorization {
+		return owner, nil
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if owner != "" && owner != clientID {
+		return "", newContractError(ErrorCodePermissionDenied, "", "the owner of a new asset must be the submitting client")
+	}
+
+	return clientID, nil
+}
+
+// authorizeOwner returns an error unless owner authorization is disabled, or the submitting
+// client is either the owner of the asset or an operator approved by the owner.
+func (s *SmartContract) authorizeOwner(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	if !s.OwnerAuthorization {
+		return nil
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if clientID == asset.Owner {
+		return nil
+	}
+
+	approved, err := s.IsApprovedForAll(ctx, asset.Owner, clientID)
+	if err != nil {
+		return err
+	}
+	if !approved {
+		return newContractError(ErrorCodePermissionDenied, asset.ID, "submitting client not authorized to update asset %s, does not own asset", asset.ID)
+	}
+
+	return nil
+}
+
+// requireAdmin returns an error unless the submitting client belongs to the admin organization
+func (s *SmartContract) requireAdmin(ctx contractapi.TransactionContextInterface) error {
+	if s.AdminMSPID == "" {
+		return newContractError(ErrorCodePermissionDenied, "", "admin operations are disabled, no admin MSP ID is configured")
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to read client MSP ID: %v", err)
+	}
+	if mspID != s.AdminMSPID {
+		return newContractError(ErrorCodePermissionDenied, "", "submitting client of MSP %s is not an admin", mspID)
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package chaincode_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+const ownerClientID = "x509::CN=owner,OU=client::CN=ca.org1.example.com"
+const operatorClientID = "x509::CN=operator,OU=client::CN=ca.org1.example.com"
+const otherClientID = "x509::CN=other,OU=client::CN=ca.org2.example.com"
+
+func TestGetSubmittingClientIdentity(t *testing.T) {
+	transactionContext, _ := prepOwnerMocks(t, ownerClientID, nil)
+
+	assetTransfer := chaincode.SmartContract{}
+	clientID, err := assetTransfer.GetSubmittingClientIdentity(transactionContext)
+	require.NoError(t, err)
+	require.Equal(t, ownerClientID, clientID)
+
+	clientIdentity := &mocks.ClientIdentity{}
+	clientIdentity.GetIDReturns("not base64", nil)
+	transactionContext.GetClientIdentityReturns(clientIdentity)
+	_, err = assetTransfer.GetSubmittingClientIdentity(transactionContext)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to base64 decode clientID")
+
+	clientIdentity.GetIDReturns("", fmt.Errorf("no identity"))
+	_, err = assetTransfer.GetSubmittingClientIdentity(transactionContext)
+	require.EqualError(t, err, "failed to read clientID: no identity")
+}
+
+func TestSetApprovalForAll(t *testing.T) {
+	transactionContext, chaincodeStub := prepOwnerMocks(t, ownerClientID, nil)
+
+	assetTransfer := chaincode.SmartContract{}
+	err := assetTransfer.SetApprovalForAll(transactionContext, operatorClientID, true)
+	require.NoError(t, err)
+	objectType, attributes := chaincodeStub.CreateCompositeKeyArgsForCall(0)
+	require.Equal(t, "owner~operator", objectType)
+	require.Equal(t, []string{ownerClientID, operatorClientID}, attributes)
+	key, value := chaincodeStub.PutStateArgsForCall(0)
+	require.Equal(t, approvalKey(ownerClientID, operatorClientID), key)
+	require.Equal(t, []byte("true"), value)
+
+	err = assetTransfer.SetApprovalForAll(transactionContext, operatorClientID, false)
+	require.NoError(t, err)
+	require.Equal(t, approvalKey(ownerClientID, operatorClientID), chaincodeStub.DelStateArgsForCall(0))
+
+	err = assetTransfer.SetApprovalForAll(transactionContext, ownerClientID, true)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "setting approval status for self")
+}
+
+func TestIsApprovedForAll(t *testing.T) {
+	transactionContext, _ := prepOwnerMocks(t, ownerClientID, map[string][]byte{
+		approvalKey(ownerClientID, operatorClientID): []byte("true"),
+	})
+
+	assetTransfer := chaincode.SmartContract{}
+	approved, err := assetTransfer.IsApprovedForAll(transactionContext, ownerClientID, operatorClientID)
+	require.NoError(t, err)
+	require.True(t, approved)
+
+	approved, err = assetTransfer.IsApprovedForAll(transactionContext, ownerClientID, otherClientID)
+	require.NoError(t, err)
+	require.False(t, approved)
+}
+
+func TestOwnerAuthorizationCreateAsset(t *testing.T) {
+	transactionContext, chaincodeStub := prepOwnerMocks(t, ownerClientID, nil)
+
+	assetTransfer := chaincode.SmartContract{OwnerAuthorization: true}
+	err := assetTransfer.CreateAsset(transactionContext, "asset1", "blue", 5, "", 300)
+	require.NoError(t, err)
+	_, value := chaincodeStub.PutStateArgsForCall(0)
+	var asset chaincode.Asset
+	require.NoError(t, json.Unmarshal(value, &asset))
+	require.Equal(t, ownerClientID, asset.Owner)
+
+	err = assetTransfer.CreateAsset(transactionContext, "asset1", "blue", 5, ownerClientID, 300)
+	require.NoError(t, err)
+
+	err = assetTransfer.CreateAsset(transactionContext, "asset1", "blue", 5, "Tomoko", 300)
+	requireContractError(t, err, chaincode.ErrorCodePermissionDenied, "the owner of a new asset must be the submitting client")
+}
+
+func TestOwnerAuthorizationTransferAsset(t *testing.T) {
+	state := map[string][]byte{
+		"asset1": assetJSON(t, &chaincode.Asset{ID: "asset1", Owner: ownerClientID}),
+		approvalKey(ownerClientID, operatorClientID): []byte("true"),
+	}
+	assetTransfer := chaincode.SmartContract{OwnerAuthorization: true}
+
+	transactionContext, chaincodeStub := prepOwnerMocks(t, ownerClientID, state)
+	err := assetTransfer.TransferAsset(transactionContext, "asset1", otherClientID)
+	require.NoError(t, err)
+	require.Equal(t, 1, chaincodeStub.PutStateCallCount())
+
+	transactionContext, chaincodeStub = prepOwnerMocks(t, operatorClientID, state)
+	err = assetTransfer.TransferAsset(transactionContext, "asset1", otherClientID)
+	require.NoError(t, err)
+	require.Equal(t, 1, chaincodeStub.PutStateCallCount())
+
+	transactionContext, chaincodeStub = prepOwnerMocks(t, otherClientID, state)
+	err = assetTransfer.TransferAsset(transactionContext, "asset1", otherClientID)
+	requireContractError(t, err, chaincode.ErrorCodePermissionDenied, "submitting client not authorized to update asset asset1, does not own asset")
+	require.Zero(t, chaincodeStub.PutStateCallCount())
+
+	assetTransfer.OwnerAuthorization = false
+	err = assetTransfer.TransferAsset(transactionContext, "asset1", otherClientID)
+	require.NoError(t, err)
+}
+
+func TestOwnerAuthorizationUpdateAsset(t *testing.T) {
+	state := map[string][]byte{
+		"asset1": assetJSON(t, &chaincode.Asset{ID: "asset1", Owner: ownerClientID}),
+	}
+	assetTransfer := chaincode.SmartContract{OwnerAuthorization: true}
+
+	transactionContext, chaincodeStub := prepOwnerMocks(t, ownerClientID, state)
+	err := assetTransfer.UpdateAsset(transactionContext, "asset1", "red", 10, ownerClientID, 500)
+	require.NoError(t, err)
+	require.Equal(t, 1, chaincodeStub.PutStateCallCount())
+
+	err = assetTransfer.UpdateAsset(transactionContext, "asset1", "red", 10, otherClientID, 500)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the owner of asset asset1 can only be changed by TransferAsset")
+
+	transactionContext, chaincodeStub = prepOwnerMocks(t, otherClientID, state)
+	err = assetTransfer.UpdateAsset(transactionContext, "asset1", "red", 10, ownerClientID, 500)
+	requireContractError(t, err, chaincode.ErrorCodePermissionDenied, "submitting client not authorized to update asset asset1, does not own asset")
+	require.Zero(t, chaincodeStub.PutStateCallCount())
+}
+
+func TestOwnerAuthorizationDeleteAsset(t *testing.T) {
+	state := map[string][]byte{
+		"asset1": assetJSON(t, &chaincode.Asset{ID: "asset1", Owner: ownerClientID}),
+	}
+	assetTransfer := chaincode.SmartContract{OwnerAuthorization: true}
+
+	transactionContext, chaincodeStub := prepOwnerMocks(t, otherClientID, state)
+	err := assetTransfer.DeleteAsset(transactionContext, "asset1")
+	requireContractError(t, err, chaincode.ErrorCodePermissionDenied, "submitting client not authorized to update asset asset1, does not own asset")
+	require.Zero(t, chaincodeStub.DelStateCallCount())
+
+	transactionContext, chaincodeStub = prepOwnerMocks(t, ownerClientID, state)
+	err = assetTransfer.DeleteAsset(transactionContext, "asset1")
+	require.NoError(t, err)
+	require.Equal(t, "asset1", chaincodeStub.DelStateArgsForCall(0))
+}
+
+func TestOwnerAuthorizationTransferAssets(t *testing.T) {
+	state := map[string][]byte{
+		"asset1": assetJSON(t, &chaincode.Asset{ID: "asset1", Owner: ownerClientID}),
+		"asset2": assetJSON(t, &chaincode.Asset{ID: "asset2", Owner: otherClientID}),
+	}
+	assetTransfer := chaincode.SmartContract{OwnerAuthorization: true}
+
+	transactionContext, chaincodeStub := prepOwnerMocks(t, ownerClientID, state)
+	err := assetTransfer.TransferAssets(transactionContext, []chaincode.AssetTransfer{
+		{ID: "asset1", NewOwner: operatorClientID},
+		{ID: "asset2", NewOwner: operatorClientID},
+	})
+	var batchErr *chaincode.BatchError
+	require.True(t, errors.As(err, &batchErr))
+	require.Equal(t, []chaincode.BatchItemError{
+		{Index: 1, ID: "asset2", Code: chaincode.ErrorCodePermissionDenied, Message: "submitting client not authorized to update asset asset2, does not own asset"},
+	}, batchErr.Errors)
+	require.Zero(t, chaincodeStub.PutStateCallCount())
+}
+
+func TestOwnerAuthorizationUpdateAssets(t *testing.T) {
+	state := map[string][]byte{
+		"asset1": assetJSON(t, &chaincode.Asset{ID: "asset1", Owner: ownerClientID}),
+		"asset2": assetJSON(t, &chaincode.Asset{ID: "asset2", Owner: ownerClientID}),
+	}
+	assetTransfer := chaincode.SmartContract{OwnerAuthorization: true}
+
+	transactionContext, chaincodeStub := prepOwnerMocks(t, ownerClientID, state)
+	err := assetTransfer.UpdateAssets(transactionContext, []chaincode.Asset{
+		{ID: "asset1", Color: "red", Owner: ownerClientID},
+		{ID: "asset2", Color: "red", Owner: otherClientID},
+	})
+	var batchErr *chaincode.BatchError
+	require.True(t, errors.As(err, &batchErr))
+	require.Equal(t, []chaincode.BatchItemError{
+		{Index: 1, ID: "asset2", Code: chaincode.ErrorCodeInvalidArgument, Message: "the owner of asset asset2 can only be changed by TransferAsset"},
+	}, batchErr.Errors)
+	require.Zero(t, chaincodeStub.PutStateCallCount())
+}
+
+// prepOwnerMocks returns mocks for a client with the given identity, backed by the given world state
+func prepOwnerMocks(t *testing.T, clientID string, state map[string][]byte) (*mocks.TransactionContext, *mocks.ChaincodeStub) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	chaincodeStub.GetStateStub = func(key string) ([]byte, error) {
+		return state[key], nil
+	}
+	chaincodeStub.CreateCompositeKeyStub = func(objectType string, attributes []string) (string, error) {
+		return approvalKey(attributes...), nil
+	}
+
+	clientIdentity := &mocks.ClientIdentity{}
+	clientIdentity.GetIDReturns(base64.StdEncoding.EncodeToString([]byte(clientID)), nil)
+
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	transactionContext.GetClientIdentityReturns(clientIdentity)
+
+	return transactionContext, chaincodeStub
+}
+
+func approvalKey(attributes ...string) string {
+	return fmt.Sprintf("approval%v", attributes)
+}
+
+func assetJSON(t *testing.T, asset *chaincode.Asset) []byte {
+	bytes, err := json.Marshal(asset)
+	require.NoError(t, err)
+
+	return bytes
+}
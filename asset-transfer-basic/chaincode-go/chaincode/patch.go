@@ -0,0 +1,125 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// PatchAsset applies a JSON merge patch (RFC 7386) to the asset stored in the world state
+// with given id, so that clients can change some fields without re-sending the others.
+// Fields set to null in the patch are reset to their zero value. The patch is rejected if it
+// contains unknown fields, changes the ID, or leaves a negative Size or AppraisedValue.
+func (s *SmartContract) PatchAsset(ctx contractapi.TransactionContextInterface, id string, patch string) error {
+	existing, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.authorizeOwner(ctx, existing)
+	if err != nil {
+		return err
+	}
+
+	asset, err := applyAssetPatch(existing, []byte(patch))
+	if err != nil {
+		return err
+	}
+	if s.OwnerAuthorization && asset.Owner != existing.Owner {
+		return ownerChangeError(id)
+	}
+
+	err = putAsset(ctx, asset, existing)
+	if err != nil {
+		return err
+	}
+
+	return emitAssetEvent(ctx, AssetUpdatedEvent, existing, asset)
+}
+
+// applyAssetPatch returns the asset resulting from applying the JSON merge patch to asset
+func applyAssetPatch(asset *Asset, patch []byte) (*Asset, error) {
+	patchDoc, err := decodeJSON(patch)
+	if err != nil {
+		return nil, newContractError(ErrorCodeInvalidArgument, asset.ID, "failed to parse patch: %v", err)
+	}
+	if _, ok := patchDoc.(map[string]interface{}); !ok {
+		return nil, newContractError(ErrorCodeInvalidArgument, asset.ID, "the patch must be a JSON object")
+	}
+
+	assetJSON, err := json.Marshal(asset)
+	if err != nil {
+		return nil, err
+	}
+	target, err := decodeJSON(assetJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	patchedJSON, err := json.Marshal(mergePatch(target, patchDoc))
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(patchedJSON))
+	decoder.DisallowUnknownFields()
+	var patched Asset
+	err = decoder.Decode(&patched)
+	if err != nil {
+		return nil, newContractError(ErrorCodeInvalidArgument, asset.ID, "invalid patch: %v", err)
+	}
+
+	if patched.ID != asset.ID {
+		return nil, newContractError(ErrorCodeInvalidArgument, asset.ID, "the ID of asset %s cannot be changed", asset.ID)
+	}
+	if patched.Version != asset.Version || patched.LastTxID != asset.LastTxID {
+		return nil, newContractError(ErrorCodeInvalidArgument, asset.ID, "the version of asset %s is maintained by the contract and cannot be patched", asset.ID)
+	}
+	err = validateAsset(&patched)
+	if err != nil {
+		return nil, err
+	}
+
+	return &patched, nil
+}
+
+// mergePatch implements the MergePatch function of RFC 7386 on decoded JSON documents
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergePatch(targetObject[name], value)
+		}
+	}
+
+	return targetObject
+}
+
+// decodeJSON decodes a JSON document keeping numbers in their original representation
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc interface{}
+	err := decoder.Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after JSON document")
+	}
+
+	return doc, nil
+}
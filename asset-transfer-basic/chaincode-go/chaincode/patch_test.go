@@ -0,0 +1,102 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatchAsset(t *testing.T) {
+	asset := &chaincode.Asset{ID: "asset1", Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 300, LastTxID: "tx0", Version: 3}
+
+	chaincodeStub := &mocks.ChaincodeStub{}
+	chaincodeStub.GetTxIDReturns("tx1")
+	chaincodeStub.GetStateReturns(assetJSON(t, asset), nil)
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	assetTransfer := chaincode.SmartContract{}
+
+	err := assetTransfer.PatchAsset(transactionContext, "asset1", `{"AppraisedValue":350}`)
+	require.NoError(t, err)
+	require.Equal(t, &chaincode.Asset{ID: "asset1", Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 350, LastTxID: "tx1", Version: 4}, putAssetArg(t, chaincodeStub, 0))
+
+	err = assetTransfer.PatchAsset(transactionContext, "asset1", `{"Color":null,"Owner":"Brad","ID":"asset1"}`)
+	require.NoError(t, err)
+	require.Equal(t, &chaincode.Asset{ID: "asset1", Size: 5, Owner: "Brad", AppraisedValue: 300, LastTxID: "tx1", Version: 4}, putAssetArg(t, chaincodeStub, 1))
+
+	err = assetTransfer.PatchAsset(transactionContext, "asset1", `{"Version":3}`)
+	require.NoError(t, err)
+	require.Equal(t, &chaincode.Asset{ID: "asset1", Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 300, LastTxID: "tx1", Version: 4}, putAssetArg(t, chaincodeStub, 2))
+
+	name, _ := chaincodeStub.SetEventArgsForCall(0)
+	require.Equal(t, "AssetUpdated", name)
+
+	err = assetTransfer.PatchAsset(transactionContext, "asset1", `{"ID":"asset2"}`)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the ID of asset asset1 cannot be changed")
+
+	err = assetTransfer.PatchAsset(transactionContext, "asset1", `{"ID":null}`)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the ID of asset asset1 cannot be changed")
+
+	err = assetTransfer.PatchAsset(transactionContext, "asset1", `{"Version":10}`)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the version of asset asset1 is maintained by the contract and cannot be patched")
+
+	err = assetTransfer.PatchAsset(transactionContext, "asset1", `{"Size":-1}`)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the size of asset asset1 must not be negative")
+
+	err = assetTransfer.PatchAsset(transactionContext, "asset1", `{"AppraisedValue":-100}`)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the appraised value of asset asset1 must not be negative")
+
+	err = assetTransfer.PatchAsset(transactionContext, "asset1", `{"Weight":10}`)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, `invalid patch: json: unknown field "Weight"`)
+
+	err = assetTransfer.PatchAsset(transactionContext, "asset1", `{"Size":"big"}`)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid patch")
+
+	err = assetTransfer.PatchAsset(transactionContext, "asset1", `["Size"]`)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the patch must be a JSON object")
+
+	err = assetTransfer.PatchAsset(transactionContext, "asset1", `{"Size":1`)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "failed to parse patch: unexpected EOF")
+
+	require.Equal(t, 3, chaincodeStub.PutStateCallCount())
+
+	chaincodeStub.GetStateReturns(nil, nil)
+	err = assetTransfer.PatchAsset(transactionContext, "asset1", `{}`)
+	requireContractError(t, err, chaincode.ErrorCodeNotFound, "the asset asset1 does not exist")
+
+	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
+	err = assetTransfer.PatchAsset(transactionContext, "asset1", `{}`)
+	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
+}
+
+func TestPatchAssetOwnerAuthorization(t *testing.T) {
+	state := map[string][]byte{
+		"asset1": assetJSON(t, &chaincode.Asset{ID: "asset1", Owner: ownerClientID}),
+	}
+	assetTransfer := chaincode.SmartContract{OwnerAuthorization: true}
+
+	transactionContext, _ := prepOwnerMocks(t, ownerClientID, state)
+	err := assetTransfer.PatchAsset(transactionContext, "asset1", `{"Size":10}`)
+	require.NoError(t, err)
+
+	err = assetTransfer.PatchAsset(transactionContext, "asset1", `{"Owner":"Brad"}`)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the owner of asset asset1 can only be changed by TransferAsset")
+
+	transactionContext, _ = prepOwnerMocks(t, otherClientID, state)
+	err = assetTransfer.PatchAsset(transactionContext, "asset1", `{"Size":10}`)
+	requireContractError(t, err, chaincode.ErrorCodePermissionDenied, "submitting client not authorized to update asset asset1, does not own asset")
+}
+
+func putAssetArg(t *testing.T, chaincodeStub *mocks.ChaincodeStub, call int) *chaincode.Asset {
+	_, value := chaincodeStub.PutStateArgsForCall(call)
+
+	var asset chaincode.Asset
+	require.NoError(t, json.Unmarshal(value, &asset))
+
+	return &asset
+}
@@ -0,0 +1,197 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// initMarkerObjectType is the object type of the composite key recording that the ledger
+// was initialized. Composite keys are not returned by the range queries over assets.
+const initMarkerObjectType = "initLedger"
+
+// maxSyntheticAssets is the maximum number of synthetic assets InitLedgerWithOptions generates
+const maxSyntheticAssets = 1000
+
+// InitLedgerOptions describes the optional seed data of InitLedgerWithOptions. At most one of
+// Assets and Count may be set; when neither is set, or Count is 0, the ledger is seeded with the
+// default assets.
+type InitLedgerOptions struct {
+	Assets []Asset `json:"Assets,omitempty"`
+	Count  int     `json:"Count,omitempty"`
+	Force  bool    `json:"Force,omitempty"`
+}
+
+// initMarker is stored under the initialization marker key when the ledger is seeded
+type initMarker struct {
+	AssetCount int    `json:"AssetCount"`
+	Timestamp  string `json:"Timestamp"`
+	TxID       string `json:"TxID"`
+}
+
+// InitLedgerWithOptions seeds the ledger with the given JSON encoded InitLedgerOptions, either
+// a set of assets, or a count of synthetic assets to generate.
+// Seeding is recorded by an initialization marker, and once the ledger is initialized further
+// calls do nothing. Seeding a ledger that already holds assets is refused, unless Force is set
+// by a client of the admin organization, in which case the seed assets overwrite existing ones.
+func (s *SmartContract) InitLedgerWithOptions(ctx contractapi.TransactionContextInterface, options string) error {
+	var initOptions InitLedgerOptions
+	if options != "" {
+		err := json.Unmarshal([]byte(options), &initOptions)
+		if err != nil {
+			return newContractError(ErrorCodeInvalidArgument, "", "failed to parse init options: %v", err)
+		}
+	}
+
+	return s.initLedger(ctx, &initOptions)
+}
+
+// initLedger seeds the ledger, see InitLedgerWithOptions
+func (s *SmartContract) initLedger(ctx contractapi.TransactionContextInterface, options *InitLedgerOptions) error {
+	assets, err := seedAssets(options)
+	if err != nil {
+		return err
+	}
+
+	markerKey, err := ctx.GetStub().CreateCompositeKey(initMarkerObjectType, []string{})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", initMarkerObjectType, err)
+	}
+
+	if options.Force {
+		err = s.requireAdmin(ctx)
+		if err != nil {
+			return err
+		}
+	} else {
+		markerJSON, err := ctx.GetStub().GetState(markerKey)
+		if err != nil {
+			return fmt.Errorf("failed to read from world state: %v", err)
+		}
+		if markerJSON != nil {
+			return nil
+		}
+
+		empty, err := ledgerIsEmpty(ctx)
+		if err != nil {
+			return err
+		}
+		if !empty {
+			return newContractError(ErrorCodeFailedPrecondition, "", "the ledger already contains assets, an admin must force the initialization")
+		}
+	}
+
+	for i := range assets {
+		existing, err := getAsset(ctx, assets[i].ID)
+		if err != nil {
+			return err
+		}
+		err = putAsset(ctx, &assets[i], existing)
+		if err != nil {
+			return err
+		}
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+	markerJSON, err := json.Marshal(initMarker{
+		AssetCount: len(assets),
+		Timestamp:  now.Format(time.RFC3339Nano),
+		TxID:       ctx.GetStub().GetTxID(),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(markerKey, markerJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put to world state. %v", err)
+	}
+
+	return nil
+}
+
+// seedAssets returns the validated assets to seed the ledger with
+func seedAssets(options *InitLedgerOptions) ([]Asset, error) {
+	if len(options.Assets) > 0 && options.Count != 0 {
+		return nil, newContractError(ErrorCodeInvalidArgument, "", "the init options must not set both Assets and Count")
+	}
+	if options.Count < 0 || options.Count > maxSyntheticAssets {
+		return nil, newContractError(ErrorCodeInvalidArgument, "", "the count of synthetic assets must be between 1 and %d, or 0 for the default assets, got %d", maxSyntheticAssets, options.Count)
+	}
+
+	if options.Count > 0 {
+		return syntheticAssets(options.Count), nil
+	}
+	if len(options.Assets) == 0 {
+		return defaultAssets(), nil
+	}
+
+	batchErr := &BatchError{}
+	seen := make(map[string]bool)
+	for i := range options.Assets {
+		asset := &options.Assets[i]
+		err := validateNewAsset(asset)
+		if err != nil {
+			batchErr.addError(i, asset.ID, err)
+			continue
+		}
+		if seen[asset.ID] {
+			batchErr.add(i, asset.ID, ErrorCodeInvalidArgument, "the asset %s appears more than once in the seed", asset.ID)
+			continue
+		}
+		seen[asset.ID] = true
+	}
+	if len(batchErr.Errors) > 0 {
+		return nil, batchErr
+	}
+
+	return options.Assets, nil
+}
+
+// defaultAssets returns the base set of assets of the sample
+func defaultAssets() []Asset {
+	return []Asset{
+		{ID: "asset1", Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 300},
+		{ID: "asset2", Color: "red", Size: 5, Owner: "Brad", AppraisedValue: 400},
+		{ID: "asset3", Color: "green", Size: 10, Owner: "Jin Soo", AppraisedValue: 500},
+		{ID: "asset4", Color: "yellow", Size: 10, Owner: "Max", AppraisedValue: 600},
+		{ID: "asset5", Color: "black", Size: 15, Owner: "Adriana", AppraisedValue: 700},
+		{ID: "asset6", Color: "white", Size: 15, Owner: "Michel", AppraisedValue: 800},
+	}
+}
+
+// syntheticAssets returns count generated assets. The assets only depend on count, so that
+// every endorsing peer generates the same set.
+func syntheticAssets(count int) []Asset {
+	colors := []string{"blue", "red", "green", "yellow", "black", "white"}
+	owners := []string{"Tomoko", "Brad", "Jin Soo", "Max", "Adriana", "Michel"}
+
+	assets := make([]Asset, count)
+	for i := range assets {
+		assets[i] = Asset{
+			ID:             fmt.Sprintf("asset%d", i+1),
+			Color:          colors[i%len(colors)],
+			Size:           5 + 5*(i%3),
+			Owner:          owners[(i/len(colors))%len(owners)],
+			AppraisedValue: 300 + 100*(i%10),
+		}
+	}
+
+	return assets
+}
+
+// ledgerIsEmpty returns true if the world state holds no asset, including soft deleted assets
+func ledgerIsEmpty(ctx contractapi.TransactionContextInterface) (bool, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return false, err
+	}
+	defer resultsIterator.Close()
+
+	return !resultsIterator.HasNext(), nil
+}
@@ -0,0 +1,103 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+// initMarkerKey is the init marker key as built by the CreateCompositeKey stub of prepOwnerMocks
+var initMarkerKey = approvalKey()
+
+func TestInitLedgerWithOptions(t *testing.T) {
+	transactionContext, chaincodeStub := prepSeedMocks(t, map[string][]byte{}, false)
+	assetTransfer := chaincode.SmartContract{}
+
+	err := assetTransfer.InitLedgerWithOptions(transactionContext, `{"Assets":[{"ID":"car1","Color":"red","Owner":"Tom"},{"ID":"car2","Size":3}]}`)
+	require.NoError(t, err)
+	require.Equal(t, 3, chaincodeStub.PutStateCallCount())
+	require.Equal(t, &chaincode.Asset{ID: "car1", Color: "red", Owner: "Tom", LastTxID: "tx1", Version: 1}, putAssetArg(t, chaincodeStub, 0))
+	key, value := chaincodeStub.PutStateArgsForCall(2)
+	require.Equal(t, initMarkerKey, key)
+	require.JSONEq(t, `{"AssetCount":2,"Timestamp":"2021-03-01T10:00:00Z","TxID":"tx1"}`, string(value))
+
+	transactionContext, chaincodeStub = prepSeedMocks(t, map[string][]byte{}, false)
+	err = assetTransfer.InitLedgerWithOptions(transactionContext, `{"Count":20}`)
+	require.NoError(t, err)
+	require.Equal(t, 21, chaincodeStub.PutStateCallCount())
+	require.Equal(t, &chaincode.Asset{ID: "asset20", Color: "red", Size: 10, Owner: "Max", AppraisedValue: 1200, LastTxID: "tx1", Version: 1}, putAssetArg(t, chaincodeStub, 19))
+
+	err = assetTransfer.InitLedgerWithOptions(transactionContext, `{"Count":2,"Assets":[{"ID":"car1"}]}`)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the init options must not set both Assets and Count")
+
+	err = assetTransfer.InitLedgerWithOptions(transactionContext, `{"Count":1001}`)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the count of synthetic assets must be between 1 and 1000, or 0 for the default assets, got 1001")
+
+	err = assetTransfer.InitLedgerWithOptions(transactionContext, `{"Count":-1}`)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "the count of synthetic assets must be between 1 and 1000, or 0 for the default assets, got -1")
+
+	err = assetTransfer.InitLedgerWithOptions(transactionContext, `{"Count":"ten"}`)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to parse init options")
+
+	err = assetTransfer.InitLedgerWithOptions(transactionContext, `{"Assets":[{"ID":"car1","Size":-1},{"ID":"car2"},{"ID":"car2"}]}`)
+	var batchErr *chaincode.BatchError
+	require.True(t, errors.As(err, &batchErr))
+	require.Equal(t, []chaincode.BatchItemError{
+		{Index: 0, ID: "car1", Code: chaincode.ErrorCodeInvalidArgument, Message: "the size of asset car1 must not be negative"},
+		{Index: 2, ID: "car2", Code: chaincode.ErrorCodeInvalidArgument, Message: "the asset car2 appears more than once in the seed"},
+	}, batchErr.Errors)
+	require.Equal(t, 21, chaincodeStub.PutStateCallCount())
+}
+
+func TestInitLedgerInitialized(t *testing.T) {
+	assetTransfer := chaincode.SmartContract{AdminMSPID: "Org1MSP"}
+
+	transactionContext, chaincodeStub := prepSeedMocks(t, map[string][]byte{initMarkerKey: []byte(`{}`)}, true)
+	err := assetTransfer.InitLedger(transactionContext)
+	require.NoError(t, err)
+	require.Zero(t, chaincodeStub.PutStateCallCount())
+
+	state := map[string][]byte{
+		"asset1": assetJSON(t, &chaincode.Asset{ID: "asset1", Owner: "Brad", Version: 4}),
+	}
+	transactionContext, chaincodeStub = prepSeedMocks(t, state, true)
+	err = assetTransfer.InitLedger(transactionContext)
+	requireContractError(t, err, chaincode.ErrorCodeFailedPrecondition, "the ledger already contains assets, an admin must force the initialization")
+	require.Zero(t, chaincodeStub.PutStateCallCount())
+
+	clientIdentity := transactionContext.GetClientIdentity().(*mocks.ClientIdentity)
+	clientIdentity.GetMSPIDReturns("Org2MSP", nil)
+	err = assetTransfer.InitLedgerWithOptions(transactionContext, `{"Force":true}`)
+	requireContractError(t, err, chaincode.ErrorCodePermissionDenied, "submitting client of MSP Org2MSP is not an admin")
+	require.Zero(t, chaincodeStub.PutStateCallCount())
+
+	clientIdentity.GetMSPIDReturns("Org1MSP", nil)
+	err = assetTransfer.InitLedgerWithOptions(transactionContext, `{"Force":true}`)
+	require.NoError(t, err)
+	require.Equal(t, 7, chaincodeStub.PutStateCallCount())
+	require.Equal(t, &chaincode.Asset{ID: "asset1", Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 300, LastTxID: "tx1", Version: 5}, putAssetArg(t, chaincodeStub, 0))
+
+	var marker map[string]interface{}
+	_, value := chaincodeStub.PutStateArgsForCall(6)
+	require.NoError(t, json.Unmarshal(value, &marker))
+	require.Equal(t, float64(6), marker["AssetCount"])
+}
+
+// prepSeedMocks returns mocks backed by the given world state, with a range query over
+// assets returning a result when hasAssets is true
+func prepSeedMocks(t *testing.T, state map[string][]byte, hasAssets bool) (*mocks.TransactionContext, *mocks.ChaincodeStub) {
+	transactionContext, chaincodeStub := prepOwnerMocks(t, ownerClientID, state)
+	chaincodeStub.GetTxIDReturns("tx1")
+	chaincodeStub.GetTxTimestampReturns(txTimestamp(t, deletedAt), nil)
+
+	iterator := &mocks.StateQueryIterator{}
+	iterator.HasNextReturns(hasAssets)
+	chaincodeStub.GetStateByRangeReturns(iterator, nil)
+
+	return transactionContext, chaincodeStub
+}
@@ -3,62 +3,86 @@ package chaincode
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
+	"github.com/golang/protobuf/ptypes"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
 // SmartContract provides functions for managing an Asset
 type SmartContract struct {
 	contractapi.Contract
+
+	// OwnerAuthorization binds the Owner of new assets to the submitting client identity, and
+	// only lets the owner, or an operator it approved, update, transfer or delete an asset.
+	// Every peer endorsing the chaincode must be started with the same setting.
+	OwnerAuthorization bool
+
+	// SoftDelete makes DeleteAsset keep a tombstone of the asset instead of removing it from
+	// the world state. Deleted assets are hidden from queries, and can be restored with
+	// RestoreAsset within RetentionPeriod, or removed for good with PurgeAsset.
+	SoftDelete bool
+
+	// RetentionPeriod is how long a soft deleted asset can be restored. Zero means forever.
+	RetentionPeriod time.Duration
+
+	// AdminMSPID is the MSP ID of the organization allowed to run admin operations, such as
+	// PurgeAsset. Admin operations are rejected when it is empty.
+	AdminMSPID string
 }
 
 // Asset describes basic details of what makes up a simple asset
 //Insert struct field in alphabetic order => to achieve determinism accross languages
 // golang keeps the order when marshal to json but doesn't order automatically
+// Version and LastTxID are maintained by the contract, Version starts at 1 and is
+// incremented by every transaction that writes the asset.
+// DeletedAt and DeletedBy are only set on the tombstone of a soft deleted asset.
 type Asset struct {
 	AppraisedValue int    `json:"AppraisedValue"`
 	Color          string `json:"Color"`
+	DeletedAt      string `json:"DeletedAt,omitempty" metadata:",optional"`
+	DeletedBy      string `json:"DeletedBy,omitempty" metadata:",optional"`
 	ID             string `json:"ID"`
+	LastTxID       string `json:"LastTxID" metadata:",optional"`
 	Owner          string `json:"Owner"`
 	Size           int    `json:"Size"`
+	Version        int    `json:"Version" metadata:",optional"`
 }
 
-// InitLedger adds a base set of assets to the ledger
-func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
-	assets := []Asset{
-		{ID: "asset1", Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 300},
-		{ID: "asset2", Color: "red", Size: 5, Owner: "Brad", AppraisedValue: 400},
-		{ID: "asset3", Color: "green", Size: 10, Owner: "Jin Soo", AppraisedValue: 500},
-		{ID: "asset4", Color: "yellow", Size: 10, Owner: "Max", AppraisedValue: 600},
-		{ID: "asset5", Color: "black", Size: 15, Owner: "Adriana", AppraisedValue: 700},
-		{ID: "asset6", Color: "white", Size: 15, Owner: "Michel", AppraisedValue: 800},
-	}
+// AssetFilter describes the optional criteria used to narrow down a paginated asset query.
+// Fields left empty (or nil) are not used for filtering.
+// Soft deleted assets are only returned when IncludeDeleted is set.
+type AssetFilter struct {
+	Color             string `json:"Color,omitempty"`
+	IncludeDeleted    bool   `json:"IncludeDeleted,omitempty"`
+	MaxAppraisedValue *int   `json:"MaxAppraisedValue,omitempty"`
+	MinAppraisedValue *int   `json:"MinAppraisedValue,omitempty"`
+	Owner             string `json:"Owner,omitempty"`
+}
 
-	for _, asset := range assets {
-		assetJSON, err := json.Marshal(asset)
-		if err != nil {
-			return err
-		}
+// HistoryQueryResult structure used for returning result of history query
+type HistoryQueryResult struct {
+	Record    *Asset    `json:"record"`
+	TxId      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+	IsDelete  bool      `json:"isDelete"`
+}
 
-		err = ctx.GetStub().PutState(asset.ID, assetJSON)
-		if err != nil {
-			return fmt.Errorf("failed to put to world state. %v", err)
-		}
-	}
+// PaginatedQueryResult structure used for returning paginated query results and metadata
+type PaginatedQueryResult struct {
+	Records             []*Asset `json:"records"`
+	FetchedRecordsCount int32    `json:"fetchedRecordsCount"`
+	Bookmark            string   `json:"bookmark"`
+}
 
-	return nil
+// InitLedger adds a base set of assets to the ledger, unless it was already initialized.
+// It is refused if the ledger already contains assets, see InitLedgerWithOptions.
+func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
+	return s.initLedger(ctx, &InitLedgerOptions{})
 }
 
 // CreateAsset issues a new asset to the world state with given details.
 func (s *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface, id string, color string, size int, owner string, appraisedValue int) error {
-	exists, err := s.AssetExists(ctx, id)
-	if err != nil {
-		return err
-	}
-	if exists {
-		return fmt.Errorf("the asset %s already exists", id)
-	}
-
 	asset := Asset{
 		ID:             id,
 		Color:          color,
@@ -66,22 +90,47 @@ func (s *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface,
 		Owner:          owner,
 		AppraisedValue: appraisedValue,
 	}
-	assetJSON, err := json.Marshal(asset)
+	err := validateNewAsset(&asset)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, assetJSON)
+	existing, err := getAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = checkAssetIDFree(existing)
+	if err != nil {
+		return err
+	}
+
+	asset.Owner, err = s.bindOwner(ctx, owner)
+	if err != nil {
+		return err
+	}
+
+	err = putAsset(ctx, &asset, nil)
+	if err != nil {
+		return err
+	}
+
+	return emitAssetEvent(ctx, AssetCreatedEvent, nil, &asset)
 }
 
 // ReadAsset returns the asset stored in the world state with given id.
+// Soft deleted assets are reported as not existing.
 func (s *SmartContract) ReadAsset(ctx contractapi.TransactionContextInterface, id string) (*Asset, error) {
+	err := validateAssetID(id)
+	if err != nil {
+		return nil, err
+	}
+
 	assetJSON, err := ctx.GetStub().GetState(id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read from world state: %v", err)
 	}
 	if assetJSON == nil {
-		return nil, fmt.Errorf("the asset %s does not exist", id)
+		return nil, notFoundError(id)
 	}
 
 	var asset Asset
@@ -89,20 +138,26 @@ func (s *SmartContract) ReadAsset(ctx contractapi.TransactionContextInterface, i
 	if err != nil {
 		return nil, err
 	}
+	if asset.isDeleted() {
+		return nil, notFoundError(id)
+	}
 
 	return &asset, nil
 }
 
 // UpdateAsset updates an existing asset in the world state with provided parameters.
 func (s *SmartContract) UpdateAsset(ctx contractapi.TransactionContextInterface, id string, color string, size int, owner string, appraisedValue int) error {
-	exists, err := s.AssetExists(ctx, id)
-	if err != nil {
-		return err
-	}
-	if !exists {
-		return fmt.Errorf("the asset %s does not exist", id)
-	}
+	return s.updateAsset(ctx, id, color, size, owner, appraisedValue, anyVersion)
+}
 
+// UpdateAssetWithVersion updates an existing asset in the world state with provided parameters,
+// provided the stored asset is still at the expected version. A VersionConflictError is
+// returned if another transaction changed the asset since the client read it.
+func (s *SmartContract) UpdateAssetWithVersion(ctx contractapi.TransactionContextInterface, id string, color string, size int, owner string, appraisedValue int, expectedVersion int) error {
+	return s.updateAsset(ctx, id, color, size, owner, appraisedValue, expectedVersion)
+}
+
+func (s *SmartContract) updateAsset(ctx contractapi.TransactionContextInterface, id string, color string, size int, owner string, appraisedValue int, expectedVersion int) error {
 	// overwriting original asset with new asset
 	asset := Asset{
 		ID:             id,
@@ -111,54 +166,136 @@ func (s *SmartContract) UpdateAsset(ctx contractapi.TransactionContextInterface,
 		Owner:          owner,
 		AppraisedValue: appraisedValue,
 	}
-	assetJSON, err := json.Marshal(asset)
+	err := validateAsset(&asset)
+	if err != nil {
+		return err
+	}
+
+	existing, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = checkVersion(existing, expectedVersion)
+	if err != nil {
+		return err
+	}
+
+	err = s.authorizeOwner(ctx, existing)
 	if err != nil {
 		return err
 	}
+	if s.OwnerAuthorization && owner != existing.Owner {
+		return ownerChangeError(id)
+	}
 
-	return ctx.GetStub().PutState(id, assetJSON)
+	err = putAsset(ctx, &asset, existing)
+	if err != nil {
+		return err
+	}
+
+	return emitAssetEvent(ctx, AssetUpdatedEvent, existing, &asset)
 }
 
 // DeleteAsset deletes an given asset from the world state.
+// In soft delete mode the asset is replaced by a tombstone instead.
 func (s *SmartContract) DeleteAsset(ctx contractapi.TransactionContextInterface, id string) error {
-	exists, err := s.AssetExists(ctx, id)
+	return s.deleteAsset(ctx, id, anyVersion)
+}
+
+// DeleteAssetWithVersion deletes an given asset from the world state, provided it is still at
+// the expected version. A VersionConflictError is returned if another transaction changed the
+// asset since the client read it.
+func (s *SmartContract) DeleteAssetWithVersion(ctx contractapi.TransactionContextInterface, id string, expectedVersion int) error {
+	return s.deleteAsset(ctx, id, expectedVersion)
+}
+
+func (s *SmartContract) deleteAsset(ctx contractapi.TransactionContextInterface, id string, expectedVersion int) error {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = checkVersion(asset, expectedVersion)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return fmt.Errorf("the asset %s does not exist", id)
+
+	err = s.authorizeOwner(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	if s.SoftDelete {
+		return s.softDeleteAsset(ctx, asset)
+	}
+
+	err = ctx.GetStub().DelState(id)
+	if err != nil {
+		return err
 	}
 
-	return ctx.GetStub().DelState(id)
+	return emitAssetEvent(ctx, AssetDeletedEvent, asset, nil)
 }
 
-// AssetExists returns true when asset with given ID exists in world state
+// AssetExists returns true when asset with given ID exists in world state and is not soft deleted
 func (s *SmartContract) AssetExists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
-	assetJSON, err := ctx.GetStub().GetState(id)
+	err := validateAssetID(id)
 	if err != nil {
-		return false, fmt.Errorf("failed to read from world state: %v", err)
+		return false, err
 	}
 
-	return assetJSON != nil, nil
+	asset, err := getAsset(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	return asset != nil && !asset.isDeleted(), nil
 }
 
 // TransferAsset updates the owner field of asset with given id in world state.
 func (s *SmartContract) TransferAsset(ctx contractapi.TransactionContextInterface, id string, newOwner string) error {
+	return s.transferAsset(ctx, id, newOwner, anyVersion)
+}
+
+// TransferAssetWithVersion updates the owner field of asset with given id in world state,
+// provided the stored asset is still at the expected version. A VersionConflictError is
+// returned if another transaction changed the asset since the client read it.
+func (s *SmartContract) TransferAssetWithVersion(ctx contractapi.TransactionContextInterface, id string, newOwner string, expectedVersion int) error {
+	return s.transferAsset(ctx, id, newOwner, expectedVersion)
+}
+
+func (s *SmartContract) transferAsset(ctx contractapi.TransactionContextInterface, id string, newOwner string, expectedVersion int) error {
+	if newOwner == "" {
+		return newOwnerEmptyError(id)
+	}
+
 	asset, err := s.ReadAsset(ctx, id)
 	if err != nil {
 		return err
 	}
 
+	err = checkVersion(asset, expectedVersion)
+	if err != nil {
+		return err
+	}
+
+	err = s.authorizeOwner(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	before := *asset
 	asset.Owner = newOwner
-	assetJSON, err := json.Marshal(asset)
+	err = putAsset(ctx, asset, &before)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, assetJSON)
+	return emitAssetEvent(ctx, AssetTransferredEvent, &before, asset)
 }
 
-// GetAllAssets returns all assets found in world state
+// GetAllAssets returns all assets found in world state, except soft deleted assets
 func (s *SmartContract) GetAllAssets(ctx contractapi.TransactionContextInterface) ([]*Asset, error) {
 	// range query with empty string for startKey and endKey does an
 	// open-ended query of all assets in the chaincode namespace.
@@ -180,8 +317,173 @@ func (s *SmartContract) GetAllAssets(ctx contractapi.TransactionContextInterface
 		if err != nil {
 			return nil, err
 		}
+		if asset.isDeleted() {
+			continue
+		}
 		assets = append(assets, &asset)
 	}
 
 	return assets, nil
 }
+
+// GetAssetsWithPagination returns a single page of assets found in world state, starting
+// at the given bookmark. An empty bookmark starts from the first asset.
+// The filter argument is an optional JSON encoded AssetFilter; assets in the page that do not
+// match it are dropped, so a page may hold fewer records than pageSize even when more remain.
+// Clients should keep requesting pages until the returned bookmark is empty.
+// FetchedRecordsCount is the number of assets read from the ledger for the page, before filtering.
+// Paginated range queries are only valid for read only transactions.
+func (s *SmartContract) GetAssetsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int, bookmark string, filter string) (*PaginatedQueryResult, error) {
+	if pageSize <= 0 {
+		return nil, newContractError(ErrorCodeInvalidArgument, "", "page size must be greater than zero, got %d", pageSize)
+	}
+
+	var assetFilter AssetFilter
+	if filter != "" {
+		err := json.Unmarshal([]byte(filter), &assetFilter)
+		if err != nil {
+			return nil, newContractError(ErrorCodeInvalidArgument, "", "failed to parse asset filter: %v", err)
+		}
+	}
+	if assetFilter.MinAppraisedValue != nil && assetFilter.MaxAppraisedValue != nil &&
+		*assetFilter.MinAppraisedValue > *assetFilter.MaxAppraisedValue {
+		return nil, newContractError(ErrorCodeInvalidArgument, "", "minimum appraised value %d is greater than maximum appraised value %d",
+			*assetFilter.MinAppraisedValue, *assetFilter.MaxAppraisedValue)
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByRangeWithPagination("", "", int32(pageSize), bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	assets := []*Asset{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var asset Asset
+		err = json.Unmarshal(queryResponse.Value, &asset)
+		if err != nil {
+			return nil, err
+		}
+		if assetFilter.matches(&asset) {
+			assets = append(assets, &asset)
+		}
+	}
+
+	return &PaginatedQueryResult{
+		Records:             assets,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
+
+// matches returns true when the asset satisfies every criteria set on the filter
+func (f *AssetFilter) matches(asset *Asset) bool {
+	if asset.isDeleted() && !f.IncludeDeleted {
+		return false
+	}
+	if f.Owner != "" && asset.Owner != f.Owner {
+		return false
+	}
+	if f.Color != "" && asset.Color != f.Color {
+		return false
+	}
+	if f.MinAppraisedValue != nil && asset.AppraisedValue < *f.MinAppraisedValue {
+		return false
+	}
+	if f.MaxAppraisedValue != nil && asset.AppraisedValue > *f.MaxAppraisedValue {
+		return false
+	}
+
+	return true
+}
+
+// GetAssetHistory returns every version of the asset with given id recorded on the ledger,
+// including deletions, in the order returned by the history database.
+func (s *SmartContract) GetAssetHistory(ctx contractapi.TransactionContextInterface, id string) ([]HistoryQueryResult, error) {
+	err := validateAssetID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read asset history: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var records []HistoryQueryResult
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var asset Asset
+		if len(response.Value) > 0 {
+			err = json.Unmarshal(response.Value, &asset)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			asset = Asset{
+				ID: id,
+			}
+		}
+
+		timestamp, err := ptypes.Timestamp(response.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		record := HistoryQueryResult{
+			TxId:      response.TxId,
+			Timestamp: timestamp,
+			Record:    &asset,
+			IsDelete:  response.IsDelete,
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// ReadAssetAsOf returns the asset with given id as it was in world state at the given time.
+// The timestamp must be in RFC 3339 format, e.g. 2021-03-01T00:00:00Z. The state is rebuilt
+// from the asset history, so the version returned is the last one committed at or before
+// the timestamp. An error is returned if the asset did not exist, or had been deleted or soft
+// deleted, at that time.
+func (s *SmartContract) ReadAssetAsOf(ctx contractapi.TransactionContextInterface, id string, timestamp string) (*Asset, error) {
+	asOf, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return nil, newContractError(ErrorCodeInvalidArgument, id, "failed to parse timestamp %s: %v", timestamp, err)
+	}
+
+	history, err := s.GetAssetHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// the history database does not guarantee the order of the results across
+	// Fabric versions, so look for the latest version not after the timestamp
+	var latest *HistoryQueryResult
+	for i := range history {
+		record := &history[i]
+		if record.Timestamp.After(asOf) {
+			continue
+		}
+		if latest == nil || record.Timestamp.After(latest.Timestamp) {
+			latest = record
+		}
+	}
+
+	if latest == nil || latest.IsDelete || latest.Record.isDeleted() {
+		return nil, newContractError(ErrorCodeNotFound, id, "the asset %s did not exist at %s", id, timestamp)
+	}
+
+	return latest.Record, nil
+}
@@ -4,10 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
 	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
 	"github.com/stretchr/testify/require"
@@ -28,14 +32,27 @@ type stateQueryIterator interface {
 	shim.StateQueryIteratorInterface
 }
 
+//go:generate counterfeiter -o mocks/historyqueryiterator.go -fake-name HistoryQueryIterator . historyQueryIterator
+type historyQueryIterator interface {
+	shim.HistoryQueryIteratorInterface
+}
+
+//go:generate counterfeiter -o mocks/clientidentity.go -fake-name ClientIdentity . clientIdentity
+type clientIdentity interface {
+	cid.ClientIdentity
+}
+
 func TestInitLedger(t *testing.T) {
 	chaincodeStub := &mocks.ChaincodeStub{}
+	chaincodeStub.GetStateByRangeReturns(&mocks.StateQueryIterator{}, nil)
+	chaincodeStub.GetTxTimestampReturns(ptypes.TimestampNow(), nil)
 	transactionContext := &mocks.TransactionContext{}
 	transactionContext.GetStubReturns(chaincodeStub)
 
 	assetTransfer := chaincode.SmartContract{}
 	err := assetTransfer.InitLedger(transactionContext)
 	require.NoError(t, err)
+	require.Equal(t, 7, chaincodeStub.PutStateCallCount())
 
 	chaincodeStub.PutStateReturns(fmt.Errorf("failed inserting key"))
 	err = assetTransfer.InitLedger(transactionContext)
@@ -48,12 +65,12 @@ func TestCreateAsset(t *testing.T) {
 	transactionContext.GetStubReturns(chaincodeStub)
 
 	assetTransfer := chaincode.SmartContract{}
-	err := assetTransfer.CreateAsset(transactionContext, "", "", 0, "", 0)
+	err := assetTransfer.CreateAsset(transactionContext, "asset1", "", 0, "", 0)
 	require.NoError(t, err)
 
-	chaincodeStub.GetStateReturns([]byte{}, nil)
+	chaincodeStub.GetStateReturns(assetJSON(t, &chaincode.Asset{ID: "asset1"}), nil)
 	err = assetTransfer.CreateAsset(transactionContext, "asset1", "", 0, "", 0)
-	require.EqualError(t, err, "the asset asset1 already exists")
+	requireContractError(t, err, chaincode.ErrorCodeAlreadyExists, "the asset asset1 already exists")
 
 	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
 	err = assetTransfer.CreateAsset(transactionContext, "asset1", "", 0, "", 0)
@@ -71,17 +88,17 @@ func TestReadAsset(t *testing.T) {
 
 	chaincodeStub.GetStateReturns(bytes, nil)
 	assetTransfer := chaincode.SmartContract{}
-	asset, err := assetTransfer.ReadAsset(transactionContext, "")
+	asset, err := assetTransfer.ReadAsset(transactionContext, "asset1")
 	require.NoError(t, err)
 	require.Equal(t, expectedAsset, asset)
 
 	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
-	_, err = assetTransfer.ReadAsset(transactionContext, "")
+	_, err = assetTransfer.ReadAsset(transactionContext, "asset1")
 	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
 
 	chaincodeStub.GetStateReturns(nil, nil)
 	asset, err = assetTransfer.ReadAsset(transactionContext, "asset1")
-	require.EqualError(t, err, "the asset asset1 does not exist")
+	requireContractError(t, err, chaincode.ErrorCodeNotFound, "the asset asset1 does not exist")
 	require.Nil(t, asset)
 }
 
@@ -96,12 +113,12 @@ func TestUpdateAsset(t *testing.T) {
 
 	chaincodeStub.GetStateReturns(bytes, nil)
 	assetTransfer := chaincode.SmartContract{}
-	err = assetTransfer.UpdateAsset(transactionContext, "", "", 0, "", 0)
+	err = assetTransfer.UpdateAsset(transactionContext, "asset1", "", 0, "", 0)
 	require.NoError(t, err)
 
 	chaincodeStub.GetStateReturns(nil, nil)
 	err = assetTransfer.UpdateAsset(transactionContext, "asset1", "", 0, "", 0)
-	require.EqualError(t, err, "the asset asset1 does not exist")
+	requireContractError(t, err, chaincode.ErrorCodeNotFound, "the asset asset1 does not exist")
 
 	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
 	err = assetTransfer.UpdateAsset(transactionContext, "asset1", "", 0, "", 0)
@@ -120,15 +137,15 @@ func TestDeleteAsset(t *testing.T) {
 	chaincodeStub.GetStateReturns(bytes, nil)
 	chaincodeStub.DelStateReturns(nil)
 	assetTransfer := chaincode.SmartContract{}
-	err = assetTransfer.DeleteAsset(transactionContext, "")
+	err = assetTransfer.DeleteAsset(transactionContext, "asset1")
 	require.NoError(t, err)
 
 	chaincodeStub.GetStateReturns(nil, nil)
 	err = assetTransfer.DeleteAsset(transactionContext, "asset1")
-	require.EqualError(t, err, "the asset asset1 does not exist")
+	requireContractError(t, err, chaincode.ErrorCodeNotFound, "the asset asset1 does not exist")
 
 	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
-	err = assetTransfer.DeleteAsset(transactionContext, "")
+	err = assetTransfer.DeleteAsset(transactionContext, "asset1")
 	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
 }
 
@@ -143,11 +160,11 @@ func TestTransferAsset(t *testing.T) {
 
 	chaincodeStub.GetStateReturns(bytes, nil)
 	assetTransfer := chaincode.SmartContract{}
-	err = assetTransfer.TransferAsset(transactionContext, "", "")
+	err = assetTransfer.TransferAsset(transactionContext, "asset1", "Brad")
 	require.NoError(t, err)
 
 	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
-	err = assetTransfer.TransferAsset(transactionContext, "", "")
+	err = assetTransfer.TransferAsset(transactionContext, "asset1", "Brad")
 	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
 }
 
@@ -182,3 +199,199 @@ func TestGetAllAssets(t *testing.T) {
 	require.EqualError(t, err, "failed retrieving all assets")
 	require.Nil(t, assets)
 }
+
+func TestGetAssetsWithPagination(t *testing.T) {
+	asset1 := &chaincode.Asset{ID: "asset1", Color: "blue", Owner: "Tomoko", AppraisedValue: 300}
+	asset2 := &chaincode.Asset{ID: "asset2", Color: "red", Owner: "Brad", AppraisedValue: 400}
+	bytes1, err := json.Marshal(asset1)
+	require.NoError(t, err)
+	bytes2, err := json.Marshal(asset2)
+	require.NoError(t, err)
+
+	newIterator := func() *mocks.StateQueryIterator {
+		iterator := &mocks.StateQueryIterator{}
+		iterator.HasNextReturnsOnCall(0, true)
+		iterator.HasNextReturnsOnCall(1, true)
+		iterator.HasNextReturnsOnCall(2, false)
+		iterator.NextReturnsOnCall(0, &queryresult.KV{Key: "asset1", Value: bytes1}, nil)
+		iterator.NextReturnsOnCall(1, &queryresult.KV{Key: "asset2", Value: bytes2}, nil)
+		return iterator
+	}
+	metadata := &peer.QueryResponseMetadata{FetchedRecordsCount: 2, Bookmark: "asset3"}
+
+	chaincodeStub := &mocks.ChaincodeStub{}
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	assetTransfer := &chaincode.SmartContract{}
+
+	chaincodeStub.GetStateByRangeWithPaginationReturns(newIterator(), metadata, nil)
+	result, err := assetTransfer.GetAssetsWithPagination(transactionContext, 2, "asset1", "")
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.Asset{asset1, asset2}, result.Records)
+	require.Equal(t, int32(2), result.FetchedRecordsCount)
+	require.Equal(t, "asset3", result.Bookmark)
+
+	startKey, endKey, pageSize, bookmark := chaincodeStub.GetStateByRangeWithPaginationArgsForCall(0)
+	require.Equal(t, "", startKey)
+	require.Equal(t, "", endKey)
+	require.Equal(t, int32(2), pageSize)
+	require.Equal(t, "asset1", bookmark)
+
+	chaincodeStub.GetStateByRangeWithPaginationReturns(newIterator(), metadata, nil)
+	result, err = assetTransfer.GetAssetsWithPagination(transactionContext, 2, "", `{"Owner":"Brad"}`)
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.Asset{asset2}, result.Records)
+	require.Equal(t, "asset3", result.Bookmark)
+
+	chaincodeStub.GetStateByRangeWithPaginationReturns(newIterator(), metadata, nil)
+	result, err = assetTransfer.GetAssetsWithPagination(transactionContext, 2, "", `{"Color":"blue","MaxAppraisedValue":350}`)
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.Asset{asset1}, result.Records)
+
+	chaincodeStub.GetStateByRangeWithPaginationReturns(newIterator(), metadata, nil)
+	result, err = assetTransfer.GetAssetsWithPagination(transactionContext, 2, "", `{"MinAppraisedValue":500}`)
+	require.NoError(t, err)
+	require.Empty(t, result.Records)
+	require.Equal(t, int32(2), result.FetchedRecordsCount)
+
+	_, err = assetTransfer.GetAssetsWithPagination(transactionContext, 0, "", "")
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "page size must be greater than zero, got 0")
+
+	_, err = assetTransfer.GetAssetsWithPagination(transactionContext, 2, "", "{")
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "failed to parse asset filter: unexpected end of JSON input")
+
+	_, err = assetTransfer.GetAssetsWithPagination(transactionContext, 2, "", `{"MinAppraisedValue":500,"MaxAppraisedValue":100}`)
+	requireContractError(t, err, chaincode.ErrorCodeInvalidArgument, "minimum appraised value 500 is greater than maximum appraised value 100")
+
+	iterator := &mocks.StateQueryIterator{}
+	iterator.HasNextReturns(true)
+	iterator.NextReturns(nil, fmt.Errorf("failed retrieving next item"))
+	chaincodeStub.GetStateByRangeWithPaginationReturns(iterator, metadata, nil)
+	result, err = assetTransfer.GetAssetsWithPagination(transactionContext, 2, "", "")
+	require.EqualError(t, err, "failed retrieving next item")
+	require.Nil(t, result)
+
+	chaincodeStub.GetStateByRangeWithPaginationReturns(nil, nil, fmt.Errorf("failed retrieving assets"))
+	result, err = assetTransfer.GetAssetsWithPagination(transactionContext, 2, "", "")
+	require.EqualError(t, err, "failed retrieving assets")
+	require.Nil(t, result)
+}
+
+func TestGetAssetHistory(t *testing.T) {
+	asset := &chaincode.Asset{ID: "asset1", Owner: "Tomoko"}
+	bytes, err := json.Marshal(asset)
+	require.NoError(t, err)
+
+	createdAt := time.Date(2021, time.February, 1, 0, 0, 0, 0, time.UTC)
+	deletedAt := time.Date(2021, time.April, 1, 0, 0, 0, 0, time.UTC)
+	iterator := newHistoryIterator(t,
+		historyEntry{txID: "tx2", timestamp: deletedAt, isDelete: true},
+		historyEntry{txID: "tx1", timestamp: createdAt, value: bytes},
+	)
+
+	chaincodeStub := &mocks.ChaincodeStub{}
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	chaincodeStub.GetHistoryForKeyReturns(iterator, nil)
+	assetTransfer := &chaincode.SmartContract{}
+	history, err := assetTransfer.GetAssetHistory(transactionContext, "asset1")
+	require.NoError(t, err)
+	require.Equal(t, []chaincode.HistoryQueryResult{
+		{Record: &chaincode.Asset{ID: "asset1"}, TxId: "tx2", Timestamp: deletedAt, IsDelete: true},
+		{Record: asset, TxId: "tx1", Timestamp: createdAt},
+	}, history)
+	require.Equal(t, "asset1", chaincodeStub.GetHistoryForKeyArgsForCall(0))
+
+	iterator = &mocks.HistoryQueryIterator{}
+	iterator.HasNextReturns(true)
+	iterator.NextReturns(nil, fmt.Errorf("failed retrieving next item"))
+	chaincodeStub.GetHistoryForKeyReturns(iterator, nil)
+	history, err = assetTransfer.GetAssetHistory(transactionContext, "asset1")
+	require.EqualError(t, err, "failed retrieving next item")
+	require.Nil(t, history)
+
+	chaincodeStub.GetHistoryForKeyReturns(nil, fmt.Errorf("history database disabled"))
+	history, err = assetTransfer.GetAssetHistory(transactionContext, "asset1")
+	require.EqualError(t, err, "failed to read asset history: history database disabled")
+	require.Nil(t, history)
+}
+
+func TestReadAssetAsOf(t *testing.T) {
+	owners := []string{"Tomoko", "Brad"}
+	versions := make([][]byte, len(owners))
+	for i, owner := range owners {
+		bytes, err := json.Marshal(&chaincode.Asset{ID: "asset7", Owner: owner})
+		require.NoError(t, err)
+		versions[i] = bytes
+	}
+
+	createdAt := time.Date(2021, time.January, 10, 0, 0, 0, 0, time.UTC)
+	transferredAt := time.Date(2021, time.February, 15, 0, 0, 0, 0, time.UTC)
+	deletedAt := time.Date(2021, time.April, 1, 0, 0, 0, 0, time.UTC)
+	entries := []historyEntry{
+		{txID: "tx3", timestamp: deletedAt, isDelete: true},
+		{txID: "tx2", timestamp: transferredAt, value: versions[1]},
+		{txID: "tx1", timestamp: createdAt, value: versions[0]},
+	}
+
+	chaincodeStub := &mocks.ChaincodeStub{}
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	assetTransfer := &chaincode.SmartContract{}
+
+	chaincodeStub.GetHistoryForKeyReturns(newHistoryIterator(t, entries...), nil)
+	asset, err := assetTransfer.ReadAssetAsOf(transactionContext, "asset7", "2021-03-01T00:00:00Z")
+	require.NoError(t, err)
+	require.Equal(t, "Brad", asset.Owner)
+
+	chaincodeStub.GetHistoryForKeyReturns(newHistoryIterator(t, entries...), nil)
+	asset, err = assetTransfer.ReadAssetAsOf(transactionContext, "asset7", "2021-02-15T00:00:00Z")
+	require.NoError(t, err)
+	require.Equal(t, "Brad", asset.Owner)
+
+	chaincodeStub.GetHistoryForKeyReturns(newHistoryIterator(t, entries...), nil)
+	asset, err = assetTransfer.ReadAssetAsOf(transactionContext, "asset7", "2021-02-01T00:00:00Z")
+	require.NoError(t, err)
+	require.Equal(t, "Tomoko", asset.Owner)
+
+	chaincodeStub.GetHistoryForKeyReturns(newHistoryIterator(t, entries...), nil)
+	asset, err = assetTransfer.ReadAssetAsOf(transactionContext, "asset7", "2021-01-01T00:00:00Z")
+	requireContractError(t, err, chaincode.ErrorCodeNotFound, "the asset asset7 did not exist at 2021-01-01T00:00:00Z")
+	require.Nil(t, asset)
+
+	chaincodeStub.GetHistoryForKeyReturns(newHistoryIterator(t, entries...), nil)
+	asset, err = assetTransfer.ReadAssetAsOf(transactionContext, "asset7", "2021-05-01T00:00:00Z")
+	requireContractError(t, err, chaincode.ErrorCodeNotFound, "the asset asset7 did not exist at 2021-05-01T00:00:00Z")
+	require.Nil(t, asset)
+
+	_, err = assetTransfer.ReadAssetAsOf(transactionContext, "asset7", "March 1st")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to parse timestamp March 1st")
+}
+
+type historyEntry struct {
+	txID      string
+	timestamp time.Time
+	value     []byte
+	isDelete  bool
+}
+
+func newHistoryIterator(t *testing.T, entries ...historyEntry) *mocks.HistoryQueryIterator {
+	iterator := &mocks.HistoryQueryIterator{}
+	for i, entry := range entries {
+		timestamp, err := ptypes.TimestampProto(entry.timestamp)
+		require.NoError(t, err)
+
+		iterator.HasNextReturnsOnCall(i, true)
+		iterator.NextReturnsOnCall(i, &queryresult.KeyModification{
+			TxId:      entry.txID,
+			Value:     entry.value,
+			Timestamp: timestamp,
+			IsDelete:  entry.isDelete,
+		}, nil)
+	}
+	iterator.HasNextReturnsOnCall(len(entries), false)
+
+	return iterator
+}
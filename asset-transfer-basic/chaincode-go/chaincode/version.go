@@ -0,0 +1,41 @@
+package chaincode
+
+import (
+	"fmt"
+)
+
+// anyVersion disables the version check of the write functions
+const anyVersion = -1
+
+// VersionConflictError is returned when an asset is written with an expected version
+// that differs from the version stored in the world state, meaning another transaction
+// changed the asset since the client read it. Its message is the JSON encoding of the
+// error with the code ABORTED, so that clients can tell a conflict from other errors and
+// retry the transaction after reading the asset again.
+type VersionConflictError struct {
+	ID              string
+	ExpectedVersion int
+	CurrentVersion  int
+}
+
+func (e *VersionConflictError) Error() string {
+	message := fmt.Sprintf("the asset %s was modified, expected version %d but found version %d", e.ID, e.ExpectedVersion, e.CurrentVersion)
+
+	return encodeError(struct {
+		Code            ErrorCode `json:"code"`
+		ID              string    `json:"ID"`
+		Message         string    `json:"message"`
+		ExpectedVersion int       `json:"expectedVersion"`
+		CurrentVersion  int       `json:"currentVersion"`
+	}{ErrorCodeAborted, e.ID, message, e.ExpectedVersion, e.CurrentVersion}, message)
+}
+
+// checkVersion returns a VersionConflictError if the asset is not at the expected version.
+// Assets written before versioning was introduced are at version 0.
+func checkVersion(asset *Asset, expectedVersion int) error {
+	if expectedVersion == anyVersion || asset.Version == expectedVersion {
+		return nil
+	}
+
+	return &VersionConflictError{ID: asset.ID, ExpectedVersion: expectedVersion, CurrentVersion: asset.Version}
+}
@@ -0,0 +1,90 @@
+package chaincode_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateAssetVersion(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	chaincodeStub.GetTxIDReturns("tx1")
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	assetTransfer := chaincode.SmartContract{}
+	err := assetTransfer.CreateAsset(transactionContext, "asset1", "blue", 5, "Tomoko", 300)
+	require.NoError(t, err)
+
+	asset := putAssetArg(t, chaincodeStub, 0)
+	require.Equal(t, 1, asset.Version)
+	require.Equal(t, "tx1", asset.LastTxID)
+}
+
+func TestUpdateAssetWithVersion(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	chaincodeStub.GetTxIDReturns("tx3")
+	chaincodeStub.GetStateReturns(assetJSON(t, &chaincode.Asset{ID: "asset1", LastTxID: "tx2", Version: 2}), nil)
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	assetTransfer := chaincode.SmartContract{}
+	err := assetTransfer.UpdateAssetWithVersion(transactionContext, "asset1", "red", 10, "Tomoko", 500, 2)
+	require.NoError(t, err)
+	asset := putAssetArg(t, chaincodeStub, 0)
+	require.Equal(t, 3, asset.Version)
+	require.Equal(t, "tx3", asset.LastTxID)
+
+	err = assetTransfer.UpdateAssetWithVersion(transactionContext, "asset1", "red", 10, "Tomoko", 500, 1)
+	requireVersionConflict(t, err, "asset1", 1, 2)
+	require.Contains(t, err.Error(), `"code":"ABORTED"`)
+	require.Equal(t, 1, chaincodeStub.PutStateCallCount())
+
+	err = assetTransfer.UpdateAsset(transactionContext, "asset1", "red", 10, "Tomoko", 500)
+	require.NoError(t, err)
+	require.Equal(t, 3, putAssetArg(t, chaincodeStub, 1).Version)
+}
+
+func TestTransferAssetWithVersion(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	chaincodeStub.GetStateReturns(assetJSON(t, &chaincode.Asset{ID: "asset1", Owner: "Tomoko", Version: 5}), nil)
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	assetTransfer := chaincode.SmartContract{}
+	err := assetTransfer.TransferAssetWithVersion(transactionContext, "asset1", "Brad", 5)
+	require.NoError(t, err)
+	asset := putAssetArg(t, chaincodeStub, 0)
+	require.Equal(t, "Brad", asset.Owner)
+	require.Equal(t, 6, asset.Version)
+
+	err = assetTransfer.TransferAssetWithVersion(transactionContext, "asset1", "Brad", 4)
+	requireVersionConflict(t, err, "asset1", 4, 5)
+	require.Equal(t, 1, chaincodeStub.PutStateCallCount())
+}
+
+func TestDeleteAssetWithVersion(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	chaincodeStub.GetStateReturns(assetJSON(t, &chaincode.Asset{ID: "asset1"}), nil)
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	assetTransfer := chaincode.SmartContract{}
+	err := assetTransfer.DeleteAssetWithVersion(transactionContext, "asset1", 1)
+	requireVersionConflict(t, err, "asset1", 1, 0)
+	require.Zero(t, chaincodeStub.DelStateCallCount())
+
+	// assets written before versioning was introduced are at version 0
+	err = assetTransfer.DeleteAssetWithVersion(transactionContext, "asset1", 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, chaincodeStub.DelStateCallCount())
+}
+
+func requireVersionConflict(t *testing.T, err error, id string, expectedVersion int, currentVersion int) {
+	var conflict *chaincode.VersionConflictError
+	require.True(t, errors.As(err, &conflict))
+	require.Equal(t, &chaincode.VersionConflictError{ID: id, ExpectedVersion: expectedVersion, CurrentVersion: currentVersion}, conflict)
+}
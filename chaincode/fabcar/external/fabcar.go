@@ -12,13 +12,9 @@ import (
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/chaincode/serverconfig"
 )
 
-type ServerConfig struct {
-	CCID    string
-	Address string
-}
-
 // SmartContract provides functions for managing a car
 type SmartContract struct {
 	contractapi.Contract
@@ -145,9 +141,16 @@ func (s *SmartContract) ChangeCarOwner(ctx contractapi.TransactionContextInterfa
 
 func main() {
 	// See chaincode.env.example
-	config := ServerConfig{
-		CCID:    os.Getenv("CHAINCODE_ID"),
-		Address: os.Getenv("CHAINCODE_SERVER_ADDRESS"),
+	config, err := serverconfig.Load(os.Args[1:])
+	if err != nil {
+		fmt.Printf("Error loading fabcar chaincode configuration: %s", err.Error())
+		return
+	}
+
+	tlsProps, err := config.TLSProperties()
+	if err != nil {
+		fmt.Printf("Error loading fabcar chaincode configuration: %s", err.Error())
+		return
 	}
 
 	chaincode, err := contractapi.NewChaincode(new(SmartContract))
@@ -158,12 +161,10 @@ func main() {
 	}
 
 	server := &shim.ChaincodeServer{
-		CCID:    config.CCID,
-		Address: config.Address,
-		CC:      chaincode,
-		TLSProps: shim.TLSProperties{
-			Disabled: true,
-		},
+		CCID:     config.CCID,
+		Address:  config.Address,
+		CC:       chaincode,
+		TLSProps: tlsProps,
 	}
 
 	if err := server.Start(); err != nil {
@@ -0,0 +1,9 @@
+module github.com/hyperledger/fabric-samples/chaincode/serverconfig
+
+go 1.13
+
+require (
+	github.com/hyperledger/fabric-chaincode-go v0.0.0-20200424173110-d7076418f212
+	github.com/stretchr/testify v1.5.1
+	gopkg.in/yaml.v2 v2.2.8
+)
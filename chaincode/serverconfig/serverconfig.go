@@ -0,0 +1,340 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package serverconfig loads the configuration of the chaincode servers run as external
+// services, such as asset-transfer-basic/chaincode-external and chaincode/fabcar/external.
+//
+// The configuration keys are the environment variables of chaincode.env.example. A key is
+// read, by increasing precedence, from the configuration file, the environment, and the
+// command line flags.
+package serverconfig
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"gopkg.in/yaml.v2"
+)
+
+// Keys of the configuration of every chaincode server
+const (
+	// KeyConfigFile is the path of a YAML or JSON file setting the other keys
+	KeyConfigFile   = "CHAINCODE_CONFIG_FILE"
+	KeyCCID         = "CHAINCODE_ID"
+	KeyAddress      = "CHAINCODE_SERVER_ADDRESS"
+	KeyTLSDisabled  = "CHAINCODE_TLS_DISABLED"
+	KeyTLSKey       = "CHAINCODE_TLS_KEY"
+	KeyTLSCert      = "CHAINCODE_TLS_CERT"
+	KeyClientCACert = "CHAINCODE_CLIENT_CA_CERT"
+)
+
+var commonKeys = []string{KeyConfigFile, KeyCCID, KeyAddress, KeyTLSDisabled, KeyTLSKey, KeyTLSCert, KeyClientCACert}
+
+// boolKeys are set by a flag without value, such as -tls-disabled
+var boolKeys = map[string]bool{KeyTLSDisabled: true}
+
+// Config is the configuration of a chaincode server
+type Config struct {
+	CCID    string
+	Address string
+
+	// TLSDisabled is true unless CHAINCODE_TLS_DISABLED is set to false
+	TLSDisabled bool
+	// TLSKey and TLSCert are the paths of the PEM key and certificate of the server
+	TLSKey  string
+	TLSCert string
+	// ClientCACert is the path of the PEM certificate of the CA of the peers, the
+	// certificate of the peers is not verified if empty
+	ClientCACert string
+
+	values map[string]string
+}
+
+// Load loads the configuration from the configuration file, the environment and args, the
+// command line arguments without the program name. extraKeys are the keys specific to the
+// server, which are read with the String, Bool and Duration functions of the configuration.
+//
+// Every key can be set by a flag named after the key, in lower case without the CHAINCODE_
+// prefix, with dashes in place of underscores, such as -server-address for
+// CHAINCODE_SERVER_ADDRESS.
+func Load(args []string, extraKeys ...string) (*Config, error) {
+	return load(args, os.LookupEnv, extraKeys)
+}
+
+func load(args []string, lookupEnv func(string) (string, bool), extraKeys []string) (*Config, error) {
+	keys := append(append([]string{}, commonKeys...), extraKeys...)
+
+	flagValues, err := parseFlags(args, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	envValues := make(map[string]string)
+	for _, key := range keys {
+		if value, ok := lookupEnv(key); ok {
+			envValues[key] = value
+		}
+	}
+
+	values := make(map[string]string)
+	configFile := envValues[KeyConfigFile]
+	if value, ok := flagValues[KeyConfigFile]; ok {
+		configFile = value
+	}
+	if configFile != "" {
+		values, err = readFile(configFile, keys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for key, value := range envValues {
+		values[key] = value
+	}
+	for key, value := range flagValues {
+		values[key] = value
+	}
+
+	config := &Config{values: values}
+	err = config.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// validate sets and checks the fields common to every chaincode server
+func (c *Config) validate() error {
+	var problems []string
+
+	c.CCID = c.String(KeyCCID, "")
+	if c.CCID == "" {
+		problems = append(problems, fmt.Sprintf("%s must be set to the package ID of the chaincode", KeyCCID))
+	}
+	c.Address = c.String(KeyAddress, "")
+	if c.Address == "" {
+		problems = append(problems, fmt.Sprintf("%s must be set to the host and port of the chaincode server", KeyAddress))
+	}
+
+	tlsDisabled, err := c.Bool(KeyTLSDisabled, true)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	c.TLSDisabled = tlsDisabled
+	c.TLSKey = c.String(KeyTLSKey, "")
+	c.TLSCert = c.String(KeyTLSCert, "")
+	c.ClientCACert = c.String(KeyClientCACert, "")
+
+	if !c.TLSDisabled {
+		for _, key := range []string{KeyTLSKey, KeyTLSCert, KeyClientCACert} {
+			problem := checkFile(key, c.String(key, ""), key != KeyClientCACert)
+			if problem != "" {
+				problems = append(problems, problem)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid chaincode server configuration: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// String returns the value of key, or defaultVal if key is not set
+func (c *Config) String(key, defaultVal string) string {
+	value, ok := c.values[key]
+	if !ok {
+		return defaultVal
+	}
+
+	return value
+}
+
+// Bool returns the value of key, or defaultVal if key is not set. Unlike a silent fallback to
+// defaultVal, a value that is not a boolean is an error.
+func (c *Config) Bool(key string, defaultVal bool) (bool, error) {
+	value, ok := c.values[key]
+	if !ok {
+		return defaultVal, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultVal, fmt.Errorf("%s must be true or false, got %q", key, value)
+	}
+
+	return parsed, nil
+}
+
+// Duration returns the value of key, such as 30s, or defaultVal if key is not set
+func (c *Config) Duration(key string, defaultVal time.Duration) (time.Duration, error) {
+	value, ok := c.values[key]
+	if !ok {
+		return defaultVal, nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return defaultVal, fmt.Errorf("%s must be a duration such as 30s, got %q", key, value)
+	}
+
+	return parsed, nil
+}
+
+// TLSProperties returns the TLS properties of shim.ChaincodeServer, reading the TLS files
+// when TLS is enabled
+func (c *Config) TLSProperties() (shim.TLSProperties, error) {
+	if c.TLSDisabled {
+		return shim.TLSProperties{Disabled: true}, nil
+	}
+
+	key, err := ioutil.ReadFile(c.TLSKey)
+	if err != nil {
+		return shim.TLSProperties{}, fmt.Errorf("error while reading the crypto file: %s", err)
+	}
+	cert, err := ioutil.ReadFile(c.TLSCert)
+	if err != nil {
+		return shim.TLSProperties{}, fmt.Errorf("error while reading the crypto file: %s", err)
+	}
+
+	var clientCACert []byte
+	if c.ClientCACert != "" {
+		clientCACert, err = ioutil.ReadFile(c.ClientCACert)
+		if err != nil {
+			return shim.TLSProperties{}, fmt.Errorf("error while reading the crypto file: %s", err)
+		}
+	}
+
+	return shim.TLSProperties{
+		Key:           key,
+		Cert:          cert,
+		ClientCACerts: clientCACert,
+	}, nil
+}
+
+// FlagName returns the name of the flag setting key
+func FlagName(key string) string {
+	return strings.Replace(strings.ToLower(strings.TrimPrefix(key, "CHAINCODE_")), "_", "-", -1)
+}
+
+// keyFlag is a flag setting a key
+type keyFlag struct {
+	key    string
+	values map[string]string
+}
+
+func (f *keyFlag) String() string {
+	if f.values == nil {
+		return ""
+	}
+	return f.values[f.key]
+}
+
+func (f *keyFlag) Set(value string) error {
+	f.values[f.key] = value
+	return nil
+}
+
+func (f *keyFlag) IsBoolFlag() bool {
+	return boolKeys[f.key]
+}
+
+// parseFlags returns the keys set by the flags in args
+func parseFlags(args []string, keys []string) (map[string]string, error) {
+	values := make(map[string]string)
+
+	flags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	for _, key := range keys {
+		flags.Var(&keyFlag{key: key, values: values}, FlagName(key), "sets "+key)
+	}
+
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	return values, nil
+}
+
+// readFile returns the keys set in the YAML or JSON configuration file at path
+func readFile(path string, keys []string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file: %s", err)
+	}
+
+	// JSON is valid YAML, both are parsed as YAML
+	var content map[string]interface{}
+	err = yaml.Unmarshal(data, &content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file %s: %s", path, err)
+	}
+
+	known := make(map[string]bool)
+	for _, key := range keys {
+		known[key] = true
+	}
+
+	values := make(map[string]string)
+	var unknown []string
+	for key, value := range content {
+		if !known[key] {
+			unknown = append(unknown, key)
+			continue
+		}
+
+		switch value.(type) {
+		case string, bool, int, float64:
+			values[key] = fmt.Sprint(value)
+		case nil:
+			values[key] = ""
+		default:
+			return nil, fmt.Errorf("the value of %s in configuration file %s must be a string, a number or a boolean", key, path)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown keys in configuration file %s: %s", path, strings.Join(unknown, ", "))
+	}
+
+	return values, nil
+}
+
+// checkFile returns a problem if the file at path, set by key, cannot be read
+func checkFile(key, path string, required bool) string {
+	if path == "" {
+		if required {
+			return fmt.Sprintf("%s must be set when TLS is enabled", key)
+		}
+		return ""
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return fmt.Sprintf("%s file %s cannot be read: %s", key, path, err)
+	}
+	if info.IsDir() {
+		return fmt.Sprintf("%s file %s is a directory", key, path)
+	}
+
+	return ""
+}
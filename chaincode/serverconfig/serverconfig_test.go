@@ -0,0 +1,158 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package serverconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadEnv(t *testing.T) {
+	config, err := load(nil, env(map[string]string{
+		KeyCCID:                  "basic_1.0:abc",
+		KeyAddress:               "0.0.0.0:9999",
+		"CHAINCODE_METRICS_PORT": "9443",
+	}), []string{"CHAINCODE_METRICS_PORT"})
+	require.NoError(t, err)
+	require.Equal(t, "basic_1.0:abc", config.CCID)
+	require.Equal(t, "0.0.0.0:9999", config.Address)
+	require.True(t, config.TLSDisabled)
+	require.Equal(t, "9443", config.String("CHAINCODE_METRICS_PORT", ""))
+	require.Equal(t, "default", config.String("CHAINCODE_UNSET", "default"))
+
+	tlsProps, err := config.TLSProperties()
+	require.NoError(t, err)
+	require.True(t, tlsProps.Disabled)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	configFile := writeFile(t, dir, "chaincode.yaml", `
+CHAINCODE_ID: basic_1.0:file
+CHAINCODE_SERVER_ADDRESS: file:9999
+CHAINCODE_SHUTDOWN_TIMEOUT: 10s
+`)
+
+	config, err := load(
+		[]string{"-config-file", configFile, "-server-address", "flag:9999"},
+		env(map[string]string{KeyAddress: "env:9999", "CHAINCODE_SHUTDOWN_TIMEOUT": "20s"}),
+		[]string{"CHAINCODE_SHUTDOWN_TIMEOUT"},
+	)
+	require.NoError(t, err)
+	require.Equal(t, "basic_1.0:file", config.CCID)
+	require.Equal(t, "flag:9999", config.Address)
+	timeout, err := config.Duration("CHAINCODE_SHUTDOWN_TIMEOUT", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 20*time.Second, timeout)
+}
+
+func TestLoadJSONFile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	configFile := writeFile(t, dir, "chaincode.json", `{"CHAINCODE_ID": "fabcar:1", "CHAINCODE_SERVER_ADDRESS": "fabcar:9999", "CHAINCODE_TLS_DISABLED": true}`)
+
+	config, err := load(nil, env(map[string]string{KeyConfigFile: configFile}), nil)
+	require.NoError(t, err)
+	require.Equal(t, "fabcar:1", config.CCID)
+	require.True(t, config.TLSDisabled)
+
+	configFile = writeFile(t, dir, "typo.json", `{"CHAINCODE_ID": "fabcar:1", "CHAINCODE_SERVER_ADRESS": "fabcar:9999", "CHAINCODE_TLS": {}}`)
+	_, err = load([]string{"-config-file", configFile}, env(nil), nil)
+	require.EqualError(t, err, "unknown keys in configuration file "+configFile+": CHAINCODE_SERVER_ADRESS, CHAINCODE_TLS")
+
+	_, err = load([]string{"-config-file", filepath.Join(dir, "missing.yaml")}, env(nil), nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to read configuration file")
+}
+
+func TestLoadTLS(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	key := writeFile(t, dir, "key.pem", "key")
+	cert := writeFile(t, dir, "cert.pem", "cert")
+
+	config, err := load(
+		[]string{"-id", "basic_1.0:abc", "-server-address", "0.0.0.0:9999", "-tls-disabled=false", "-tls-key", key, "-tls-cert", cert},
+		env(nil), nil,
+	)
+	require.NoError(t, err)
+	require.False(t, config.TLSDisabled)
+
+	tlsProps, err := config.TLSProperties()
+	require.NoError(t, err)
+	require.False(t, tlsProps.Disabled)
+	require.Equal(t, []byte("key"), tlsProps.Key)
+	require.Equal(t, []byte("cert"), tlsProps.Cert)
+	require.Nil(t, tlsProps.ClientCACerts)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	cert := writeFile(t, dir, "cert.pem", "cert")
+
+	_, err := load(nil, env(map[string]string{
+		KeyTLSDisabled:  "no",
+		KeyClientCACert: cert,
+	}), nil)
+	require.EqualError(t, err, "invalid chaincode server configuration: "+
+		"CHAINCODE_ID must be set to the package ID of the chaincode; "+
+		"CHAINCODE_SERVER_ADDRESS must be set to the host and port of the chaincode server; "+
+		"CHAINCODE_TLS_DISABLED must be true or false, got \"no\"")
+
+	_, err = load(nil, env(map[string]string{
+		KeyCCID:         "basic_1.0:abc",
+		KeyAddress:      "0.0.0.0:9999",
+		KeyTLSDisabled:  "false",
+		KeyTLSCert:      dir,
+		KeyClientCACert: filepath.Join(dir, "missing.pem"),
+	}), nil)
+	require.EqualError(t, err, "invalid chaincode server configuration: "+
+		"CHAINCODE_TLS_KEY must be set when TLS is enabled; "+
+		"CHAINCODE_TLS_CERT file "+dir+" is a directory; "+
+		"CHAINCODE_CLIENT_CA_CERT file "+filepath.Join(dir, "missing.pem")+" cannot be read: no such file or directory")
+
+	_, err = load([]string{"-unknown"}, env(nil), nil)
+	require.EqualError(t, err, "flag provided but not defined: -unknown")
+
+	config, err := load([]string{"-id", "basic_1.0:abc", "-server-address", "0.0.0.0:9999", "-shutdown-timeout", "soon"}, env(nil), []string{"CHAINCODE_SHUTDOWN_TIMEOUT"})
+	require.NoError(t, err)
+	_, err = config.Duration("CHAINCODE_SHUTDOWN_TIMEOUT", time.Minute)
+	require.EqualError(t, err, "CHAINCODE_SHUTDOWN_TIMEOUT must be a duration such as 30s, got \"soon\"")
+}
+
+func TestFlagName(t *testing.T) {
+	require.Equal(t, "id", FlagName(KeyCCID))
+	require.Equal(t, "server-address", FlagName(KeyAddress))
+	require.Equal(t, "client-ca-cert", FlagName(KeyClientCACert))
+}
+
+// env returns a lookup function of the environment variables in vars
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := vars[key]
+		return value, ok
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "serverconfig")
+	require.NoError(t, err)
+
+	return dir
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+
+	return path
+}
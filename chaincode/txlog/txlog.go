@@ -0,0 +1,186 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package txlog logs the transactions of contractapi chaincodes as JSON lines, and recovers the
+// panics of transaction functions into errors.
+//
+// The transactions are logged by the shim.Chaincode returned by Chaincode, which wraps the
+// contractapi chaincode and logs each transaction once, when it returns, with its outcome. The
+// before and unknown transaction hooks set by Hook add the client MSP of the transaction and the
+// error of an unknown function. The after transaction hook is not used: contractapi only calls
+// it when the transaction function succeeds, and the transaction can still fail after it. Hook
+// requires Chaincode, the hooks of a chaincode which is not wrapped log nothing:
+//
+//	logger := txlog.New(os.Stdout)
+//	logger.Hook(&contract.Contract)
+//	chaincode, err := contractapi.NewChaincode(contract)
+//	...
+//	err = shim.Start(logger.Chaincode(chaincode))
+package txlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Entry is the log line of a transaction
+type Entry struct {
+	Time     string `json:"time"`
+	Level    string `json:"level"`
+	TxID     string `json:"txID"`
+	Channel  string `json:"channel"`
+	Function string `json:"function"`
+	MSPID    string `json:"mspID,omitempty"`
+	// Duration is the duration of the transaction in milliseconds
+	Duration float64 `json:"durationMs"`
+	Error    string  `json:"error,omitempty"`
+	// Stack is the stack trace of a panic
+	Stack string `json:"stack,omitempty"`
+
+	start time.Time
+}
+
+// Logger writes the entries of the transactions of a chaincode
+type Logger struct {
+	mu  sync.Mutex
+	out io.Writer
+	now func() time.Time
+
+	// transactions are the entries of the transactions in progress, by channel and txID
+	transactions sync.Map
+}
+
+// New returns a logger writing the entries to out
+func New(out io.Writer) *Logger {
+	return &Logger{out: out, now: time.Now}
+}
+
+// Hook sets the before and unknown transaction hooks of contract. The transactions are only
+// logged if the contractapi chaincode of contract is wrapped by Chaincode.
+func (l *Logger) Hook(contract *contractapi.Contract) {
+	contract.BeforeTransaction = l.BeforeTransaction
+	contract.UnknownTransaction = l.UnknownTransaction
+}
+
+// BeforeTransaction records the client MSP of the transaction, if it is invoked through the
+// chaincode returned by Chaincode
+func (l *Logger) BeforeTransaction(ctx contractapi.TransactionContextInterface) {
+	entry, ok := l.transactions.Load(transactionKey(ctx.GetStub()))
+	if !ok {
+		return
+	}
+	entry.(*Entry).MSPID = creatorMSPID(ctx.GetStub())
+}
+
+// UnknownTransaction returns the error of a function that is not in the contract, which is
+// logged as the error of the transaction
+func (l *Logger) UnknownTransaction(ctx contractapi.TransactionContextInterface) error {
+	function, _ := ctx.GetStub().GetFunctionAndParameters()
+	return fmt.Errorf("function %s not found", function)
+}
+
+// Chaincode returns a chaincode calling cc, which logs the transactions of cc, and recovers
+// its panics into errors
+func (l *Logger) Chaincode(cc shim.Chaincode) shim.Chaincode {
+	return &chaincode{cc: cc, logger: l}
+}
+
+type chaincode struct {
+	cc     shim.Chaincode
+	logger *Logger
+}
+
+func (c *chaincode) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	return c.logger.call(stub, c.cc.Init)
+}
+
+func (c *chaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	return c.logger.call(stub, c.cc.Invoke)
+}
+
+// call calls f with stub, logs the transaction when it returns, and recovers its panics
+func (l *Logger) call(stub shim.ChaincodeStubInterface, f func(shim.ChaincodeStubInterface) peer.Response) (response peer.Response) {
+	entry := l.entry(stub)
+	defer l.transactions.Delete(transactionKey(stub))
+
+	defer func() {
+		if r := recover(); r != nil {
+			entry.Error = fmt.Sprintf("panic: %v", r)
+			entry.Stack = string(debug.Stack())
+			l.write(entry, "error")
+
+			// the details of the panic are in the log of the chaincode, not in the response
+			response = shim.Error(fmt.Sprintf("internal error in function %s of transaction %s", entry.Function, entry.TxID))
+		}
+	}()
+
+	response = f(stub)
+	if response.Status >= shim.ERRORTHRESHOLD {
+		entry.Error = response.Message
+		l.write(entry, "error")
+	} else {
+		l.write(entry, "info")
+	}
+
+	return response
+}
+
+// entry returns a new entry for the transaction of stub, recorded until the transaction ends
+func (l *Logger) entry(stub shim.ChaincodeStubInterface) *Entry {
+	function, _ := stub.GetFunctionAndParameters()
+	entry := &Entry{
+		TxID:     stub.GetTxID(),
+		Channel:  stub.GetChannelID(),
+		Function: function,
+		start:    l.now(),
+	}
+	l.transactions.Store(transactionKey(stub), entry)
+
+	return entry
+}
+
+// write writes entry as a JSON line, ending the transaction
+func (l *Logger) write(entry *Entry, level string) {
+	now := l.now()
+	entry.Time = now.UTC().Format(time.RFC3339Nano)
+	entry.Level = level
+	entry.Duration = float64(now.Sub(entry.start)) / float64(time.Millisecond)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// the logs must not fail the transaction, errors writing them are ignored
+	_ = json.NewEncoder(l.out).Encode(entry)
+}
+
+// creatorMSPID returns the MSP of the creator of the transaction, or an empty string if the
+// creator cannot be parsed
+func creatorMSPID(stub shim.ChaincodeStubInterface) string {
+	creator, err := stub.GetCreator()
+	if err != nil {
+		return ""
+	}
+
+	identity := &msp.SerializedIdentity{}
+	err = proto.Unmarshal(creator, identity)
+	if err != nil {
+		return ""
+	}
+
+	return identity.GetMspid()
+}
+
+func transactionKey(stub shim.ChaincodeStubInterface) string {
+	return stub.GetChannelID() + " " + stub.GetTxID()
+}
@@ -0,0 +1,201 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/stretchr/testify/require"
+)
+
+type testContract struct {
+	contractapi.Contract
+}
+
+func (c *testContract) Succeed(ctx contractapi.TransactionContextInterface) string {
+	return "done"
+}
+
+func (c *testContract) Fail(ctx contractapi.TransactionContextInterface) error {
+	return errors.New("failed on purpose")
+}
+
+func (c *testContract) Panic(ctx contractapi.TransactionContextInterface) error {
+	var assets map[string]string
+	assets["asset1"] = "blue"
+	return nil
+}
+
+func TestChaincode(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(&out)
+	start := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	calls := 0
+	logger.now = func() time.Time {
+		calls++
+		return start.Add(time.Duration(calls) * 5 * time.Millisecond)
+	}
+
+	contract := &testContract{}
+	logger.Hook(&contract.Contract)
+	chaincode, err := contractapi.NewChaincode(contract)
+	require.NoError(t, err)
+
+	stub := shimtest.NewMockStub("txlog", logger.Chaincode(chaincode))
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: "Org1MSP"})
+	require.NoError(t, err)
+	stub.Creator = creator
+	stub.ChannelID = "mychannel"
+
+	response := stub.MockInvoke("tx1", [][]byte{[]byte("Succeed")})
+	require.EqualValues(t, 200, response.Status, response.Message)
+	require.Equal(t, "done", string(response.Payload))
+
+	response = stub.MockInvoke("tx2", [][]byte{[]byte("Fail")})
+	require.EqualValues(t, 500, response.Status)
+	require.Equal(t, "failed on purpose", response.Message)
+
+	response = stub.MockInvoke("tx3", [][]byte{[]byte("Panic")})
+	require.EqualValues(t, 500, response.Status)
+	require.Equal(t, "internal error in function Panic of transaction tx3", response.Message)
+
+	response = stub.MockInvoke("tx4", [][]byte{[]byte("Missing")})
+	require.EqualValues(t, 500, response.Status)
+	require.Equal(t, "function Missing not found", response.Message)
+
+	entries := readEntries(t, &out)
+	require.Len(t, entries, 4)
+	require.Equal(t, Entry{
+		Time:     "2021-03-01T10:00:00.01Z",
+		Level:    "info",
+		TxID:     "tx1",
+		Channel:  "mychannel",
+		Function: "Succeed",
+		MSPID:    "Org1MSP",
+		Duration: 5,
+	}, entries[0])
+	require.Equal(t, "error", entries[1].Level)
+	require.Equal(t, "Fail", entries[1].Function)
+	require.Equal(t, "Org1MSP", entries[1].MSPID)
+	require.Equal(t, "failed on purpose", entries[1].Error)
+	require.Empty(t, entries[1].Stack)
+	require.Equal(t, "error", entries[2].Level)
+	require.Equal(t, "panic: assignment to entry in nil map", entries[2].Error)
+	require.Contains(t, entries[2].Stack, "(*testContract).Panic")
+	require.Equal(t, "tx4", entries[3].TxID)
+	require.Equal(t, "function Missing not found", entries[3].Error)
+
+	var inProgress int
+	logger.transactions.Range(func(_, _ interface{}) bool {
+		inProgress++
+		return true
+	})
+	require.Zero(t, inProgress)
+}
+
+func TestChaincodeUnknownCreator(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(&out)
+	contract := &testContract{}
+	logger.Hook(&contract.Contract)
+	chaincode, err := contractapi.NewChaincode(contract)
+	require.NoError(t, err)
+
+	stub := shimtest.NewMockStub("txlog", logger.Chaincode(chaincode))
+	stub.Creator = []byte("not an identity")
+	response := stub.MockInvoke("tx1", [][]byte{[]byte("Succeed")})
+	require.EqualValues(t, 200, response.Status, response.Message)
+
+	entries := readEntries(t, &out)
+	require.Len(t, entries, 1)
+	require.Empty(t, entries[0].MSPID)
+	require.NotContains(t, out.String(), "mspID")
+}
+
+func TestChaincodeFailedAfterTransaction(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(&out)
+	contract := &testContract{}
+	logger.Hook(&contract.Contract)
+	contract.AfterTransaction = func(ctx contractapi.TransactionContextInterface, result interface{}) error {
+		return errors.New("rejected after the transaction")
+	}
+	chaincode, err := contractapi.NewChaincode(contract)
+	require.NoError(t, err)
+
+	stub := shimtest.NewMockStub("txlog", logger.Chaincode(chaincode))
+	response := stub.MockInvoke("tx1", [][]byte{[]byte("Succeed")})
+	require.EqualValues(t, 500, response.Status)
+
+	// the transaction is logged once, with the error returned to the client
+	entries := readEntries(t, &out)
+	require.Len(t, entries, 1)
+	require.Equal(t, "error", entries[0].Level)
+	require.Equal(t, "rejected after the transaction", entries[0].Error)
+}
+
+func TestChaincodeInit(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(&out)
+	contract := &testContract{}
+	logger.Hook(&contract.Contract)
+	chaincode, err := contractapi.NewChaincode(contract)
+	require.NoError(t, err)
+
+	stub := shimtest.NewMockStub("txlog", logger.Chaincode(chaincode))
+	response := stub.MockInit("tx1", [][]byte{[]byte("Panic")})
+	require.EqualValues(t, 500, response.Status)
+	require.Equal(t, "internal error in function Panic of transaction tx1", response.Message)
+
+	entries := readEntries(t, &out)
+	require.Len(t, entries, 1)
+	require.Equal(t, "error", entries[0].Level)
+	require.Equal(t, "panic: assignment to entry in nil map", entries[0].Error)
+}
+
+// The hooks log nothing, and record nothing, without the chaincode returned by Chaincode
+func TestHookWithoutChaincode(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(&out)
+	contract := &testContract{}
+	logger.Hook(&contract.Contract)
+	chaincode, err := contractapi.NewChaincode(contract)
+	require.NoError(t, err)
+
+	stub := shimtest.NewMockStub("txlog", chaincode)
+	response := stub.MockInvoke("tx1", [][]byte{[]byte("Succeed")})
+	require.EqualValues(t, 200, response.Status, response.Message)
+	response = stub.MockInvoke("tx2", [][]byte{[]byte("Fail")})
+	require.EqualValues(t, 500, response.Status)
+	require.Equal(t, "failed on purpose", response.Message)
+
+	require.Empty(t, out.String())
+	var inProgress int
+	logger.transactions.Range(func(_, _ interface{}) bool {
+		inProgress++
+		return true
+	})
+	require.Zero(t, inProgress)
+}
+
+func readEntries(t *testing.T, out *bytes.Buffer) []Entry {
+	var entries []Entry
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var entry Entry
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), line)
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
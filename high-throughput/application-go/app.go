@@ -7,64 +7,157 @@ SPDX-License-Identifier: Apache-2.0
 package main
 
 import (
+	"flag"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	f "github.com/hyperledger/fabric-samples/high-throughput/application-go/functions"
+	"github.com/hyperledger/fabric-samples/test-application/go/client"
+	"github.com/hyperledger/fabric-samples/test-application/go/identity"
 )
 
 func main() {
 
-	var function, variableName, change, sign string
+	var function, variableName, change, sign, variableType string
 
-	if len(os.Args) <= 2 {
-		log.Println("Usage: function variableName")
-		log.Fatalf("functions: update manyUpdates manyUpdatesTraditional get prune delete")
-	} else if (os.Args[1] == "update" || os.Args[1] == "manyUpdates" || os.Args[1] == "manyUpdatesTraditional") && len(os.Args) < 5 {
+	id := identity.Flags(flag.CommandLine, identity.TestNetworkUser(filepath.Join("..", "..")))
+	calls := client.Flags(flag.CommandLine, client.DefaultOptions())
+	flag.Parse()
+	args := flag.Args()
+
+	if len(args) > 0 && args[0] == "load" {
+		runLoad(id, calls, args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "compact" {
+		runCompact(id, calls, args[1:])
+		return
+	}
+
+	if len(args) <= 1 {
+		log.Println("Usage: [flags] function variableName")
+		log.Fatalf("functions: update manyUpdates manyUpdatesTraditional get prune compact delete setscale setfloor load")
+	} else if (args[0] == "update" || args[0] == "manyUpdates" || args[0] == "manyUpdatesTraditional") && len(args) < 4 {
 		log.Fatalf("error: provide value and operation")
-	} else if len(os.Args) == 3 {
-		function = os.Args[1]
-		variableName = os.Args[2]
-	} else if len(os.Args) == 5 {
-		function = os.Args[1]
-		variableName = os.Args[2]
-		change = os.Args[3]
-		sign = os.Args[4]
+	} else if args[0] == "setfloor" && len(args) != 4 {
+		log.Fatalf("error: provide floor and number of shards")
+	} else if len(args) == 2 {
+		function = args[0]
+		variableName = args[1]
+	} else if len(args) == 4 || len(args) == 5 {
+		function = args[0]
+		variableName = args[1]
+		change = args[2]
+		sign = args[3]
+		if len(args) == 5 {
+			variableType = args[4]
+		}
 	}
 
 	// Handle different functions
 	if function == "update" {
-		result, err := f.Update(function, variableName, change, sign)
+		result, err := f.Update(id, calls, function, variableName, change, sign, variableType)
 		if err != nil {
 			log.Fatalf("error: %v", err)
 		}
 		log.Println("Value of variable", string(variableName), ": ", string(result))
 
-	} else if function == "delete" || function == "prune" || function == "delstandard" {
-		result, err := f.DeletePrune(function, variableName)
+	} else if function == "delete" || function == "prune" || function == "delstandard" || function == "setscale" {
+		result, err := f.DeletePrune(id, calls, function, variableName)
+		if err != nil {
+			log.Fatalf("error: %v", err)
+		}
+		log.Println(string(result))
+	} else if function == "setfloor" {
+		result, err := f.DeletePrune(id, calls, function, variableName, change, sign)
 		if err != nil {
 			log.Fatalf("error: %v", err)
 		}
 		log.Println(string(result))
 	} else if function == "get" || function == "getstandard" {
-		result, err := f.Query(function, variableName)
+		result, err := f.Query(id, calls, function, variableName)
 		if err != nil {
 			log.Fatalf("error: %v", err)
 		}
 		log.Println("Value of variable", string(variableName), ": ", string(result))
 	} else if function == "manyUpdates" {
 		log.Println("submitting 1000 concurrent updates...")
-		result, err := f.ManyUpdates("update", variableName, change, sign)
-		if err != nil {
-			log.Fatalf("error: %v", err)
-		}
-		log.Println("Final value of variable", string(variableName), ": ", string(result))
+		result, err := f.ManyUpdates(id, calls, "update", variableName, change, sign)
+		logManyUpdates(variableName, result, err)
 	} else if function == "manyUpdatesTraditional" {
 		log.Println("submitting 1000 concurrent updates...")
-		result, err := f.ManyUpdates("putstandard", variableName, change, sign)
-		if err != nil {
-			log.Fatalf("error: %v", err)
-		}
+		result, err := f.ManyUpdates(id, calls, "putstandard", variableName, change, sign)
+		logManyUpdates(variableName, result, err)
+	}
+}
+
+// logManyUpdates logs the final value of a variable after many updates, and exits if some of them
+// failed
+func logManyUpdates(variableName string, result []byte, err error) {
+	if result != nil {
 		log.Println("Final value of variable", string(variableName), ": ", string(result))
 	}
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+}
+
+// runLoad submits transactions with the functions selected by the load flags in args, and prints
+// the summary of their outcome
+func runLoad(id *identity.Options, calls *client.Options, args []string) {
+	flags := flag.NewFlagSet("load", flag.ExitOnError)
+	opts := f.LoadOptions{}
+	flags.IntVar(&opts.Transactions, "transactions", 1000, "number of transactions of each function, 0 to submit transactions until the duration is over")
+	flags.IntVar(&opts.Concurrency, "concurrency", 50, "maximum number of transactions waiting to be committed")
+	flags.Float64Var(&opts.Rate, "rate", 0, "maximum number of transactions started per second, 0 for no limit")
+	flags.DurationVar(&opts.Duration, "duration", 0, "time after which no more transactions are started, 0 for no limit")
+	functions := flags.String("functions", "update,putstandard", "comma separated functions submitting the transactions, in turn")
+	flags.Usage = func() {
+		log.Println("Usage: [flags] load [load flags] variableName value operation")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 3 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	variableName, change, sign := flags.Arg(0), flags.Arg(1), flags.Arg(2)
+
+	log.Printf("submitting transactions to %s with %s...", variableName, *functions)
+	results, err := f.Load(id, calls, strings.Split(*functions, ","), variableName, change, sign, opts)
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+
+	err = f.WriteLoadSummary(os.Stdout, results)
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+}
+
+// runCompact compacts the deltas of the variable in args selected by the compact flags
+func runCompact(id *identity.Options, calls *client.Options, args []string) {
+	flags := flag.NewFlagSet("compact", flag.ExitOnError)
+	minAge := flags.Duration("min-age", time.Minute, "minimum age of the compacted deltas, rounded down to seconds")
+	limit := flags.Int("limit", 1000, "maximum number of deltas compacted")
+	flags.Usage = func() {
+		log.Println("Usage: [flags] compact [compact flags] variableName")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	result, err := f.Compact(id, calls, flags.Arg(0), *minAge, *limit)
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	log.Println(string(result))
 }
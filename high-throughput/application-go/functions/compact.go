@@ -0,0 +1,40 @@
+/*
+Copyright 2020 IBM All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package functions
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/test-application/go/client"
+	"github.com/hyperledger/fabric-samples/test-application/go/identity"
+)
+
+// Compact compacts up to limit deltas of a variable older than minAge into its checkpoint. The
+// deltas are listed by evaluating compactable, so that the submitted compact transaction does not
+// conflict with concurrent updates.
+func Compact(id *identity.Options, calls *client.Options, variableName string, minAge time.Duration, limit int) ([]byte, error) {
+
+	gw, contract, err := connect(id, calls)
+	if err != nil {
+		return nil, err
+	}
+	defer gw.Close()
+
+	age := strconv.FormatInt(int64(minAge/time.Second), 10)
+	deltas, err := contract.EvaluateTransaction(transaction("compactable"), variableName, age, strconv.Itoa(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate transaction: %v", err)
+	}
+
+	result, err := contract.SubmitTransaction(transaction("compact"), variableName, age, string(deltas))
+	if err != nil {
+		return result, fmt.Errorf("failed to Submit transaction: %v", err)
+	}
+	return result, err
+}
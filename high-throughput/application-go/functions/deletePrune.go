@@ -8,60 +8,22 @@ package functions
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 
-	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
-	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+	"github.com/hyperledger/fabric-samples/test-application/go/client"
+	"github.com/hyperledger/fabric-samples/test-application/go/identity"
 )
 
-// DeletePrune deletes or prunes a variable
-func DeletePrune(function, variableName string) ([]byte, error) {
+// DeletePrune deletes or prunes a variable, sets the floor of a variable, or sets the scale of the
+// variables, with the arguments of the function
+func DeletePrune(id *identity.Options, calls *client.Options, function string, args ...string) ([]byte, error) {
 
-	err := os.Setenv("DISCOVERY_AS_LOCALHOST", "true")
+	gw, contract, err := connect(id, calls)
 	if err != nil {
-		return nil, fmt.Errorf("error setting DISCOVERY_AS_LOCALHOST environemnt variable: %v", err)
-	}
-
-	wallet, err := gateway.NewFileSystemWallet("wallet")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create wallet: %v", err)
-	}
-
-	if !wallet.Exists("appUser") {
-		err := populateWallet(wallet)
-		if err != nil {
-			return nil, fmt.Errorf("failed to populate wallet contents: %v", err)
-		}
-	}
-
-	ccpPath := filepath.Join(
-		"..",
-		"..",
-		"test-network",
-		"organizations",
-		"peerOrganizations",
-		"org1.example.com",
-		"connection-org1.yaml",
-	)
-
-	gw, err := gateway.Connect(
-		gateway.WithConfig(config.FromFile(filepath.Clean(ccpPath))),
-		gateway.WithIdentity(wallet, "appUser"),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to gateway: %v", err)
+		return nil, err
 	}
 	defer gw.Close()
 
-	network, err := gw.GetNetwork("mychannel")
-	if err != nil {
-		return nil, fmt.Errorf("failed to get network: %v", err)
-	}
-
-	contract := network.GetContract("bigdatacc")
-
-	result, err := contract.SubmitTransaction(function, variableName)
+	result, err := contract.SubmitTransaction(transaction(function), args...)
 	if err != nil {
 		return result, fmt.Errorf("failed to Submit transaction: %v", err)
 	}
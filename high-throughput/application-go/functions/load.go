@@ -0,0 +1,247 @@
+/*
+Copyright 2020 IBM All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/test-application/go/client"
+	"github.com/hyperledger/fabric-samples/test-application/go/identity"
+)
+
+// LoadOptions set the transactions submitted by Load for each function
+type LoadOptions struct {
+	// Transactions is the number of transactions, or zero to submit transactions until the
+	// Duration is over
+	Transactions int
+	// Concurrency is the maximum number of transactions waiting to be committed
+	Concurrency int
+	// Rate is the maximum number of transactions started per second, or zero for no limit
+	Rate float64
+	// Duration is the time after which no more transactions are started, or zero for no limit
+	Duration time.Duration
+}
+
+// LoadResult is the outcome of the transactions submitted to a function
+type LoadResult struct {
+	Function  string
+	Submitted int
+	Succeeded int
+	// Failures are the number of failed transactions of each class
+	Failures map[client.Class]int
+	// Latencies are the sorted durations of the submitted transactions, until they are
+	// committed or they fail
+	Latencies []time.Duration
+	Elapsed   time.Duration
+}
+
+// Conflicts returns the number of transactions invalidated by a read conflict
+func (r *LoadResult) Conflicts() int {
+	return r.Failures[client.Conflict]
+}
+
+// Failed returns the number of transactions which failed for another reason than a read conflict
+func (r *LoadResult) Failed() int {
+	return r.Submitted - r.Succeeded - r.Conflicts()
+}
+
+// Throughput returns the number of successful transactions per second
+func (r *LoadResult) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Succeeded) / r.Elapsed.Seconds()
+}
+
+// Percentile returns the latency under which are p percent of the transactions
+func (r *LoadResult) Percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	i := int(p/100*float64(len(r.Latencies))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(r.Latencies) {
+		i = len(r.Latencies) - 1
+	}
+	return r.Latencies[i]
+}
+
+// Load submits transactions adding change to variableName with each function in turn, over a
+// single connection to the gateway, and returns their outcome. The transactions are not retried.
+func Load(id *identity.Options, calls *client.Options, functions []string, variableName, change, sign string, opts LoadOptions) ([]*LoadResult, error) {
+	err := opts.check()
+	if err != nil {
+		return nil, err
+	}
+
+	gw, contract, err := connect(id, loadCalls(calls))
+	if err != nil {
+		return nil, err
+	}
+	defer gw.Close()
+
+	results := make([]*LoadResult, 0, len(functions))
+	for _, function := range functions {
+		results = append(results, load(contract, function, loadArgs(function, variableName, change, sign), opts))
+	}
+
+	return results, nil
+}
+
+// loadCalls returns the options of the calls of Load, which submit each transaction once, so
+// that every read conflict is counted and the latencies do not include the backoffs of retries
+func loadCalls(calls *client.Options) *client.Options {
+	once := *calls
+	once.Attempts = 1
+	return &once
+}
+
+// loadArgs returns the arguments of the transactions of function adding change to variableName
+func loadArgs(function, variableName, change, sign string) []string {
+	if function == "putstandard" {
+		return []string{variableName, change}
+	}
+	return []string{variableName, change, sign, ""}
+}
+
+func (o *LoadOptions) check() error {
+	if o.Transactions < 0 || o.Concurrency < 0 || o.Rate < 0 || o.Duration < 0 {
+		return errors.New("the number of transactions, the concurrency, the rate and the duration cannot be negative")
+	}
+	if o.Transactions == 0 && o.Duration == 0 {
+		return errors.New("a number of transactions or a duration is required")
+	}
+	if o.Concurrency == 0 {
+		return errors.New("the concurrency must be at least 1")
+	}
+	return nil
+}
+
+// load submits the transactions of function with up to Concurrency workers, which are started
+// at the Rate until the Transactions are started or the Duration is over
+func load(contract client.Contract, function string, args []string, opts LoadOptions) *LoadResult {
+	result := &LoadResult{Function: function, Failures: make(map[client.Class]int)}
+	var mutex sync.Mutex
+
+	starts := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range starts {
+				start := time.Now()
+				_, err := contract.SubmitTransaction(transaction(function), args...)
+				latency := time.Since(start)
+
+				mutex.Lock()
+				result.Submitted++
+				result.Latencies = append(result.Latencies, latency)
+				if err != nil {
+					result.Failures[client.Classify(err)]++
+				} else {
+					result.Succeeded++
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	begin := time.Now()
+	var deadline <-chan time.Time
+	if opts.Duration > 0 {
+		timer := time.NewTimer(opts.Duration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	var ticks <-chan time.Time
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+dispatch:
+	for started := 0; opts.Transactions == 0 || started < opts.Transactions; started++ {
+		if ticks != nil && started > 0 {
+			select {
+			case <-ticks:
+			case <-deadline:
+				break dispatch
+			}
+		}
+		select {
+		case starts <- struct{}{}:
+		case <-deadline:
+			break dispatch
+		}
+	}
+	close(starts)
+	wg.Wait()
+
+	result.Elapsed = time.Since(begin)
+	sort.Slice(result.Latencies, func(i, j int) bool {
+		return result.Latencies[i] < result.Latencies[j]
+	})
+
+	return result
+}
+
+// WriteLoadSummary writes a table of the outcome of the transactions submitted to each function,
+// followed by their failures and by the throughput of the first function compared to the others
+func WriteLoadSummary(w io.Writer, results []*LoadResult) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "FUNCTION\tSUBMITTED\tSUCCEEDED\tCONFLICTS\tFAILED\tELAPSED\tTPS\tP50\tP95\tP99\tMAX")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%v\t%.1f\t%v\t%v\t%v\t%v\n",
+			r.Function, r.Submitted, r.Succeeded, r.Conflicts(), r.Failed(), round(r.Elapsed), r.Throughput(),
+			round(r.Percentile(50)), round(r.Percentile(95)), round(r.Percentile(99)), round(r.Percentile(100)))
+	}
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if len(r.Failures) > 0 {
+			_, err = fmt.Fprintf(w, "%s failed %s\n", r.Function, describeFailures(r.Failures))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(results) < 2 {
+		return nil
+	}
+	base := results[0]
+	for _, r := range results[1:] {
+		if r.Throughput() == 0 {
+			_, err = fmt.Fprintf(w, "%s committed %.1f transactions per second, %s committed none\n", base.Function, base.Throughput(), r.Function)
+		} else {
+			_, err = fmt.Fprintf(w, "%s committed %.1f times the transactions per second of %s\n", base.Function, base.Throughput()/r.Throughput(), r.Function)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// round rounds a duration to the millisecond
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond)
+}
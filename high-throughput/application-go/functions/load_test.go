@@ -0,0 +1,162 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package functions
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-samples/test-application/go/client"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+)
+
+var mvccConflict = status.New(status.EventServerStatus, int32(peer.TxValidationCode_MVCC_READ_CONFLICT), "received invalid transaction", nil)
+
+// fakeContract commits the transactions after a delay, fails every conflictEvery-th transaction
+// with a read conflict, and records the maximum number of concurrent transactions
+type fakeContract struct {
+	delay         time.Duration
+	conflictEvery int
+
+	mutex       sync.Mutex
+	calls       int
+	inFlight    int
+	maxInFlight int
+}
+
+func (c *fakeContract) EvaluateTransaction(name string, args ...string) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c *fakeContract) SubmitTransaction(name string, args ...string) ([]byte, error) {
+	c.mutex.Lock()
+	c.calls++
+	call := c.calls
+	c.inFlight++
+	if c.inFlight > c.maxInFlight {
+		c.maxInFlight = c.inFlight
+	}
+	c.mutex.Unlock()
+
+	time.Sleep(c.delay)
+
+	c.mutex.Lock()
+	c.inFlight--
+	c.mutex.Unlock()
+
+	if c.conflictEvery > 0 && call%c.conflictEvery == 0 {
+		return nil, mvccConflict
+	}
+	return nil, nil
+}
+
+func TestLoad(t *testing.T) {
+	contract := &fakeContract{delay: 5 * time.Millisecond, conflictEvery: 4}
+	result := load(contract, "putstandard", []string{"myvar", "1", "+"}, LoadOptions{Transactions: 100, Concurrency: 10})
+
+	if result.Submitted != 100 || result.Succeeded != 75 || result.Conflicts() != 25 || result.Failed() != 0 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if contract.maxInFlight != 10 {
+		t.Errorf("expected 10 concurrent transactions, got %d", contract.maxInFlight)
+	}
+	if len(result.Latencies) != 100 || result.Percentile(0) < contract.delay || result.Percentile(50) > result.Percentile(100) {
+		t.Errorf("unexpected latencies %v", result.Latencies)
+	}
+	if result.Throughput() <= 0 {
+		t.Errorf("unexpected throughput %f", result.Throughput())
+	}
+}
+
+func TestLoadCountsEveryConflict(t *testing.T) {
+	calls := client.DefaultOptions()
+	contract := &fakeContract{conflictEvery: 2}
+	result := load(client.New(contract, *loadCalls(&calls)), "putstandard", []string{"myvar", "1"}, LoadOptions{Transactions: 10, Concurrency: 1})
+
+	if contract.calls != 10 || result.Conflicts() != 5 {
+		t.Errorf("expected 10 transactions submitted once with 5 conflicts, got %d calls and %+v", contract.calls, result)
+	}
+	if calls.Attempts != 5 {
+		t.Errorf("expected the options of the other commands to be kept, got %d attempts", calls.Attempts)
+	}
+}
+
+func TestLoadRateAndDuration(t *testing.T) {
+	contract := &fakeContract{}
+	start := time.Now()
+	result := load(contract, "update", nil, LoadOptions{Transactions: 5, Concurrency: 5, Rate: 100})
+	if result.Submitted != 5 {
+		t.Errorf("expected 5 transactions, got %d", result.Submitted)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("5 transactions at 100 per second were submitted in %v", elapsed)
+	}
+
+	result = load(contract, "update", nil, LoadOptions{Concurrency: 1, Rate: 100, Duration: 100 * time.Millisecond})
+	if result.Submitted < 5 || result.Submitted > 15 {
+		t.Errorf("expected about 10 transactions in 100ms at 100 per second, got %d", result.Submitted)
+	}
+}
+
+func TestLoadOptions(t *testing.T) {
+	for _, test := range []struct {
+		opts     LoadOptions
+		expected string
+	}{
+		{LoadOptions{Transactions: 10, Concurrency: 1}, ""},
+		{LoadOptions{Duration: time.Second, Concurrency: 1}, ""},
+		{LoadOptions{Concurrency: 1}, "a number of transactions or a duration is required"},
+		{LoadOptions{Transactions: 10}, "the concurrency must be at least 1"},
+		{LoadOptions{Transactions: 10, Concurrency: 1, Rate: -1}, "the number of transactions, the concurrency, the rate and the duration cannot be negative"},
+	} {
+		err := test.opts.check()
+		if test.expected == "" && err != nil || test.expected != "" && (err == nil || err.Error() != test.expected) {
+			t.Errorf("expected error %q for %+v, got %v", test.expected, test.opts, err)
+		}
+	}
+}
+
+func TestWriteLoadSummary(t *testing.T) {
+	results := []*LoadResult{
+		{
+			Function:  "update",
+			Submitted: 4,
+			Succeeded: 4,
+			Latencies: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond, 40 * time.Millisecond},
+			Elapsed:   time.Second,
+		},
+		{
+			Function:  "putstandard",
+			Submitted: 4,
+			Succeeded: 1,
+			Failures:  map[client.Class]int{client.Conflict: 2, client.Timeout: 1},
+			Latencies: []time.Duration{10 * time.Millisecond, 10 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond},
+			Elapsed:   500 * time.Millisecond,
+		},
+	}
+
+	var out bytes.Buffer
+	err := WriteLoadSummary(&out, results)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := strings.Join([]string{
+		"FUNCTION     SUBMITTED  SUCCEEDED  CONFLICTS  FAILED  ELAPSED  TPS  P50   P95   P99   MAX",
+		"update       4          4          0          0       1s       4.0  20ms  40ms  40ms  40ms",
+		"putstandard  4          1          2          1       500ms    2.0  10ms  50ms  50ms  50ms",
+		"putstandard failed 3 transactions: 2 read conflict, 1 timeout",
+		"update committed 2.0 times the transactions per second of putstandard",
+		"",
+	}, "\n")
+	if out.String() != expected {
+		t.Errorf("expected summary:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
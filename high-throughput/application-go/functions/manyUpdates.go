@@ -8,79 +8,48 @@ package functions
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
-	"sync"
+	"sort"
+	"strings"
 
-	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
-	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+	"github.com/hyperledger/fabric-samples/test-application/go/client"
+	"github.com/hyperledger/fabric-samples/test-application/go/identity"
 )
 
 // ManyUpdates allows you to push many cuncurrent updates to a variable
-func ManyUpdates(function, variableName, change, sign string) ([]byte, error) {
+func ManyUpdates(id *identity.Options, calls *client.Options, function, variableName, change, sign string) ([]byte, error) {
 
-	err := os.Setenv("DISCOVERY_AS_LOCALHOST", "true")
+	gw, contract, err := connect(id, calls)
 	if err != nil {
-		return nil, fmt.Errorf("error setting DISCOVERY_AS_LOCALHOST environemnt variable: %v", err)
-	}
-
-	wallet, err := gateway.NewFileSystemWallet("wallet")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create wallet: %v", err)
-	}
-
-	if !wallet.Exists("appUser") {
-		err := populateWallet(wallet)
-		if err != nil {
-			return nil, fmt.Errorf("failed to populate wallet contents: %v", err)
-		}
+		return nil, err
 	}
+	defer gw.Close()
 
-	ccpPath := filepath.Join(
-		"..",
-		"..",
-		"test-network",
-		"organizations",
-		"peerOrganizations",
-		"org1.example.com",
-		"connection-org1.yaml",
-	)
+	loaded := load(contract, function, loadArgs(function, variableName, change, sign), LoadOptions{Transactions: 1000, Concurrency: 1000})
 
-	gw, err := gateway.Connect(
-		gateway.WithConfig(config.FromFile(filepath.Clean(ccpPath))),
-		gateway.WithIdentity(wallet, "appUser"),
-	)
+	result, err := getValue(contract, variableName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to gateway: %v", err)
+		return nil, err
 	}
-	defer gw.Close()
-
-	network, err := gw.GetNetwork("mychannel")
-	if err != nil {
-		return nil, fmt.Errorf("failed to get network: %v", err)
+	if len(loaded.Failures) > 0 {
+		return result, fmt.Errorf("failed to submit %s", describeFailures(loaded.Failures))
 	}
+	return result, err
+}
 
-	contract := network.GetContract("bigdatacc")
-
-	var wg sync.WaitGroup
-
-	for i := 0; i < 1000; i++ {
-		wg.Add(1)
-		go func() ([]byte, error) {
-			defer wg.Done()
-			result, err := contract.SubmitTransaction(function, variableName, change, sign)
-			if err != nil {
-				return result, fmt.Errorf("failed to evaluate transaction: %v", err)
-			}
-			return result, nil
-		}()
+// describeFailures returns the number of failed transactions of each class, in the order of the
+// classes
+func describeFailures(failures map[client.Class]int) string {
+	classes := make([]int, 0, len(failures))
+	total := 0
+	for class, count := range failures {
+		classes = append(classes, int(class))
+		total += count
 	}
+	sort.Ints(classes)
 
-	wg.Wait()
-
-	result, err := contract.EvaluateTransaction("get", variableName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to evaluate transaction: %v", err)
+	counts := make([]string, 0, len(classes))
+	for _, class := range classes {
+		counts = append(counts, fmt.Sprintf("%d %s", failures[client.Class(class)], client.Class(class)))
 	}
-	return result, err
+	return fmt.Sprintf("%d transactions: %s", total, strings.Join(counts, ", "))
 }
@@ -7,49 +7,95 @@ SPDX-License-Identifier: Apache-2.0
 package functions
 
 import (
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"os"
 	"path/filepath"
 
+	"github.com/hyperledger/fabric-samples/test-application/go/client"
+	"github.com/hyperledger/fabric-samples/test-application/go/identity"
+	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
 	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
 )
 
-func populateWallet(wallet *gateway.Wallet) error {
-	credPath := filepath.Join(
+// transactions are the names of the transactions of the contract submitted or evaluated by the
+// functions of the application
+var transactions = map[string]string{
+	"update":      "Update",
+	"get":         "Get",
+	"prune":       "Prune",
+	"delete":      "Delete",
+	"setscale":    "SetScale",
+	"setfloor":    "SetFloor",
+	"compactable": "Compactable",
+	"compact":     "Compact",
+	"putstandard": "PutStandard",
+	"getstandard": "GetStandard",
+	"delstandard": "DelStandard",
+}
+
+// transaction returns the name of the transaction of the contract called by function
+func transaction(function string) string {
+	if name, ok := transactions[function]; ok {
+		return name
+	}
+	return function
+}
+
+// getValue evaluates the Get transaction, and returns the value of the variable
+func getValue(contract client.Contract, variableName string) ([]byte, error) {
+	result, err := contract.EvaluateTransaction(transaction("get"), variableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate transaction: %v", err)
+	}
+
+	var variable struct {
+		Value string `json:"value"`
+	}
+	err = json.Unmarshal(result, &variable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the value of %s: %v", variableName, err)
+	}
+	return []byte(variable.Value), nil
+}
+
+// connect connects to the gateway with the identity of id, and returns the client calling the
+// bigdatacc contract with the deadline and the retries of calls
+func connect(id *identity.Options, calls *client.Options) (*gateway.Gateway, *client.Client, error) {
+	err := os.Setenv("DISCOVERY_AS_LOCALHOST", "true")
+	if err != nil {
+		return nil, nil, fmt.Errorf("error setting DISCOVERY_AS_LOCALHOST environemnt variable: %v", err)
+	}
+
+	withIdentity, err := id.WithIdentity()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load identity: %v", err)
+	}
+
+	ccpPath := filepath.Join(
 		"..",
 		"..",
 		"test-network",
 		"organizations",
 		"peerOrganizations",
 		"org1.example.com",
-		"users",
-		"User1@org1.example.com",
-		"msp",
+		"connection-org1.yaml",
 	)
 
-	certPath := filepath.Join(credPath, "signcerts", "cert.pem")
-	// read the certificate pem
-	cert, err := ioutil.ReadFile(filepath.Clean(certPath))
+	gw, err := gateway.Connect(
+		gateway.WithConfig(config.FromFile(filepath.Clean(ccpPath))),
+		withIdentity,
+		calls.WithTimeout(),
+	)
 	if err != nil {
-		return err
+		return nil, nil, fmt.Errorf("failed to connect to gateway: %v", err)
 	}
 
-	keyDir := filepath.Join(credPath, "keystore")
-	// there's a single file in this dir containing the private key
-	files, err := ioutil.ReadDir(keyDir)
-	if err != nil {
-		return err
-	}
-	if len(files) != 1 {
-		return fmt.Errorf("keystore folder should have contain one file")
-	}
-	keyPath := filepath.Join(keyDir, files[0].Name())
-	key, err := ioutil.ReadFile(filepath.Clean(keyPath))
+	network, err := gw.GetNetwork("mychannel")
 	if err != nil {
-		return err
+		gw.Close()
+		return nil, nil, fmt.Errorf("failed to get network: %v", err)
 	}
 
-	identity := gateway.NewX509Identity("Org1MSP", string(cert), string(key))
-
-	return wallet.Put("appUser", identity)
+	return gw, client.New(network.GetContract("bigdatacc"), *calls), nil
 }
@@ -0,0 +1,295 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// checkpointIndexName is the composite key index of the checkpoints of the variables
+const checkpointIndexName = "varName~checkpoint"
+
+// checkpoint is the aggregate of the compacted deltas of a variable, stored as the deltas replacing
+// them, as written by prune
+type checkpoint struct {
+	Deltas [][2]string `json:"deltas"`
+	// Rows is the number of delta rows compacted into the checkpoint
+	Rows int `json:"rows"`
+	// Rejected is the number of compacted deltas which were rejected, reported by prune
+	Rejected int `json:"rejected,omitempty"`
+}
+
+// counterMinAge is the minimum age in seconds of the compacted deltas of a counter, the default time
+// window in which the peers accept the time of a proposal. No transaction proposed before the
+// compacted deltas can be committed after them, so whether they are rejected cannot change.
+const counterMinAge = 15 * 60
+
+// DeltaRow identifies a delta row of a variable, with the time in nanoseconds of the transaction
+// which added it
+type DeltaRow struct {
+	Operation string `json:"op"`
+	Value     string `json:"value"`
+	TxID      string `json:"txId"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// CompactResult is the number of delta rows of a variable compacted into its checkpoint
+type CompactResult struct {
+	Name           string `json:"name"`
+	RowsCompacted  int    `json:"rowsCompacted"`
+	RowsSkipped    int    `json:"rowsSkipped"`
+	DeltasRejected int    `json:"deltasRejected"`
+}
+
+// deltaTime returns the time in nanoseconds of the transaction which added a delta row, stored as
+// its value. The rows added before the time was stored have the value 0x00, and are the oldest.
+func deltaTime(value []byte) int64 {
+	timestamp, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return timestamp
+}
+
+// txTime returns the time in nanoseconds of the transaction, as set by the client which proposed it
+func txTime(APIstub shim.ChaincodeStubInterface) (int64, error) {
+	timestamp, err := APIstub.GetTxTimestamp()
+	if err != nil {
+		return 0, fmt.Errorf("Could not retrieve the transaction timestamp: %s", err.Error())
+	}
+	return time.Unix(timestamp.GetSeconds(), int64(timestamp.GetNanos())).UnixNano(), nil
+}
+
+// getCheckpoint returns the checkpoint of a variable and its key, the checkpoint is nil if the
+// variable was not compacted
+func getCheckpoint(APIstub shim.ChaincodeStubInterface, name string) (*checkpoint, string, error) {
+	checkpointKey, err := APIstub.CreateCompositeKey(checkpointIndexName, []string{name})
+	if err != nil {
+		return nil, "", fmt.Errorf("Could not create a composite key for %s: %s", name, err.Error())
+	}
+
+	checkpointBytes, err := APIstub.GetState(checkpointKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("Could not retrieve the checkpoint of %s: %s", name, err.Error())
+	}
+	if checkpointBytes == nil {
+		return nil, checkpointKey, nil
+	}
+
+	cp := &checkpoint{}
+	err = json.Unmarshal(checkpointBytes, cp)
+	if err != nil {
+		return nil, "", fmt.Errorf("Invalid checkpoint of %s in the ledger: %s", name, err.Error())
+	}
+	return cp, checkpointKey, nil
+}
+
+// loadCheckpoint returns the aggregate of a variable starting from its checkpoint, and the
+// checkpoint, nil if the variable was not compacted
+func (s *SmartContract) loadCheckpoint(APIstub shim.ChaincodeStubInterface, name string) (aggregate, *checkpoint, string, error) {
+	finalVal, err := s.newAggregate(APIstub, name)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	cp, checkpointKey, err := getCheckpoint(APIstub, name)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if cp != nil {
+		for _, delta := range cp.Deltas {
+			_, err = finalVal.apply(delta[0], delta[1])
+			if err != nil {
+				return nil, nil, "", fmt.Errorf("Invalid checkpoint of %s in the ledger: %s", name, err.Error())
+			}
+		}
+		if sum, ok := finalVal.(*sumAggregate); ok {
+			sum.rejected = cp.Rejected
+		}
+	}
+	return finalVal, cp, checkpointKey, nil
+}
+
+// checkCompactable returns an error if the deltas of a counter younger than counterMinAge would be
+// compacted, as a transaction proposed before them could still be committed and change whether they
+// are rejected
+func checkCompactable(APIstub shim.ChaincodeStubInterface, name string, minAge int64) error {
+	typeName, err := typeOfVariable(APIstub, name)
+	if err != nil {
+		return err
+	}
+	if typeName == typeCounter && minAge < counterMinAge {
+		return fmt.Errorf("The deltas of the %s variable %s must be at least %d seconds old to be compacted", typeCounter, name, counterMinAge)
+	}
+	return nil
+}
+
+/**
+ * Lists the oldest delta rows of a variable which are old enough to be compacted, in the order they
+ * are aggregated. This only reads the ledger, and is meant to be evaluated rather than submitted: the
+ * range query would make a submitted transaction conflict with every concurrent update. The age of a
+ * delta is measured with the time of the transactions, set by the clients which proposed them. The
+ * deltas added before the chaincode recorded the time of the transactions are listed with the
+ * timestamp 0.
+ *
+ * @param ctx The transaction context
+ * @param name The name of the variable
+ * @param minAge The minimum age of the deltas in seconds, relative to the transaction timestamp
+ * @param limit The maximum number of deltas to list
+ *
+ * @return The deltas, or an error
+ */
+func (s *SmartContract) Compactable(ctx contractapi.TransactionContextInterface, name string, minAge int64, limit int) ([]DeltaRow, error) {
+	APIstub := ctx.GetStub()
+
+	if minAge < 0 {
+		return nil, fmt.Errorf("The minimum age cannot be negative")
+	}
+	if limit < 1 {
+		return nil, fmt.Errorf("The maximum number of deltas must be at least 1")
+	}
+	err := checkCompactable(APIstub, name, minAge)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := txTime(APIstub)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := readDeltas(APIstub, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Select the oldest deltas, in the order they are aggregated
+	deltas := []DeltaRow{}
+	for _, delta := range rows {
+		if len(deltas) == limit || now-delta.Timestamp < minAge*int64(time.Second) {
+			break
+		}
+		deltas = append(deltas, delta)
+	}
+
+	return deltas, nil
+}
+
+/**
+ * Compacts delta rows of a variable into its checkpoint, which get reads before the remaining deltas.
+ * Unlike prune, the deltas are read one by one rather than with a range query, so compacting does not
+ * conflict with concurrent updates, only with other compactions and prunes of the variable. The deltas
+ * which were already compacted, or which are younger than the minimum age, are skipped. The deltas of a
+ * counter must be the oldest deltas listed by Compactable, with a minimum age of at least
+ * counterMinAge, so that the checkpoint aggregates them in the same order as get, and the number of
+ * rejected deltas is kept in the checkpoint.
+ *
+ * @param ctx The transaction context
+ * @param name The name of the variable
+ * @param minAge The minimum age of the deltas in seconds, relative to the transaction timestamp
+ * @param deltas The deltas to compact, as returned by Compactable
+ *
+ * @return The number of rows compacted and skipped, or an error
+ */
+func (s *SmartContract) Compact(ctx contractapi.TransactionContextInterface, name string, minAge int64, deltas []DeltaRow) (*CompactResult, error) {
+	APIstub := ctx.GetStub()
+
+	if minAge < 0 {
+		return nil, fmt.Errorf("The minimum age cannot be negative")
+	}
+	err := checkCompactable(APIstub, name, minAge)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := txTime(APIstub)
+	if err != nil {
+		return nil, err
+	}
+
+	finalVal, cp, checkpointKey, err := s.loadCheckpoint(APIstub, name)
+	if err != nil {
+		return nil, err
+	}
+	if cp == nil {
+		cp = &checkpoint{}
+	}
+	rejected := cp.Rejected
+
+	// Read the time of the deltas from the ledger, skipping the deltas already compacted and the
+	// recent deltas
+	result := &CompactResult{Name: name}
+	keys := make(map[string]bool, len(deltas))
+	compacted := make([]DeltaRow, 0, len(deltas))
+	for _, delta := range deltas {
+		key, keyErr := deltaKey(APIstub, name, delta)
+		if keyErr != nil {
+			return nil, keyErr
+		}
+		if keys[key] {
+			continue
+		}
+		keys[key] = true
+
+		deltaBytes, getErr := APIstub.GetState(key)
+		if getErr != nil {
+			return nil, fmt.Errorf("Could not retrieve delta row: %s", getErr.Error())
+		}
+		delta.Timestamp = deltaTime(deltaBytes)
+		if deltaBytes == nil || now-delta.Timestamp < minAge*int64(time.Second) {
+			result.RowsSkipped++
+			continue
+		}
+		compacted = append(compacted, delta)
+	}
+
+	// Aggregate the deltas in order, as get does
+	sortDeltas(compacted)
+	for _, delta := range compacted {
+		_, applyErr := finalVal.apply(delta.Operation, delta.Value)
+		if applyErr != nil {
+			return nil, applyErr
+		}
+
+		key, keyErr := deltaKey(APIstub, name, delta)
+		if keyErr != nil {
+			return nil, keyErr
+		}
+		deltaRowDelErr := APIstub.DelState(key)
+		if deltaRowDelErr != nil {
+			return nil, fmt.Errorf("Could not delete delta row: %s", deltaRowDelErr.Error())
+		}
+		result.RowsCompacted++
+	}
+
+	sum, isSum := finalVal.(*sumAggregate)
+	if isSum {
+		result.DeltasRejected = sum.rejected - rejected
+	}
+
+	if result.RowsCompacted > 0 {
+		cp.Deltas = finalVal.deltas()
+		cp.Rows += result.RowsCompacted
+		if isSum {
+			cp.Rejected = sum.rejected
+		}
+		checkpointBytes, marshalErr := json.Marshal(cp)
+		if marshalErr != nil {
+			return nil, fmt.Errorf("Could not marshal the checkpoint of %s: %s", name, marshalErr.Error())
+		}
+		putErr := APIstub.PutState(checkpointKey, checkpointBytes)
+		if putErr != nil {
+			return nil, fmt.Errorf("Could not put the checkpoint of %s in the ledger: %s", name, putErr.Error())
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,179 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Bounds of the scale of the decimals, and of the exponent of a parsed decimal
+const (
+	defaultScale = 18
+	maxScale     = 100
+	maxExponent  = 1000
+)
+
+// decimalPattern matches the decimal numbers accepted as deltas, with an optional exponent
+var decimalPattern = regexp.MustCompile(`^[+-]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE]([+-]?[0-9]+))?$`)
+
+// legacyExponentPattern matches the decimal or binary exponent of a delta stored by the versions of
+// the chaincode which accepted any number parsed by strconv.ParseFloat
+var legacyExponentPattern = regexp.MustCompile(`[eEpP]([+-]?[0-9_]+)$`)
+
+// errNotFinite is returned by parseDelta for the NaN and infinite deltas, which can only have been
+// stored by the versions of the chaincode summing the deltas as floats
+var errNotFinite = errors.New("the delta is not a finite number")
+
+// decimal is an exact decimal number, stored as an integer number of units of 10^-scale
+type decimal struct {
+	units *big.Int
+	scale int
+}
+
+// newDecimal returns a zero decimal with the given scale
+func newDecimal(scale int) *decimal {
+	return &decimal{units: new(big.Int), scale: scale}
+}
+
+// parseDecimal parses a decimal number, which cannot have more decimal places than the scale
+func parseDecimal(s string, scale int) (*decimal, error) {
+	match := decimalPattern.FindStringSubmatch(s)
+	if match == nil {
+		return nil, fmt.Errorf("%s is not a decimal number", s)
+	}
+	if match[3] != "" {
+		exponent, err := strconv.Atoi(match[3])
+		if err != nil || exponent > maxExponent || exponent < -maxExponent {
+			return nil, fmt.Errorf("the exponent of %s is out of range", s)
+		}
+	}
+
+	value, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a decimal number", s)
+	}
+	value.Mul(value, new(big.Rat).SetInt(pow10(scale)))
+	if !value.IsInt() {
+		return nil, fmt.Errorf("%s has more than %d decimal places", s, scale)
+	}
+
+	return &decimal{units: new(big.Int).Set(value.Num()), scale: scale}, nil
+}
+
+// parseDelta parses the value of a delta stored in the ledger. The deltas added since the deltas are
+// exact decimals are parsed by parseDecimal. The deltas stored before, which are any number parsed by
+// strconv.ParseFloat, keep their exact value, or the value of the float for a hexadecimal float or an
+// out of range exponent, rounded half away from zero to the scale. The NaN and infinite deltas cannot
+// be summed, and errNotFinite is returned so that they are skipped.
+func parseDelta(s string, scale int) (*decimal, error) {
+	d, err := parseDecimal(s, scale)
+	if err == nil {
+		return d, nil
+	}
+
+	f, floatErr := strconv.ParseFloat(s, 64)
+	if floatErr != nil {
+		return nil, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, errNotFinite
+	}
+
+	var value *big.Rat
+	if legacyExponentInRange(s) {
+		value, _ = new(big.Rat).SetString(s)
+	}
+	if value == nil {
+		value = new(big.Rat).SetFloat64(f)
+	}
+
+	return roundDecimal(value, scale), nil
+}
+
+// legacyExponentInRange returns false if the exponent of a legacy delta is out of range, as parsing
+// its exact value would take as long as computing its power of 10 or 2
+func legacyExponentInRange(s string) bool {
+	match := legacyExponentPattern.FindStringSubmatch(s)
+	if match == nil {
+		return true
+	}
+	exponent, err := strconv.Atoi(strings.Replace(match[1], "_", "", -1))
+	return err == nil && exponent <= maxExponent && exponent >= -maxExponent
+}
+
+// roundDecimal rounds value half away from zero to the scale
+func roundDecimal(value *big.Rat, scale int) *decimal {
+	scaled := new(big.Rat).Mul(value, new(big.Rat).SetInt(pow10(scale)))
+	units, remainder := new(big.Int).QuoRem(new(big.Int).Abs(scaled.Num()), scaled.Denom(), new(big.Int))
+	if remainder.Lsh(remainder, 1).Cmp(scaled.Denom()) >= 0 {
+		units.Add(units, big.NewInt(1))
+	}
+	if scaled.Sign() < 0 {
+		units.Neg(units)
+	}
+
+	return &decimal{units: units, scale: scale}
+}
+
+// add adds x to d
+func (d *decimal) add(x *decimal) {
+	d.units.Add(d.units, x.units)
+}
+
+// sub subtracts x from d
+func (d *decimal) sub(x *decimal) {
+	d.units.Sub(d.units, x.units)
+}
+
+// apply adds or subtracts the delta value to d, according to operation
+func (d *decimal) apply(operation string, value string) error {
+	delta, err := parseDelta(value, d.scale)
+	if err != nil {
+		return err
+	}
+	return d.applyDelta(operation, delta)
+}
+
+// applyDelta adds or subtracts delta to d, according to operation
+func (d *decimal) applyDelta(operation string, delta *decimal) error {
+	switch operation {
+	case "+":
+		d.add(delta)
+	case "-":
+		d.sub(delta)
+	default:
+		return fmt.Errorf("Unrecognized operation %s", operation)
+	}
+	return nil
+}
+
+// String returns the canonical form of d, without exponent, leading zeros or trailing
+// fractional zeros
+func (d *decimal) String() string {
+	digits := new(big.Int).Abs(d.units).String()
+	if len(digits) <= d.scale {
+		digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+	}
+
+	integer, fraction := digits[:len(digits)-d.scale], strings.TrimRight(digits[len(digits)-d.scale:], "0")
+	s := integer
+	if fraction != "" {
+		s += "." + fraction
+	}
+	if d.units.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
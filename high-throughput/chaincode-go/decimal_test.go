@@ -0,0 +1,130 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	for _, test := range []struct {
+		value    string
+		scale    int
+		expected string
+	}{
+		{"0.1", 18, "0.1"},
+		{"100", 18, "100"},
+		{"+100.500", 2, "100.5"},
+		{"-0.000", 2, "0"},
+		{"5.", 0, "5"},
+		{".25", 2, "0.25"},
+		{"1e3", 0, "1000"},
+		{"1.5E-2", 3, "0.015"},
+		{"0.30000000000000004", 18, "0.30000000000000004"},
+		{"-12345678901234567890.123456789", 9, "-12345678901234567890.123456789"},
+	} {
+		d, err := parseDecimal(test.value, test.scale)
+		if err != nil {
+			t.Errorf("unexpected error parsing %s: %v", test.value, err)
+			continue
+		}
+		if d.String() != test.expected {
+			t.Errorf("expected %s parsed with scale %d to be %s, got %s", test.value, test.scale, test.expected, d)
+		}
+	}
+
+	for _, test := range []struct {
+		value    string
+		scale    int
+		expected string
+	}{
+		{"0.001", 2, "0.001 has more than 2 decimal places"},
+		{"NaN", 2, "NaN is not a decimal number"},
+		{"Inf", 2, "Inf is not a decimal number"},
+		{"1/3", 2, "1/3 is not a decimal number"},
+		{"0x10", 2, "0x10 is not a decimal number"},
+		{"", 2, " is not a decimal number"},
+		{"1e1000000", 2, "the exponent of 1e1000000 is out of range"},
+	} {
+		_, err := parseDecimal(test.value, test.scale)
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("expected error %q parsing %s, got %v", test.expected, test.value, err)
+		}
+	}
+}
+
+func TestParseDelta(t *testing.T) {
+	for _, test := range []struct {
+		value    string
+		scale    int
+		expected string
+	}{
+		{"0.25", 2, "0.25"},
+		{"0.125", 2, "0.13"},
+		{"-0.125", 2, "-0.13"},
+		{"0.1249", 2, "0.12"},
+		{"0.1234567890123456789", 18, "0.123456789012345679"},
+		{"0x1p-2", 2, "0.25"},
+		{"0x1.8p3", 0, "12"},
+		{"1E-400", 2, "0"},
+		{"1e-1000000", 2, "0"},
+		{"1_000.5", 0, "1001"},
+		{"1/3", 2, ""},
+		{"abc", 2, ""},
+	} {
+		d, err := parseDelta(test.value, test.scale)
+		if test.expected == "" {
+			if err == nil || err == errNotFinite {
+				t.Errorf("expected an error parsing %s, got %v", test.value, err)
+			}
+			continue
+		}
+		if err != nil || d.String() != test.expected {
+			t.Errorf("expected %s parsed with scale %d to be %s, got %v: %v", test.value, test.scale, test.expected, d, err)
+		}
+	}
+
+	for _, value := range []string{"NaN", "nan", "Inf", "-Inf", "+infinity"} {
+		_, err := parseDelta(value, 2)
+		if err != errNotFinite {
+			t.Errorf("expected %s not to be finite, got %v", value, err)
+		}
+	}
+}
+
+func TestDecimalNoDrift(t *testing.T) {
+	sum := newDecimal(defaultScale)
+	var floatSum float64
+	for i := 0; i < 100000; i++ {
+		err := sum.apply("+", "0.1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		floatSum += 0.1
+	}
+	if sum.String() != "10000" {
+		t.Errorf("expected 100000 deltas of 0.1 to sum to 10000, got %s", sum)
+	}
+	// the float64 sum drifts, which the decimal sum avoids
+	if strconv.FormatFloat(floatSum, 'f', -1, 64) == "10000" {
+		t.Errorf("expected the float64 sum to drift")
+	}
+
+	for i := 0; i < 100000; i++ {
+		err := sum.apply("-", "0.01")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if sum.String() != "9000" {
+		t.Errorf("expected 9000 after subtracting 100000 deltas of 0.01, got %s", sum)
+	}
+
+	err := sum.apply("*", "2")
+	if err == nil || err.Error() != "Unrecognized operation *" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key indexes of the floors of the variables, and of the shards of their balance above the floor
+const (
+	floorIndexName = "varName~floor"
+	shardIndexName = "varName~shard"
+)
+
+// maxShards is the maximum number of shards of the balance of a variable
+const maxShards = 100
+
+// Floor is the lowest value of a variable, whose balance above the floor is split in shards. The
+// deltas of a variable with a floor reserve their value from the shards: a "+" delta credits one
+// shard, and a "-" delta debits as many shards as needed, and is rejected if they cannot cover it.
+// Only the updates which credit or debit the same shard conflict.
+type Floor struct {
+	Value  string `json:"value"`
+	Shards int    `json:"shards"`
+}
+
+// getFloor returns the floor of a variable, nil if the variable does not have a floor
+func getFloor(APIstub shim.ChaincodeStubInterface, name string) (*Floor, error) {
+	floorKey, err := APIstub.CreateCompositeKey(floorIndexName, []string{name})
+	if err != nil {
+		return nil, fmt.Errorf("Could not create a composite key for %s: %s", name, err.Error())
+	}
+
+	floorBytes, err := APIstub.GetState(floorKey)
+	if err != nil {
+		return nil, fmt.Errorf("Could not retrieve the floor of %s: %s", name, err.Error())
+	}
+	if floorBytes == nil {
+		return nil, nil
+	}
+
+	f := &Floor{}
+	err = json.Unmarshal(floorBytes, f)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid floor of %s in the ledger: %s", name, err.Error())
+	}
+	return f, nil
+}
+
+// shardKey returns the key of a shard of the balance of a variable
+func shardKey(APIstub shim.ChaincodeStubInterface, name string, shard int) (string, error) {
+	key, err := APIstub.CreateCompositeKey(shardIndexName, []string{name, strconv.Itoa(shard)})
+	if err != nil {
+		return "", fmt.Errorf("Could not create a composite key for %s: %s", name, err.Error())
+	}
+	return key, nil
+}
+
+// getShard returns the balance of a shard of a variable
+func getShard(APIstub shim.ChaincodeStubInterface, name string, shard int, scale int) (*decimal, error) {
+	key, err := shardKey(APIstub, name, shard)
+	if err != nil {
+		return nil, err
+	}
+
+	balanceBytes, err := APIstub.GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("Could not retrieve shard %d of %s: %s", shard, name, err.Error())
+	}
+	if balanceBytes == nil {
+		return newDecimal(scale), nil
+	}
+
+	balance, err := parseDecimal(string(balanceBytes), scale)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid shard %d of %s in the ledger: %s", shard, name, err.Error())
+	}
+	return balance, nil
+}
+
+// putShard sets the balance of a shard of a variable
+func putShard(APIstub shim.ChaincodeStubInterface, name string, shard int, balance *decimal) error {
+	key, err := shardKey(APIstub, name, shard)
+	if err != nil {
+		return err
+	}
+
+	err = APIstub.PutState(key, []byte(balance.String()))
+	if err != nil {
+		return fmt.Errorf("Could not put shard %d of %s in the ledger: %s", shard, name, err.Error())
+	}
+	return nil
+}
+
+// firstShard returns the shard a transaction credits, or debits first. Spreading the transactions
+// over the shards by their ID is deterministic, so that every endorser chooses the same shard.
+func (f *Floor) firstShard(txid string) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(txid))
+	return int(hash.Sum32() % uint32(f.Shards))
+}
+
+// reserve credits or debits the shards of a variable with a delta. A "-" delta debits the first shard
+// of the transaction, then the next shards until the delta is covered, and fails if the balance of the
+// variable above its floor is too low.
+func (f *Floor) reserve(APIstub shim.ChaincodeStubInterface, name string, op string, delta *decimal) error {
+	shard := f.firstShard(APIstub.GetTxID())
+
+	if op == "+" {
+		balance, err := getShard(APIstub, name, shard, delta.scale)
+		if err != nil {
+			return err
+		}
+		balance.add(delta)
+		return putShard(APIstub, name, shard, balance)
+	}
+
+	// Take what each shard can cover, and update the shards once the delta is covered
+	remaining := newDecimal(delta.scale)
+	remaining.add(delta)
+	balances := make(map[int]*decimal)
+	for i := 0; i < f.Shards && remaining.units.Sign() > 0; i++ {
+		balance, err := getShard(APIstub, name, (shard+i)%f.Shards, delta.scale)
+		if err != nil {
+			return err
+		}
+		if balance.units.Sign() <= 0 {
+			continue
+		}
+
+		taken := newDecimal(delta.scale)
+		if balance.units.Cmp(remaining.units) > 0 {
+			taken.add(remaining)
+		} else {
+			taken.add(balance)
+		}
+		remaining.sub(taken)
+		balance.sub(taken)
+		balances[(shard+i)%f.Shards] = balance
+	}
+
+	if remaining.units.Sign() > 0 {
+		return fmt.Errorf("Insufficient balance: %s cannot go below its floor %s", name, f.Value)
+	}
+	for i := 0; i < f.Shards; i++ {
+		if balance, ok := balances[i]; ok {
+			err := putShard(APIstub, name, i, balance)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/**
+ * Sets the floor of a variable, the lowest value its "-" deltas can take it to. The balance of the
+ * variable above the floor is split in shards, and the updates of the variable reserve their delta
+ * from a shard, so that a "-" delta which would take the variable below its floor is rejected when it
+ * is submitted. The floor can only be set once, on a sum or a counter whose value is not below the
+ * floor.
+ *
+ * @param ctx The transaction context
+ * @param name The name of the variable
+ * @param value The floor of the variable
+ * @param shards The number of shards, between 1 and 100, bounding the number of conflicting updates
+ *
+ * @return The floor of the variable, or an error
+ */
+func (s *SmartContract) SetFloor(ctx contractapi.TransactionContextInterface, name string, value string, shards int) (*Floor, error) {
+	APIstub := ctx.GetStub()
+
+	if shards < 1 || shards > maxShards {
+		return nil, fmt.Errorf("The number of shards must be between 1 and %d", maxShards)
+	}
+
+	typeName, err := getType(APIstub, name)
+	if err != nil {
+		return nil, err
+	}
+	if typeName != "" && typeName != typeCounter {
+		return nil, fmt.Errorf("A floor can only be set on a %s or a %s, %s is declared as %s", typeSum, typeCounter, name, typeName)
+	}
+
+	existing, err := getFloor(APIstub, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("The floor of %s is already set to %s", name, existing.Value)
+	}
+
+	scale, err := s.getScale(APIstub)
+	if err != nil {
+		return nil, err
+	}
+	floorValue, err := parseDecimal(value, scale)
+	if err != nil {
+		return nil, fmt.Errorf("Provided floor was not a number: %s", err.Error())
+	}
+
+	// The current balance above the floor goes to the first shard
+	finalVal, _, _, err := s.value(APIstub, name)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := parseDecimal(finalVal.String(), scale)
+	if err != nil {
+		return nil, err
+	}
+	balance.sub(floorValue)
+	if balance.units.Sign() < 0 {
+		return nil, fmt.Errorf("The value %s of %s is below the floor %s", finalVal, name, floorValue)
+	}
+	err = putShard(APIstub, name, 0, balance)
+	if err != nil {
+		return nil, err
+	}
+
+	floorKey, err := APIstub.CreateCompositeKey(floorIndexName, []string{name})
+	if err != nil {
+		return nil, fmt.Errorf("Could not create a composite key for %s: %s", name, err.Error())
+	}
+	result := &Floor{Value: floorValue.String(), Shards: shards}
+	floorBytes, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("Could not marshal the floor of %s: %s", name, err.Error())
+	}
+	err = APIstub.PutState(floorKey, floorBytes)
+	if err != nil {
+		return nil, fmt.Errorf("Could not put the floor of %s in the ledger: %s", name, err.Error())
+	}
+
+	return result, nil
+}
+
+// deleteFloor deletes the floor of a variable and the shards of its balance
+func deleteFloor(APIstub shim.ChaincodeStubInterface, name string) error {
+	floorKey, err := APIstub.CreateCompositeKey(floorIndexName, []string{name})
+	if err != nil {
+		return fmt.Errorf("Could not create a composite key for %s: %s", name, err.Error())
+	}
+	err = APIstub.DelState(floorKey)
+	if err != nil {
+		return fmt.Errorf("Could not delete the floor of %s: %s", name, err.Error())
+	}
+
+	shardResultsIterator, err := APIstub.GetStateByPartialCompositeKey(shardIndexName, []string{name})
+	if err != nil {
+		return fmt.Errorf("Could not retrieve the shards of %s: %s", name, err.Error())
+	}
+	defer shardResultsIterator.Close()
+	for shardResultsIterator.HasNext() {
+		responseRange, err := shardResultsIterator.Next()
+		if err != nil {
+			return fmt.Errorf("Could not retrieve next shard: %s", err.Error())
+		}
+		err = APIstub.DelState(responseRange.Key)
+		if err != nil {
+			return fmt.Errorf("Could not delete shard: %s", err.Error())
+		}
+	}
+	return nil
+}
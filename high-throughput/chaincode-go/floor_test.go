@@ -0,0 +1,191 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestFloor(t *testing.T) {
+	stub := newStub(t)
+
+	expectUpdate(t, stub, "stock", "10", "+", "")
+	expectResponse(t, invoke(stub, "SetFloor", "stock", "20", "4"), shim.ERROR, "The value 10 of stock is below the floor 20")
+	expectResponse(t, invoke(stub, "SetFloor", "stock", "5", "0"), shim.ERROR, "The number of shards must be between 1 and 100")
+	expectResponse(t, invoke(stub, "SetFloor", "stock", "5", "4"), shim.OK, `{"value":"5","shards":4}`)
+	expectResponse(t, invoke(stub, "SetFloor", "stock", "0", "4"), shim.ERROR, "The floor of stock is already set to 5")
+
+	// the debits are covered by several shards, and rejected below the floor
+	expectUpdate(t, stub, "stock", "4", "+", "")
+	expectUpdate(t, stub, "stock", "8", "-", "")
+	expectResponse(t, invoke(stub, "Update", "stock", "2", "-", ""), shim.ERROR, "Insufficient balance: stock cannot go below its floor 5")
+	expectUpdate(t, stub, "stock", "1", "-", "")
+	expectValue(t, stub, "stock", "5")
+	expectResponse(t, invoke(stub, "Update", "stock", "-1", "+", ""), shim.ERROR, "The deltas of a variable with a floor cannot be negative")
+	expectResponse(t, invoke(stub, "Update", "stock", "1", "max", ""), shim.ERROR, "Operator max is not supported by the variable stock with a floor")
+
+	// the floor is kept when the variable is pruned, and deleted with the variable
+	expectResponse(t, invoke(stub, "Prune", "stock"), shim.OK, `{"name":"stock","value":"5","rowsPruned":4,"deltasRejected":0}`)
+	expectResponse(t, invoke(stub, "Update", "stock", "1", "-", ""), shim.ERROR, "Insufficient balance: stock cannot go below its floor 5")
+	expectResponse(t, invoke(stub, "Delete", "stock"), shim.OK, `{"name":"stock","rowsDeleted":1}`)
+	expectUpdate(t, stub, "stock", "1", "-", "")
+
+	expectUpdate(t, stub, "low", "1", "min", "")
+	expectResponse(t, invoke(stub, "SetFloor", "low", "0", "4"), shim.ERROR, "A floor can only be set on a sum or a counter, low is declared as min")
+	expectResponse(t, invoke(stub, "SetScale", "2"), shim.ERROR, "The scale cannot be changed once deltas are added")
+}
+
+// simulatedTx is a transaction simulated against the state of the ledger at the start of a block. It
+// records the keys it reads and buffers its writes, which are committed if no transaction committed
+// earlier in the block wrote the keys it read.
+type simulatedTx struct {
+	*shimtest.MockStub
+	reads  map[string]bool
+	writes map[string][]byte
+}
+
+func (tx *simulatedTx) GetState(key string) ([]byte, error) {
+	tx.reads[key] = true
+	if value, ok := tx.writes[key]; ok {
+		return value, nil
+	}
+	return tx.MockStub.GetState(key)
+}
+
+func (tx *simulatedTx) PutState(key string, value []byte) error {
+	tx.writes[key] = value
+	return nil
+}
+
+func (tx *simulatedTx) DelState(key string) error {
+	tx.writes[key] = nil
+	return nil
+}
+
+// GetStateByPartialCompositeKey is not simulated: the updates of a variable with a floor only read
+// single keys, so that they only conflict with the updates writing the same keys
+func (tx *simulatedTx) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	return nil, errors.New("range queries are not simulated")
+}
+
+// simulateBlock simulates the updates in args concurrently, as if they were endorsed at the same
+// ledger height and ordered in the same block, and returns their errors and whether they were
+// invalidated by a read conflict
+func simulateBlock(stub *shimtest.MockStub, txids []string, args [][]string) ([]error, []bool) {
+	s := new(SmartContract)
+	txs := make([]*simulatedTx, len(args))
+	errs := make([]error, len(args))
+	for i := range args {
+		txs[i] = &simulatedTx{MockStub: stub, reads: make(map[string]bool), writes: make(map[string][]byte)}
+		ctx := new(contractapi.TransactionContext)
+		ctx.SetStub(txs[i])
+		stub.MockTransactionStart(txids[i])
+		_, errs[i] = s.Update(ctx, args[i][0], args[i][1], args[i][2], "")
+		stub.MockTransactionEnd(txids[i])
+	}
+
+	conflicts := make([]bool, len(args))
+	written := make(map[string]bool)
+	stub.MockTransactionStart("commit")
+	defer stub.MockTransactionEnd("commit")
+	for i, tx := range txs {
+		if errs[i] != nil {
+			continue
+		}
+		for key := range tx.reads {
+			if written[key] {
+				conflicts[i] = true
+			}
+		}
+		if conflicts[i] {
+			continue
+		}
+		for key, value := range tx.writes {
+			if value == nil {
+				stub.DelState(key)
+			} else {
+				stub.PutState(key, value)
+			}
+			written[key] = true
+		}
+	}
+	return errs, conflicts
+}
+
+func TestFloorConcurrentDebits(t *testing.T) {
+	stub := newStub(t)
+	expectResponse(t, invoke(stub, "SetFloor", "stock", "0", "4"), shim.OK, `{"value":"0","shards":4}`)
+	for i := 0; i < 20; i++ {
+		expectUpdate(t, stub, "stock", "5", "+", "")
+	}
+
+	// 40 clients concurrently debit 5, twice the balance, and resubmit their conflicting debits
+	pending := make([]int, 40)
+	for i := range pending {
+		pending[i] = i
+	}
+	var debited, rejected, conflicted int
+	for block := 0; len(pending) > 0; block++ {
+		if block == 100 {
+			t.Fatalf("%d debits still pending after %d blocks", len(pending), block)
+		}
+
+		txids := make([]string, len(pending))
+		args := make([][]string, len(pending))
+		for i, client := range pending {
+			txids[i] = fmt.Sprintf("debit%02d-%d", client, block)
+			args[i] = []string{"stock", "5", "-"}
+		}
+		errs, conflicts := simulateBlock(stub, txids, args)
+
+		var blockDebited int
+		var retry []int
+		for i, client := range pending {
+			switch {
+			case errs[i] != nil:
+				if !strings.HasPrefix(errs[i].Error(), "Insufficient balance") {
+					t.Fatalf("unexpected error %v", errs[i])
+				}
+				rejected++
+			case conflicts[i]:
+				conflicted++
+				retry = append(retry, client)
+			default:
+				blockDebited++
+			}
+		}
+		if block == 0 && blockDebited < 2 {
+			t.Errorf("expected the shards to commit concurrent debits, %d committed in the first block", blockDebited)
+		}
+		debited += blockDebited
+		pending = retry
+
+		var variable Variable
+		response := invoke(stub, "Get", "stock")
+		err := json.Unmarshal(response.Payload, &variable)
+		if err != nil || strings.HasPrefix(variable.Value, "-") {
+			t.Fatalf("stock went below its floor: %v", response)
+		}
+	}
+
+	if debited != 20 || rejected != 20 || conflicted == 0 {
+		t.Errorf("expected 20 debits and 20 rejected debits after some conflicts, got %d, %d and %d conflicts", debited, rejected, conflicted)
+	}
+	expectValue(t, stub, "stock", "0")
+	for shard := 0; shard < 4; shard++ {
+		balance, err := getShard(stub, "stock", shard, defaultScale)
+		if err != nil || balance.units.Sign() != 0 {
+			t.Errorf("expected shard %d to be empty, got %v: %v", shard, balance, err)
+		}
+	}
+}
@@ -13,7 +13,8 @@
  * is then an aggregate of the initial value combined with all of the deltas. Additionally, a pruning
  * function is provided which aggregates and deletes the deltas to update the initial value. This should
  * be done during a maintenance window or when there is a lowered transaction volume, to avoid the proliferation
- * of millions of rows of data.
+ * of millions of rows of data. Compacting functions move the older deltas into a checkpoint row instead,
+ * without conflicting with concurrent updates, so that the cost of retrieving the value stays bounded.
  *
  * @author	Alexandre Pauwels for IBM
  * @created	17 Aug 2017
@@ -22,295 +23,455 @@
 package main
 
 /* Imports
- * 4 utility libraries for formatting, handling bytes, reading and writing JSON, and string manipulation
+ * 2 utility libraries for formatting and converting strings
  * 2 specific Hyperledger Fabric specific libraries for Smart Contracts
  */
 import (
 	"fmt"
+	"sort"
 	"strconv"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
-	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
-//SmartContract is the data structure which represents this contract and on which  various contract lifecycle functions are attached
+// SmartContract is the data structure which represents this contract and on which  various contract lifecycle functions are attached
 type SmartContract struct {
+	contractapi.Contract
 }
 
-// Define Status codes for the response
-const (
-	OK    = 200
-	ERROR = 500
-)
+// Delta is a delta added to a variable
+type Delta struct {
+	Name      string `json:"name"`
+	Operation string `json:"operation"`
+	Value     string `json:"value"`
+}
+
+// Variable is the aggregate value of a variable. The value of a set is a sorted JSON array.
+type Variable struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	// DeltaCount is the number of delta rows aggregated after the checkpoint of the variable
+	DeltaCount int `json:"deltaCount"`
+}
+
+// PruneResult is the value of a pruned variable
+type PruneResult struct {
+	Name           string `json:"name"`
+	Value          string `json:"value"`
+	RowsPruned     int    `json:"rowsPruned"`
+	DeltasRejected int    `json:"deltasRejected"`
+}
 
-// Init is called when the smart contract is instantiated
-func (s *SmartContract) Init(APIstub shim.ChaincodeStubInterface) pb.Response {
-	return shim.Success(nil)
+// DeleteResult is the number of delta rows of a deleted variable
+type DeleteResult struct {
+	Name        string `json:"name"`
+	RowsDeleted int    `json:"rowsDeleted"`
 }
 
-// Invoke routes invocations to the appropriate function in chaincode
-// Current supported invocations are:
-//	- update, adds a delta to an aggregate variable in the ledger, all variables are assumed to start at 0
-//	- get, retrieves the aggregate value of a variable in the ledger
-//	- prune, deletes all rows associated with the variable and replaces them with a single row containing the aggregate value
-//	- delete, removes all rows associated with the variable
-func (s *SmartContract) Invoke(APIstub shim.ChaincodeStubInterface) pb.Response {
-	// Retrieve the requested Smart Contract function and arguments
-	function, args := APIstub.GetFunctionAndParameters()
-
-	// Route to the appropriate handler function to interact with the ledger appropriately
-	if function == "update" {
-		return s.update(APIstub, args)
-	} else if function == "get" {
-		return s.get(APIstub, args)
-	} else if function == "prune" {
-		return s.prune(APIstub, args)
-	} else if function == "delete" {
-		return s.delete(APIstub, args)
-	} else if function == "putstandard" {
-		return s.putStandard(APIstub, args)
-	} else if function == "getstandard" {
-		return s.getStandard(APIstub, args)
-	} else if function == "delstandard" {
-		return s.delStandard(APIstub, args)
-	}
-
-	return shim.Error("Invalid Smart Contract function name.")
+// scaleKey is the key of the scale of the variables, the number of decimal places of their deltas
+const scaleKey = "decimalScale"
+
+// Init is called when the chaincode is deployed with an Init transaction, there is nothing to initialize
+func (s *SmartContract) Init(ctx contractapi.TransactionContextInterface) error {
+	return nil
+}
+
+// getScale returns the scale of the variables, or the default scale if SetScale did not set it
+func (s *SmartContract) getScale(APIstub shim.ChaincodeStubInterface) (int, error) {
+	scaleBytes, err := APIstub.GetState(scaleKey)
+	if err != nil {
+		return 0, fmt.Errorf("Could not retrieve the scale: %s", err.Error())
+	}
+	if scaleBytes == nil {
+		return defaultScale, nil
+	}
+
+	scale, err := strconv.Atoi(string(scaleBytes))
+	if err != nil {
+		return 0, fmt.Errorf("Invalid scale %s in the ledger", scaleBytes)
+	}
+	return scale, nil
 }
 
 /**
  * Updates the ledger to include a new delta for a particular variable. If this is the first time
- * this variable is being added to the ledger, then its initial value is assumed to be 0. The arguments
- * to give in the args array are as follows:
- *	- args[0] -> name of the variable
- *	- args[1] -> new delta (float)
- *	- args[2] -> operation (currently supported are addition "+" and subtraction "-")
+ * this variable is being added to the ledger, then its initial value is assumed to be 0, and its type
+ * is declared by the type argument or by the operation.
  *
- * @param APIstub The chaincode shim
- * @param args The arguments array for the update invocation
+ * Sums are not declared, so that concurrent first updates do not conflict. The other types are declared
+ * by the first update of the variable.
  *
- * @return A response structure indicating success or failure with a message
+ * @param ctx The transaction context
+ * @param name The name of the variable
+ * @param value The new delta (decimal number, with at most as many decimal places as the scale, or string of a set)
+ * @param op The operation ("+" and "-" for sums and counters, "min", "max", and "add" for sets)
+ * @param variableType The type of the variable ("sum", "counter", "min", "max" or "set"), or the empty string
+ *
+ * @return The delta added to the variable, or an error
  */
-func (s *SmartContract) update(APIstub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Check we have a valid number of args
-	if len(args) != 3 {
-		return shim.Error("Incorrect number of arguments, expecting 3")
-	}
+func (s *SmartContract) Update(ctx contractapi.TransactionContextInterface, name string, value string, op string, variableType string) (*Delta, error) {
+	APIstub := ctx.GetStub()
 
-	// Extract the args
-	name := args[0]
-	op := args[2]
-	_, err := strconv.ParseFloat(args[1], 64)
+	// Find the type of the variable, declared by its first update
+	declaredType, err := getType(APIstub, name)
 	if err != nil {
-		return shim.Error("Provided value was not a number")
+		return nil, err
+	}
+	typeName := declaredType
+	if variableType != "" {
+		if declaredType != "" && variableType != declaredType {
+			return nil, fmt.Errorf("The variable %s is declared as %s", name, declaredType)
+		}
+		typeName = variableType
+	}
+	if typeName == "" {
+		typeName, err = typeOfOperation(op)
+		if err != nil {
+			return nil, err
+		}
+	}
+	varType, ok := variableTypes[typeName]
+	if !ok {
+		return nil, fmt.Errorf("Unknown variable type %s, expecting sum, counter, min, max or set", typeName)
 	}
 
 	// Make sure a valid operator is provided
-	if op != "+" && op != "-" {
-		return shim.Error(fmt.Sprintf("Operator %s is unrecognized", op))
+	err = varType.allows(name, typeName, op)
+	if err != nil {
+		return nil, err
+	}
+
+	// Numeric deltas are stored in their canonical form
+	if varType.numeric {
+		scale, err := s.getScale(APIstub)
+		if err != nil {
+			return nil, err
+		}
+		delta, err := parseDecimal(value, scale)
+		if err != nil {
+			return nil, fmt.Errorf("Provided value was not a number: %s", err.Error())
+		}
+		if typeName == typeCounter && delta.units.Sign() < 0 {
+			return nil, fmt.Errorf("The deltas of a counter cannot be negative")
+		}
+		value = delta.String()
+
+		// The deltas of a variable with a floor are reserved from the shards of its balance
+		varFloor, err := getFloor(APIstub, name)
+		if err != nil {
+			return nil, err
+		}
+		if varFloor != nil {
+			if op != "+" && op != "-" {
+				return nil, fmt.Errorf("Operator %s is not supported by the variable %s with a floor", op, name)
+			}
+			if delta.units.Sign() < 0 {
+				return nil, fmt.Errorf("The deltas of a variable with a floor cannot be negative")
+			}
+			err = varFloor.reserve(APIstub, name, op, delta)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else if value == "" {
+		return nil, fmt.Errorf("The elements of a set cannot be empty")
+	}
+
+	// Declare the type of a new variable, the variables updated before the types were introduced are sums
+	if declaredType == "" && typeName != typeSum {
+		deltaResultsIterator, deltaErr := APIstub.GetStateByPartialCompositeKey("varName~op~value~txID", []string{name})
+		if deltaErr != nil {
+			return nil, fmt.Errorf("Could not retrieve delta rows for %s: %s", name, deltaErr.Error())
+		}
+		exists := deltaResultsIterator.HasNext()
+		deltaResultsIterator.Close()
+		cp, _, cpErr := getCheckpoint(APIstub, name)
+		if cpErr != nil {
+			return nil, cpErr
+		}
+		if exists || cp != nil {
+			return nil, fmt.Errorf("The variable %s is declared as %s", name, typeSum)
+		}
+
+		err = putType(APIstub, name, typeName)
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	err = putDelta(APIstub, name, op, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Delta{Name: name, Operation: op, Value: value}, nil
+}
+
+// putDelta adds a delta row to a variable, whose value is the time of the transaction in nanoseconds
+func putDelta(APIstub shim.ChaincodeStubInterface, name string, op string, value string) error {
 	// Retrieve info needed for the update procedure
 	txid := APIstub.GetTxID()
 	compositeIndexName := "varName~op~value~txID"
+	timestamp, err := txTime(APIstub)
+	if err != nil {
+		return err
+	}
 
 	// Create the composite key that will allow us to query for all deltas on a particular variable
-	compositeKey, compositeErr := APIstub.CreateCompositeKey(compositeIndexName, []string{name, op, args[1], txid})
+	compositeKey, compositeErr := APIstub.CreateCompositeKey(compositeIndexName, []string{name, op, value, txid})
 	if compositeErr != nil {
-		return shim.Error(fmt.Sprintf("Could not create a composite key for %s: %s", name, compositeErr.Error()))
+		return fmt.Errorf("Could not create a composite key for %s: %s", name, compositeErr.Error())
 	}
 
 	// Save the composite key index
-	compositePutErr := APIstub.PutState(compositeKey, []byte{0x00})
+	compositePutErr := APIstub.PutState(compositeKey, []byte(strconv.FormatInt(timestamp, 10)))
 	if compositePutErr != nil {
-		return shim.Error(fmt.Sprintf("Could not put operation for %s in the ledger: %s", name, compositePutErr.Error()))
+		return fmt.Errorf("Could not put operation for %s in the ledger: %s", name, compositePutErr.Error())
 	}
 
-	return shim.Success([]byte(fmt.Sprintf("Successfully added %s%s to %s", op, args[1], name)))
+	return nil
 }
 
-/**
- * Retrieves the aggregate value of a variable in the ledger. Gets all delta rows for the variable
- * and computes the final value from all deltas. The args array for the invocation must contain the
- * following argument:
- *	- args[0] -> The name of the variable to get the value of
- *
- * @param APIstub The chaincode shim
- * @param args The arguments array for the get invocation
- *
- * @return A response structure indicating success or failure with a message
- */
-func (s *SmartContract) get(APIstub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Check we have a valid number of args
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments, expecting 1")
+// deltaKey returns the key of a delta row of a variable
+func deltaKey(APIstub shim.ChaincodeStubInterface, name string, delta DeltaRow) (string, error) {
+	key, err := APIstub.CreateCompositeKey("varName~op~value~txID", []string{name, delta.Operation, delta.Value, delta.TxID})
+	if err != nil {
+		return "", fmt.Errorf("Could not create a composite key for %s: %s", name, err.Error())
 	}
+	return key, nil
+}
 
-	name := args[0]
-	// Get all deltas for the variable
+// readDeltas returns the delta rows of a variable in the order they are aggregated: the order of
+// the time of the transactions which added them, then of their transaction IDs. The order does not
+// depend on the deltas added afterwards, so that a "-" delta rejected by a counter stays rejected.
+func readDeltas(APIstub shim.ChaincodeStubInterface, name string) ([]DeltaRow, error) {
 	deltaResultsIterator, deltaErr := APIstub.GetStateByPartialCompositeKey("varName~op~value~txID", []string{name})
 	if deltaErr != nil {
-		return shim.Error(fmt.Sprintf("Could not retrieve value for %s: %s", name, deltaErr.Error()))
+		return nil, fmt.Errorf("Could not retrieve delta rows for %s: %s", name, deltaErr.Error())
 	}
 	defer deltaResultsIterator.Close()
 
-	// Check the variable existed
-	if !deltaResultsIterator.HasNext() {
-		return shim.Error(fmt.Sprintf("No variable by the name %s exists", name))
-	}
-
-	// Iterate through result set and compute final value
-	var finalVal float64
-	var i int
-	for i = 0; deltaResultsIterator.HasNext(); i++ {
-		// Get the next row
+	deltas := []DeltaRow{}
+	for deltaResultsIterator.HasNext() {
 		responseRange, nextErr := deltaResultsIterator.Next()
 		if nextErr != nil {
-			return shim.Error(nextErr.Error())
+			return nil, nextErr
 		}
 
 		// Split the composite key into its component parts
 		_, keyParts, splitKeyErr := APIstub.SplitCompositeKey(responseRange.Key)
 		if splitKeyErr != nil {
-			return shim.Error(splitKeyErr.Error())
+			return nil, splitKeyErr
 		}
+		deltas = append(deltas, DeltaRow{Operation: keyParts[1], Value: keyParts[2], TxID: keyParts[3], Timestamp: deltaTime(responseRange.Value)})
+	}
 
-		// Retrieve the delta value and operation
-		operation := keyParts[1]
-		valueStr := keyParts[2]
+	sortDeltas(deltas)
+	return deltas, nil
+}
 
-		// Convert the value string and perform the operation
-		value, convErr := strconv.ParseFloat(valueStr, 64)
-		if convErr != nil {
-			return shim.Error(convErr.Error())
+// sortDeltas sorts delta rows in the order they are aggregated
+func sortDeltas(deltas []DeltaRow) {
+	sort.SliceStable(deltas, func(i, j int) bool {
+		if deltas[i].Timestamp != deltas[j].Timestamp {
+			return deltas[i].Timestamp < deltas[j].Timestamp
 		}
+		return deltas[i].TxID < deltas[j].TxID
+	})
+}
 
-		switch operation {
-		case "+":
-			finalVal += value
-		case "-":
-			finalVal -= value
-		default:
-			return shim.Error(fmt.Sprintf("Unrecognized operation %s", operation))
-		}
+// typeOfVariable returns the type of a variable, the variables without a declared type are sums
+func typeOfVariable(APIstub shim.ChaincodeStubInterface, name string) (string, error) {
+	typeName, err := getType(APIstub, name)
+	if err != nil {
+		return "", err
 	}
+	if typeName == "" {
+		typeName = typeSum
+	}
+	return typeName, nil
+}
 
-	return shim.Success([]byte(strconv.FormatFloat(finalVal, 'f', -1, 64)))
+// newAggregate returns the aggregate of the deltas of a variable, according to its type
+func (s *SmartContract) newAggregate(APIstub shim.ChaincodeStubInterface, name string) (aggregate, error) {
+	typeName, err := typeOfVariable(APIstub, name)
+	if err != nil {
+		return nil, err
+	}
+	varType, ok := variableTypes[typeName]
+	if !ok {
+		return nil, fmt.Errorf("Unknown type %s of variable %s", typeName, name)
+	}
+
+	scale, err := s.getScale(APIstub)
+	if err != nil {
+		return nil, err
+	}
+	return varType.newAggregate(scale), nil
 }
 
 /**
- * Prunes a variable by deleting all of its delta rows while computing the final value. Once all rows
- * have been processed and deleted, a single new row is added which defines a delta containing the final
- * computed value of the variable. The args array contains the following argument:
- *	- args[0] -> The name of the variable to prune
+ * Retrieves the aggregate value of a variable in the ledger. Gets the checkpoint and all remaining delta
+ * rows for the variable and computes the final value from them.
  *
- * @param APIstub The chaincode shim
- * @param args The args array for the prune invocation
+ * @param ctx The transaction context
+ * @param name The name of the variable to get the value of
  *
- * @return A response structure indicating success or failure with a message
+ * @return The value of the variable, or an error
  */
-func (s *SmartContract) prune(APIstub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Check we have a valid number of ars
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments, expecting 1")
+func (s *SmartContract) Get(ctx contractapi.TransactionContextInterface, name string) (*Variable, error) {
+	APIstub := ctx.GetStub()
+
+	finalVal, rows, exists, err := s.value(APIstub, name)
+	if err != nil {
+		return nil, err
 	}
 
-	// Retrieve the name of the variable to prune
-	name := args[0]
+	// Check the variable existed
+	if !exists {
+		return nil, fmt.Errorf("No variable by the name %s exists", name)
+	}
 
-	// Get all delta rows for the variable
-	deltaResultsIterator, deltaErr := APIstub.GetStateByPartialCompositeKey("varName~op~value~txID", []string{name})
-	if deltaErr != nil {
-		return shim.Error(fmt.Sprintf("Could not retrieve value for %s: %s", name, deltaErr.Error()))
+	typeName, err := typeOfVariable(APIstub, name)
+	if err != nil {
+		return nil, err
 	}
-	defer deltaResultsIterator.Close()
+	return &Variable{Name: name, Type: typeName, Value: finalVal.String(), DeltaCount: rows}, nil
+}
 
-	// Check the variable existed
-	if !deltaResultsIterator.HasNext() {
-		return shim.Error(fmt.Sprintf("No variable by the name %s exists", name))
+// value returns the aggregate of the checkpoint and the delta rows of a variable, the number of delta
+// rows, and false if the variable does not exist
+func (s *SmartContract) value(APIstub shim.ChaincodeStubInterface, name string) (aggregate, int, bool, error) {
+	// Get all deltas for the variable
+	deltas, err := readDeltas(APIstub, name)
+	if err != nil {
+		return nil, 0, false, err
 	}
 
-	// Iterate through result set computing final value while iterating and deleting each key
-	var finalVal float64
-	var i int
-	for i = 0; deltaResultsIterator.HasNext(); i++ {
-		// Get the next row
-		responseRange, nextErr := deltaResultsIterator.Next()
-		if nextErr != nil {
-			return shim.Error(nextErr.Error())
-		}
+	// Start from the checkpoint of the compacted deltas
+	finalVal, cp, _, err := s.loadCheckpoint(APIstub, name)
+	if err != nil {
+		return nil, 0, false, err
+	}
 
-		// Split the key into its composite parts
-		_, keyParts, splitKeyErr := APIstub.SplitCompositeKey(responseRange.Key)
-		if splitKeyErr != nil {
-			return shim.Error(splitKeyErr.Error())
+	// Compute the final value from the deltas, in order
+	for _, delta := range deltas {
+		_, applyErr := finalVal.apply(delta.Operation, delta.Value)
+		if applyErr != nil {
+			return nil, 0, false, applyErr
 		}
+	}
+
+	return finalVal, len(deltas), cp != nil || len(deltas) > 0, nil
+}
 
-		// Retrieve the operation and value
-		operation := keyParts[1]
-		valueStr := keyParts[2]
+/**
+ * Prunes a variable by deleting its checkpoint and all of its delta rows while computing the final value.
+ * Once all rows have been processed and deleted, a single new row is added which defines a delta containing
+ * the final computed value of the variable.
+ *
+ * @param ctx The transaction context
+ * @param name The name of the variable to prune
+ *
+ * @return The final value of the variable and the number of rows pruned, or an error
+ */
+func (s *SmartContract) Prune(ctx contractapi.TransactionContextInterface, name string) (*PruneResult, error) {
+	APIstub := ctx.GetStub()
+
+	// Get all delta rows for the variable
+	deltas, err := readDeltas(APIstub, name)
+	if err != nil {
+		return nil, err
+	}
 
-		// Convert the value to a float
-		value, convErr := strconv.ParseFloat(valueStr, 64)
-		if convErr != nil {
-			return shim.Error(convErr.Error())
+	// Start from the checkpoint of the compacted deltas
+	finalVal, cp, checkpointKey, err := s.loadCheckpoint(APIstub, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the variable existed
+	if cp == nil && len(deltas) == 0 {
+		return nil, fmt.Errorf("No variable by the name %s exists", name)
+	}
+
+	// Compute the final value while deleting each row, in order
+	for _, delta := range deltas {
+		key, keyErr := deltaKey(APIstub, name, delta)
+		if keyErr != nil {
+			return nil, keyErr
 		}
 
 		// Delete the row from the ledger
-		deltaRowDelErr := APIstub.DelState(responseRange.Key)
+		deltaRowDelErr := APIstub.DelState(key)
 		if deltaRowDelErr != nil {
-			return shim.Error(fmt.Sprintf("Could not delete delta row: %s", deltaRowDelErr.Error()))
+			return nil, fmt.Errorf("Could not delete delta row: %s", deltaRowDelErr.Error())
 		}
 
 		// Add the value of the deleted row to the final aggregate
-		switch operation {
-		case "+":
-			finalVal += value
-		case "-":
-			finalVal -= value
-		default:
-			return shim.Error(fmt.Sprintf("Unrecognized operation %s", operation))
+		_, applyErr := finalVal.apply(delta.Operation, delta.Value)
+		if applyErr != nil {
+			return nil, applyErr
+		}
+	}
+
+	// The checkpoint is included in the final value
+	if cp != nil {
+		checkpointDelErr := APIstub.DelState(checkpointKey)
+		if checkpointDelErr != nil {
+			return nil, fmt.Errorf("Could not delete the checkpoint of %s: %s", name, checkpointDelErr.Error())
 		}
 	}
 
 	// Update the ledger with the final value
-	updateResp := s.update(APIstub, []string{name, strconv.FormatFloat(finalVal, 'f', -1, 64), "+"})
-	if updateResp.Status == ERROR {
-		return shim.Error(fmt.Sprintf("Could not update the final value of the variable after pruning: %s", updateResp.Message))
+	for _, delta := range finalVal.deltas() {
+		putErr := putDelta(APIstub, name, delta[0], delta[1])
+		if putErr != nil {
+			return nil, fmt.Errorf("Could not update the final value of the variable after pruning: %s", putErr.Error())
+		}
 	}
 
-	return shim.Success([]byte(fmt.Sprintf("Successfully pruned variable %s, final value is %f, %d rows pruned", args[0], finalVal, i)))
+	result := &PruneResult{Name: name, Value: finalVal.String(), RowsPruned: len(deltas)}
+	if sum, ok := finalVal.(*sumAggregate); ok {
+		result.DeltasRejected = sum.rejected
+	}
+	return result, nil
 }
 
 /**
- * Deletes all rows associated with an aggregate variable from the ledger. The args array
- * contains the following argument:
- *	- args[0] -> The name of the variable to delete
+ * Deletes all rows associated with an aggregate variable from the ledger, including its type,
+ * checkpoint and floor.
  *
- * @param APIstub The chaincode shim
- * @param args The arguments array for the delete invocation
+ * @param ctx The transaction context
+ * @param name The name of the variable to delete
  *
- * @return A response structure indicating success or failure with a message
+ * @return The number of delta rows deleted, or an error
  */
-func (s *SmartContract) delete(APIstub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Check there are a correct number of arguments
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments, expecting 1")
-	}
-
-	// Retrieve the variable name
-	name := args[0]
+func (s *SmartContract) Delete(ctx contractapi.TransactionContextInterface, name string) (*DeleteResult, error) {
+	APIstub := ctx.GetStub()
 
 	// Delete all delta rows
 	deltaResultsIterator, deltaErr := APIstub.GetStateByPartialCompositeKey("varName~op~value~txID", []string{name})
 	if deltaErr != nil {
-		return shim.Error(fmt.Sprintf("Could not retrieve delta rows for %s: %s", name, deltaErr.Error()))
+		return nil, fmt.Errorf("Could not retrieve delta rows for %s: %s", name, deltaErr.Error())
 	}
 	defer deltaResultsIterator.Close()
 
+	cp, checkpointKey, err := getCheckpoint(APIstub, name)
+	if err != nil {
+		return nil, err
+	}
+
+	varFloor, err := getFloor(APIstub, name)
+	if err != nil {
+		return nil, err
+	}
+
 	// Ensure the variable exists
-	if !deltaResultsIterator.HasNext() {
-		return shim.Error(fmt.Sprintf("No variable by the name %s exists", name))
+	if cp == nil && varFloor == nil && !deltaResultsIterator.HasNext() {
+		return nil, fmt.Errorf("No variable by the name %s exists", name)
 	}
 
 	// Iterate through result set and delete all indices
@@ -318,79 +479,149 @@ func (s *SmartContract) delete(APIstub shim.ChaincodeStubInterface, args []strin
 	for i = 0; deltaResultsIterator.HasNext(); i++ {
 		responseRange, nextErr := deltaResultsIterator.Next()
 		if nextErr != nil {
-			return shim.Error(fmt.Sprintf("Could not retrieve next delta row: %s", nextErr.Error()))
+			return nil, fmt.Errorf("Could not retrieve next delta row: %s", nextErr.Error())
 		}
 
 		deltaRowDelErr := APIstub.DelState(responseRange.Key)
 		if deltaRowDelErr != nil {
-			return shim.Error(fmt.Sprintf("Could not delete delta row: %s", deltaRowDelErr.Error()))
+			return nil, fmt.Errorf("Could not delete delta row: %s", deltaRowDelErr.Error())
 		}
 	}
 
-	return shim.Success([]byte(fmt.Sprintf("Deleted %s, %d rows removed", name, i)))
+	// Delete the checkpoint of the compacted deltas
+	if cp != nil {
+		checkpointDelErr := APIstub.DelState(checkpointKey)
+		if checkpointDelErr != nil {
+			return nil, fmt.Errorf("Could not delete the checkpoint of %s: %s", name, checkpointDelErr.Error())
+		}
+	}
+
+	// Delete the floor and the shards of the balance
+	if varFloor != nil {
+		err = deleteFloor(APIstub, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Delete the type, so that the variable can be declared again
+	typeKey, typeKeyErr := APIstub.CreateCompositeKey(typeIndexName, []string{name})
+	if typeKeyErr != nil {
+		return nil, fmt.Errorf("Could not create a composite key for %s: %s", name, typeKeyErr.Error())
+	}
+	typeDelErr := APIstub.DelState(typeKey)
+	if typeDelErr != nil {
+		return nil, fmt.Errorf("Could not delete the type of %s: %s", name, typeDelErr.Error())
+	}
+
+	return &DeleteResult{Name: name, RowsDeleted: i}, nil
 }
 
 /**
- * Converts a float64 to a byte array
+ * Sets the scale of the variables, the maximum number of decimal places of their deltas, which is 18 by
+ * default. The scale cannot be changed once a delta is added to a variable, as the aggregate values of
+ * existing variables could not be represented.
  *
- * @param f The float64 to convert
+ * @param ctx The transaction context
+ * @param scale The number of decimal places, between 0 and 100
  *
- * @return The byte array representation
+ * @return An error if the scale cannot be set
  */
-func f2barr(f float64) []byte {
-	str := strconv.FormatFloat(f, 'f', -1, 64)
+func (s *SmartContract) SetScale(ctx contractapi.TransactionContextInterface, scale int) error {
+	APIstub := ctx.GetStub()
+
+	if scale < 0 || scale > maxScale {
+		return fmt.Errorf("The scale must be a number of decimal places between 0 and %d", maxScale)
+	}
+
+	// Ensure no variable has deltas
+	deltaResultsIterator, deltaErr := APIstub.GetStateByPartialCompositeKey("varName~op~value~txID", []string{})
+	if deltaErr != nil {
+		return fmt.Errorf("Could not retrieve delta rows: %s", deltaErr.Error())
+	}
+	defer deltaResultsIterator.Close()
+	checkpointResultsIterator, checkpointErr := APIstub.GetStateByPartialCompositeKey(checkpointIndexName, []string{})
+	if checkpointErr != nil {
+		return fmt.Errorf("Could not retrieve checkpoints: %s", checkpointErr.Error())
+	}
+	defer checkpointResultsIterator.Close()
+	floorResultsIterator, floorErr := APIstub.GetStateByPartialCompositeKey(floorIndexName, []string{})
+	if floorErr != nil {
+		return fmt.Errorf("Could not retrieve floors: %s", floorErr.Error())
+	}
+	defer floorResultsIterator.Close()
+	if deltaResultsIterator.HasNext() || checkpointResultsIterator.HasNext() || floorResultsIterator.HasNext() {
+		return fmt.Errorf("The scale cannot be changed once deltas are added")
+	}
 
-	return []byte(str)
+	putErr := APIstub.PutState(scaleKey, []byte(strconv.Itoa(scale)))
+	if putErr != nil {
+		return fmt.Errorf("Could not put the scale in the ledger: %s", putErr.Error())
+	}
+
+	return nil
 }
 
 // The main function is only relevant in unit test mode. Only included here for completeness.
 func main() {
 
 	// Create a new Smart Contract
-	err := shim.Start(new(SmartContract))
+	chaincode, err := newChaincode()
 	if err != nil {
 		fmt.Printf("Error creating new Smart Contract: %s", err)
+		return
+	}
+
+	err = chaincode.Start()
+	if err != nil {
+		fmt.Printf("Error starting new Smart Contract: %s", err)
 	}
 }
 
+// newChaincode returns the chaincode of the contract, whose functions are described by the metadata
+// returned by org.hyperledger.fabric:GetMetadata
+func newChaincode() (*contractapi.ContractChaincode, error) {
+	contract := new(SmartContract)
+	contract.Info.Title = "HighThroughputContract"
+	contract.Info.Description = "Variables aggregated from deltas, which concurrent transactions add without conflicts"
+	contract.Info.Version = "0.0.1"
+
+	return contractapi.NewChaincode(contract)
+}
+
 /**
  * All functions below this are for testing traditional editing of a single row
  */
-func (s *SmartContract) putStandard(APIstub shim.ChaincodeStubInterface, args []string) pb.Response {
-	name := args[0]
-	valStr := args[1]
+func (s *SmartContract) PutStandard(ctx contractapi.TransactionContextInterface, name string, valStr string) error {
+	APIstub := ctx.GetStub()
 
 	_, getErr := APIstub.GetState(name)
 	if getErr != nil {
-		return shim.Error(fmt.Sprintf("Failed to retrieve the state of %s: %s", name, getErr.Error()))
+		return fmt.Errorf("Failed to retrieve the state of %s: %s", name, getErr.Error())
 	}
 
 	putErr := APIstub.PutState(name, []byte(valStr))
 	if putErr != nil {
-		return shim.Error(fmt.Sprintf("Failed to put state: %s", putErr.Error()))
+		return fmt.Errorf("Failed to put state: %s", putErr.Error())
 	}
 
-	return shim.Success(nil)
+	return nil
 }
 
-func (s *SmartContract) getStandard(APIstub shim.ChaincodeStubInterface, args []string) pb.Response {
-	name := args[0]
-
-	val, getErr := APIstub.GetState(name)
+func (s *SmartContract) GetStandard(ctx contractapi.TransactionContextInterface, name string) (string, error) {
+	val, getErr := ctx.GetStub().GetState(name)
 	if getErr != nil {
-		return shim.Error(fmt.Sprintf("Failed to get state: %s", getErr.Error()))
+		return "", fmt.Errorf("Failed to get state: %s", getErr.Error())
 	}
 
-	return shim.Success(val)
+	return string(val), nil
 }
 
-func (s *SmartContract) delStandard(APIstub shim.ChaincodeStubInterface, args []string) pb.Response {
-	name := args[0]
-
-	getErr := APIstub.DelState(name)
+func (s *SmartContract) DelStandard(ctx contractapi.TransactionContextInterface, name string) error {
+	getErr := ctx.GetStub().DelState(name)
 	if getErr != nil {
-		return shim.Error(fmt.Sprintf("Failed to delete state: %s", getErr.Error()))
+		return fmt.Errorf("Failed to delete state: %s", getErr.Error())
 	}
 
-	return shim.Success(nil)
+	return nil
 }
@@ -0,0 +1,316 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+func TestGetAndPrune(t *testing.T) {
+	stub := newStub(t)
+	expectResponse(t, invoke(stub, "SetScale", "2"), shim.OK, "")
+
+	for i := 0; i < 1000; i++ {
+		response := invoke(stub, "Update", "myvar", "0.10", "+", "")
+		if response.Status != shim.OK {
+			t.Fatalf("unexpected response %v", response)
+		}
+	}
+	expectResponse(t, invoke(stub, "Update", "myvar", "0.05", "-", ""), shim.OK, `{"name":"myvar","operation":"-","value":"0.05"}`)
+	expectResponse(t, invoke(stub, "Update", "myvar", "0.05", "-"), shim.ERROR, "Incorrect number of params. Expected 4, received 3")
+	expectResponse(t, invoke(stub, "Update", "myvar", "0.001", "+", ""), shim.ERROR, "Provided value was not a number: 0.001 has more than 2 decimal places")
+	expectResponse(t, invoke(stub, "Get", "myvar"), shim.OK, `{"name":"myvar","type":"sum","value":"99.95","deltaCount":1001}`)
+	expectResponse(t, invoke(stub, "Prune", "myvar"), shim.OK, `{"name":"myvar","value":"99.95","rowsPruned":1001,"deltasRejected":0}`)
+	expectResponse(t, invoke(stub, "Get", "myvar"), shim.OK, `{"name":"myvar","type":"sum","value":"99.95","deltaCount":1}`)
+	expectResponse(t, invoke(stub, "SetScale", "4"), shim.ERROR, "The scale cannot be changed once deltas are added")
+}
+
+// The delta rows stored by the versions of the chaincode summing floats hold any number accepted by
+// strconv.ParseFloat, and a 0x00 value
+func TestLegacyDeltas(t *testing.T) {
+	stub := newStub(t)
+	expectResponse(t, invoke(stub, "SetScale", "2"), shim.OK, "")
+
+	stub.MockTransactionStart("legacy")
+	for i, delta := range [][2]string{{"+", "1e2"}, {"+", "0x1p-2"}, {"+", "0.125"}, {"-", "NaN"}, {"+", "Inf"}} {
+		key, err := stub.CreateCompositeKey("varName~op~value~txID", []string{"legacy", delta[0], delta[1], fmt.Sprintf("legacy%d", i)})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		stub.PutState(key, []byte{0x00})
+	}
+	stub.MockTransactionEnd("legacy")
+
+	// the finite deltas are rounded to the scale, the others are skipped
+	expectResponse(t, invoke(stub, "Get", "legacy"), shim.OK, `{"name":"legacy","type":"sum","value":"100.38","deltaCount":5}`)
+	expectUpdate(t, stub, "legacy", "1", "+", "")
+	expectResponse(t, invoke(stub, "Update", "legacy", "NaN", "+", ""), shim.ERROR, "Provided value was not a number: NaN is not a decimal number")
+	expectResponse(t, invoke(stub, "Prune", "legacy"), shim.OK, `{"name":"legacy","value":"101.38","rowsPruned":6,"deltasRejected":2}`)
+	expectValue(t, stub, "legacy", "101.38")
+}
+
+// The delta rows stored before the time of the transactions was recorded are older than any other
+// row, so they are compacted whatever the minimum age
+func TestCompactLegacyDeltas(t *testing.T) {
+	stub := newStub(t)
+	expectResponse(t, invoke(stub, "SetScale", "2"), shim.OK, "")
+
+	stub.MockTransactionStart("legacy")
+	for i, value := range []string{"1e2", "0.125"} {
+		key, err := stub.CreateCompositeKey("varName~op~value~txID", []string{"legacy", "+", value, fmt.Sprintf("legacy%d", i)})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		stub.PutState(key, []byte{0x00})
+	}
+	stub.MockTransactionEnd("legacy")
+	expectUpdate(t, stub, "legacy", "1", "+", "")
+
+	response := invoke(stub, "Compactable", "legacy", "3600", "10")
+	expectResponse(t, response, shim.OK, `[{"op":"+","value":"1e2","txId":"legacy0","timestamp":0},{"op":"+","value":"0.125","txId":"legacy1","timestamp":0}]`)
+	expectResponse(t, invoke(stub, "Compact", "legacy", "3600", string(response.Payload)), shim.OK, `{"name":"legacy","rowsCompacted":2,"rowsSkipped":0,"deltasRejected":0}`)
+	expectResponse(t, invoke(stub, "Get", "legacy"), shim.OK, `{"name":"legacy","type":"sum","value":"101.13","deltaCount":1}`)
+}
+
+// The deltas of a counter older than the time window of the peers are compacted in the order of
+// their transactions, keeping the rejected deltas rejected
+func TestCompactCounter(t *testing.T) {
+	stub := newStub(t)
+	expectUpdate(t, stub, "stock", "5", "+", "counter")
+	putDeltaRow(t, stub, "stock", "+", "2", "old1", 2*time.Hour)
+	putDeltaRow(t, stub, "stock", "-", "8", "old2", 90*time.Minute)
+	putDeltaRow(t, stub, "stock", "+", "10", "old3", time.Hour)
+	expectResponse(t, invoke(stub, "Get", "stock"), shim.OK, `{"name":"stock","type":"counter","value":"17","deltaCount":4}`)
+
+	expectResponse(t, invoke(stub, "Compactable", "stock", "600", "10"), shim.ERROR, "The deltas of the counter variable stock must be at least 900 seconds old to be compacted")
+	expectResponse(t, invoke(stub, "Compact", "stock", "0", "[]"), shim.ERROR, "The deltas of the counter variable stock must be at least 900 seconds old to be compacted")
+
+	// the rejected delta is counted once, and stays rejected after a later "+" delta
+	response := invoke(stub, "Compactable", "stock", "900", "2")
+	expectResponse(t, invoke(stub, "Compact", "stock", "900", string(response.Payload)), shim.OK, `{"name":"stock","rowsCompacted":2,"rowsSkipped":0,"deltasRejected":1}`)
+	expectResponse(t, invoke(stub, "Get", "stock"), shim.OK, `{"name":"stock","type":"counter","value":"17","deltaCount":2}`)
+	expectUpdate(t, stub, "stock", "20", "+", "")
+	expectValue(t, stub, "stock", "37")
+
+	response = invoke(stub, "Compactable", "stock", "900", "10")
+	expectResponse(t, invoke(stub, "Compact", "stock", "900", string(response.Payload)), shim.OK, `{"name":"stock","rowsCompacted":1,"rowsSkipped":0,"deltasRejected":0}`)
+	expectValue(t, stub, "stock", "37")
+	expectResponse(t, invoke(stub, "Prune", "stock"), shim.OK, `{"name":"stock","value":"37","rowsPruned":2,"deltasRejected":1}`)
+}
+
+// putDeltaRow adds a delta row to a variable as if it was added age ago
+func putDeltaRow(t *testing.T, stub *shimtest.MockStub, name string, op string, value string, txID string, age time.Duration) {
+	t.Helper()
+	key, err := stub.CreateCompositeKey("varName~op~value~txID", []string{name, op, value, txID})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stub.MockTransactionStart(txID)
+	stub.PutState(key, []byte(strconv.FormatInt(time.Now().Add(-age).UnixNano(), 10)))
+	stub.MockTransactionEnd(txID)
+}
+
+func TestStandard(t *testing.T) {
+	stub := newStub(t)
+	expectResponse(t, invoke(stub, "PutStandard", "myvar", "10"), shim.OK, "")
+	expectResponse(t, invoke(stub, "GetStandard", "myvar"), shim.OK, "10")
+	expectResponse(t, invoke(stub, "DelStandard", "myvar"), shim.OK, "")
+	expectResponse(t, invoke(stub, "GetStandard", "myvar"), shim.OK, "")
+}
+
+func TestMetadata(t *testing.T) {
+	stub := newStub(t)
+	response := invoke(stub, "org.hyperledger.fabric:GetMetadata")
+	if response.Status != shim.OK {
+		t.Fatalf("unexpected response %v", response)
+	}
+
+	var contractMetadata metadata.ContractChaincodeMetadata
+	err := json.Unmarshal(response.Payload, &contractMetadata)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	contract := contractMetadata.Contracts["SmartContract"]
+	if contract.Info == nil || contract.Info.Title != "HighThroughputContract" || !contract.Default {
+		t.Errorf("unexpected contract %+v", contract)
+	}
+	var functions []string
+	for _, transaction := range contract.Transactions {
+		functions = append(functions, transaction.Name)
+	}
+	sort.Strings(functions)
+	expected := "Compact Compactable DelStandard Delete Get GetStandard Init Prune PutStandard SetFloor SetScale Update"
+	if strings.Join(functions, " ") != expected {
+		t.Errorf("expected the functions %s, got %v", expected, functions)
+	}
+}
+
+// newStub returns a mock stub of the chaincode
+func newStub(t *testing.T) *shimtest.MockStub {
+	t.Helper()
+	chaincode, err := newChaincode()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return shimtest.NewMockStub("bigdatacc", chaincode)
+}
+
+// invoke invokes the chaincode with args in a new transaction
+func invoke(stub *shimtest.MockStub, args ...string) pb.Response {
+	txCount++
+	byteArgs := make([][]byte, 0, len(args))
+	for _, arg := range args {
+		byteArgs = append(byteArgs, []byte(arg))
+	}
+	return stub.MockInvoke(fmt.Sprintf("tx%06d", txCount), byteArgs)
+}
+
+var txCount int
+
+func expectResponse(t *testing.T, response pb.Response, status int32, message string) {
+	t.Helper()
+	actual := string(response.Payload)
+	if status == shim.ERROR {
+		actual = response.Message
+	}
+	if response.Status != status || actual != message {
+		t.Errorf("expected response %d %q, got %d %q", status, message, response.Status, actual)
+	}
+}
+
+// expectUpdate adds a delta to a variable, and expects the update to succeed
+func expectUpdate(t *testing.T, stub *shimtest.MockStub, name string, value string, op string, variableType string) {
+	t.Helper()
+	expected, _ := json.Marshal(&Delta{Name: name, Operation: op, Value: value})
+	expectResponse(t, invoke(stub, "Update", name, value, op, variableType), shim.OK, string(expected))
+}
+
+// expectValue gets a variable, and expects its value
+func expectValue(t *testing.T, stub *shimtest.MockStub, name string, value string) {
+	t.Helper()
+	response := invoke(stub, "Get", name)
+	var variable Variable
+	err := json.Unmarshal(response.Payload, &variable)
+	if response.Status != shim.OK || err != nil || variable.Value != value {
+		t.Errorf("expected %s to be %s, got %d %q %q", name, value, response.Status, response.Payload, response.Message)
+	}
+}
+
+func TestTypes(t *testing.T) {
+	stub := newStub(t)
+
+	// min and max are declared by their operation
+	for _, value := range []string{"7", "-2.5", "12"} {
+		expectUpdate(t, stub, "low", value, "min", "")
+		expectUpdate(t, stub, "high", value, "max", "")
+	}
+	expectResponse(t, invoke(stub, "Get", "low"), shim.OK, `{"name":"low","type":"min","value":"-2.5","deltaCount":3}`)
+	expectValue(t, stub, "high", "12")
+	expectResponse(t, invoke(stub, "Update", "low", "1", "+", ""), shim.ERROR, "Operator + is not supported by the min variable low, expecting min")
+	expectResponse(t, invoke(stub, "Update", "low", "1", "min", "max"), shim.ERROR, "The variable low is declared as min")
+	expectResponse(t, invoke(stub, "Prune", "high"), shim.OK, `{"name":"high","value":"12","rowsPruned":3,"deltasRejected":0}`)
+	expectUpdate(t, stub, "high", "11", "max", "")
+	expectValue(t, stub, "high", "12")
+
+	// sets are the union of their elements
+	for _, element := range []string{"blue", "red", "blue", "green"} {
+		expectUpdate(t, stub, "colors", element, "add", "")
+	}
+	expectResponse(t, invoke(stub, "Get", "colors"), shim.OK, `{"name":"colors","type":"set","value":"[\"blue\",\"green\",\"red\"]","deltaCount":4}`)
+	expectResponse(t, invoke(stub, "Update", "colors", "", "add", ""), shim.ERROR, "The elements of a set cannot be empty")
+	expectResponse(t, invoke(stub, "Prune", "colors"), shim.OK, `{"name":"colors","value":"[\"blue\",\"green\",\"red\"]","rowsPruned":4,"deltasRejected":0}`)
+	expectValue(t, stub, "colors", `["blue","green","red"]`)
+
+	// counters are declared by their type, and reject the deltas going below zero
+	expectUpdate(t, stub, "stock", "10", "+", "counter")
+	expectResponse(t, invoke(stub, "Update", "stock", "-1", "+", ""), shim.ERROR, "The deltas of a counter cannot be negative")
+	for _, value := range []string{"4", "8", "5"} {
+		expectUpdate(t, stub, "stock", value, "-", "")
+	}
+	expectValue(t, stub, "stock", "1")
+	expectResponse(t, invoke(stub, "Prune", "stock"), shim.OK, `{"name":"stock","value":"1","rowsPruned":4,"deltasRejected":1}`)
+	expectUpdate(t, stub, "stock", "3", "+", "")
+	expectValue(t, stub, "stock", "4")
+
+	// the deltas are aggregated in the order of their transactions, so that a rejected "-" delta
+	// stays rejected after a later "+" delta, although its key sorts after the key of the "+" delta
+	expectUpdate(t, stub, "stock", "9", "-", "")
+	expectValue(t, stub, "stock", "4")
+	expectUpdate(t, stub, "stock", "10", "+", "")
+	expectValue(t, stub, "stock", "14")
+	expectResponse(t, invoke(stub, "Prune", "stock"), shim.OK, `{"name":"stock","value":"14","rowsPruned":4,"deltasRejected":1}`)
+
+	// the variables updated without a type are sums, which cannot be declared afterwards
+	expectUpdate(t, stub, "balance", "5", "-", "")
+	expectValue(t, stub, "balance", "-5")
+	expectResponse(t, invoke(stub, "Update", "balance", "5", "+", "counter"), shim.ERROR, "The variable balance is declared as sum")
+	expectResponse(t, invoke(stub, "Update", "balance", "5", "max", ""), shim.ERROR, "The variable balance is declared as sum")
+	expectResponse(t, invoke(stub, "Update", "balance", "5", "*", ""), shim.ERROR, "Operator * is unrecognized")
+	expectResponse(t, invoke(stub, "Update", "other", "5", "+", "average"), shim.ERROR, "Unknown variable type average, expecting sum, counter, min, max or set")
+
+	// a deleted variable can be declared again
+	expectResponse(t, invoke(stub, "Delete", "low"), shim.OK, `{"name":"low","rowsDeleted":3}`)
+	expectUpdate(t, stub, "low", "2", "add", "")
+	expectValue(t, stub, "low", `["2"]`)
+}
+
+func TestCompaction(t *testing.T) {
+	stub := newStub(t)
+
+	for i := 0; i < 5; i++ {
+		expectUpdate(t, stub, "myvar", "1", "+", "")
+	}
+	response := invoke(stub, "Compactable", "myvar", "0", "3")
+	var deltas []DeltaRow
+	err := json.Unmarshal(response.Payload, &deltas)
+	if err != nil || len(deltas) != 3 || deltas[0].Operation != "+" || deltas[0].Value != "1" || deltas[0].Timestamp == 0 {
+		t.Fatalf("unexpected compactable deltas %v: %v", response, err)
+	}
+	expectResponse(t, invoke(stub, "Compactable", "myvar", "3600", "3"), shim.OK, "[]")
+
+	// the deltas added after listing them are not compacted, and the value is kept
+	expectUpdate(t, stub, "myvar", "10", "+", "")
+	expectValue(t, stub, "myvar", "15")
+	expectResponse(t, invoke(stub, "Compact", "myvar", "0", string(response.Payload)), shim.OK, `{"name":"myvar","rowsCompacted":3,"rowsSkipped":0,"deltasRejected":0}`)
+	expectResponse(t, invoke(stub, "Get", "myvar"), shim.OK, `{"name":"myvar","type":"sum","value":"15","deltaCount":3}`)
+
+	// the compacted deltas and the recent deltas are skipped
+	expectResponse(t, invoke(stub, "Compact", "myvar", "0", string(response.Payload)), shim.OK, `{"name":"myvar","rowsCompacted":0,"rowsSkipped":3,"deltasRejected":0}`)
+	response = invoke(stub, "Compactable", "myvar", "0", "10")
+	expectResponse(t, invoke(stub, "Compact", "myvar", "3600", string(response.Payload)), shim.OK, `{"name":"myvar","rowsCompacted":0,"rowsSkipped":3,"deltasRejected":0}`)
+	expectResponse(t, invoke(stub, "Compact", "myvar", "-1", "[]"), shim.ERROR, "The minimum age cannot be negative")
+	expectValue(t, stub, "myvar", "15")
+
+	// prune includes the checkpoint
+	expectResponse(t, invoke(stub, "Prune", "myvar"), shim.OK, `{"name":"myvar","value":"15","rowsPruned":3,"deltasRejected":0}`)
+	expectValue(t, stub, "myvar", "15")
+
+	// the value of a partially compacted variable does not change with the deltas added later
+	expectUpdate(t, stub, "low", "5", "min", "")
+	expectUpdate(t, stub, "low", "8", "min", "")
+	response = invoke(stub, "Compactable", "low", "0", "1")
+	expectResponse(t, invoke(stub, "Compact", "low", "0", string(response.Payload)), shim.OK, `{"name":"low","rowsCompacted":1,"rowsSkipped":0,"deltasRejected":0}`)
+	expectUpdate(t, stub, "low", "3", "min", "")
+	expectValue(t, stub, "low", "3")
+
+	// a variable whose deltas are all compacted still exists, and keeps its type
+	response = invoke(stub, "Compactable", "low", "0", "10")
+	expectResponse(t, invoke(stub, "Compact", "low", "0", string(response.Payload)), shim.OK, `{"name":"low","rowsCompacted":2,"rowsSkipped":0,"deltasRejected":0}`)
+	expectResponse(t, invoke(stub, "Get", "low"), shim.OK, `{"name":"low","type":"min","value":"3","deltaCount":0}`)
+	expectResponse(t, invoke(stub, "Update", "low", "1", "+", ""), shim.ERROR, "Operator + is not supported by the min variable low, expecting min")
+	expectResponse(t, invoke(stub, "Delete", "low"), shim.OK, `{"name":"low","rowsDeleted":0}`)
+	expectResponse(t, invoke(stub, "Get", "low"), shim.ERROR, "No variable by the name low exists")
+}
@@ -0,0 +1,239 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Types of the variables
+const (
+	// typeSum is the sum of "+" and "-" deltas, the type of the variables declared without a type
+	typeSum = "sum"
+	// typeCounter is the sum of "+" and "-" deltas which cannot go below zero
+	typeCounter = "counter"
+	// typeMin and typeMax are the smallest and the largest of the "min" or "max" deltas
+	typeMin = "min"
+	typeMax = "max"
+	// typeSet is the union of the strings of the "add" deltas
+	typeSet = "set"
+)
+
+// typeIndexName is the composite key index of the types of the variables
+const typeIndexName = "varName~type"
+
+// aggregate computes the value of a variable from its deltas
+type aggregate interface {
+	// apply adds the delta value with the operation to the aggregate, and returns false if the
+	// delta is rejected
+	apply(operation string, value string) (bool, error)
+	// deltas returns the operations and the values of the deltas replacing the aggregated deltas
+	// when the variable is pruned
+	deltas() [][2]string
+	// String returns the value of the variable
+	String() string
+}
+
+// variableType defines the operations of the deltas of a variable, and how they are aggregated
+type variableType struct {
+	operations   []string
+	newAggregate func(scale int) aggregate
+	// numeric is true if the deltas are decimal numbers
+	numeric bool
+}
+
+var variableTypes = map[string]*variableType{
+	typeSum: {
+		operations:   []string{"+", "-"},
+		newAggregate: func(scale int) aggregate { return &sumAggregate{value: newDecimal(scale)} },
+		numeric:      true,
+	},
+	typeCounter: {
+		operations:   []string{"+", "-"},
+		newAggregate: func(scale int) aggregate { return &sumAggregate{value: newDecimal(scale), bounded: true} },
+		numeric:      true,
+	},
+	typeMin: {
+		operations:   []string{"min"},
+		newAggregate: func(scale int) aggregate { return &extremeAggregate{operation: "min", scale: scale} },
+		numeric:      true,
+	},
+	typeMax: {
+		operations:   []string{"max"},
+		newAggregate: func(scale int) aggregate { return &extremeAggregate{operation: "max", scale: scale} },
+		numeric:      true,
+	},
+	typeSet: {
+		operations:   []string{"add"},
+		newAggregate: func(scale int) aggregate { return &setAggregate{elements: make(map[string]bool)} },
+	},
+}
+
+// typeOfOperation returns the type of a variable declared by the first delta with operation
+func typeOfOperation(operation string) (string, error) {
+	switch operation {
+	case "+", "-":
+		return typeSum, nil
+	case "min":
+		return typeMin, nil
+	case "max":
+		return typeMax, nil
+	case "add":
+		return typeSet, nil
+	}
+	return "", fmt.Errorf("Operator %s is unrecognized", operation)
+}
+
+// allows returns an error if the type does not have the operation
+func (t *variableType) allows(name string, typeName string, operation string) error {
+	for _, o := range t.operations {
+		if o == operation {
+			return nil
+		}
+	}
+	return fmt.Errorf("Operator %s is not supported by the %s variable %s, expecting %s", operation, typeName, name, strings.Join(t.operations, " or "))
+}
+
+// getType returns the type of a variable, the empty string if the variable was not declared
+func getType(APIstub shim.ChaincodeStubInterface, name string) (string, error) {
+	typeKey, err := APIstub.CreateCompositeKey(typeIndexName, []string{name})
+	if err != nil {
+		return "", fmt.Errorf("Could not create a composite key for %s: %s", name, err.Error())
+	}
+
+	typeBytes, err := APIstub.GetState(typeKey)
+	if err != nil {
+		return "", fmt.Errorf("Could not retrieve the type of %s: %s", name, err.Error())
+	}
+	return string(typeBytes), nil
+}
+
+// putType declares the type of a variable
+func putType(APIstub shim.ChaincodeStubInterface, name string, typeName string) error {
+	typeKey, err := APIstub.CreateCompositeKey(typeIndexName, []string{name})
+	if err != nil {
+		return fmt.Errorf("Could not create a composite key for %s: %s", name, err.Error())
+	}
+
+	err = APIstub.PutState(typeKey, []byte(typeName))
+	if err != nil {
+		return fmt.Errorf("Could not put the type of %s in the ledger: %s", name, err.Error())
+	}
+	return nil
+}
+
+// sumAggregate sums the deltas. A bounded sum rejects the negative deltas, and the "-" deltas
+// which would make it negative. As the deltas are aggregated in the order of their transactions,
+// a "-" delta is rejected when the deltas added before it cannot cover it, whatever the deltas
+// added after it. The NaN and infinite deltas stored by the versions of
+// the chaincode summing floats are rejected as well.
+type sumAggregate struct {
+	value    *decimal
+	bounded  bool
+	rejected int
+}
+
+func (a *sumAggregate) apply(operation string, value string) (bool, error) {
+	delta, err := parseDelta(value, a.value.scale)
+	if err == errNotFinite {
+		a.rejected++
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if a.bounded && (delta.units.Sign() < 0 || operation == "-" && delta.units.Cmp(a.value.units) > 0) {
+		a.rejected++
+		return false, nil
+	}
+
+	return true, a.value.applyDelta(operation, delta)
+}
+
+func (a *sumAggregate) deltas() [][2]string {
+	return [][2]string{{"+", a.value.String()}}
+}
+
+func (a *sumAggregate) String() string {
+	return a.value.String()
+}
+
+// extremeAggregate keeps the smallest or the largest of the deltas
+type extremeAggregate struct {
+	operation string
+	scale     int
+	value     *decimal
+}
+
+func (a *extremeAggregate) apply(operation string, value string) (bool, error) {
+	if operation != a.operation {
+		return false, fmt.Errorf("Unrecognized operation %s", operation)
+	}
+	delta, err := parseDelta(value, a.scale)
+	if err == errNotFinite {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if a.value == nil || operation == "min" && delta.units.Cmp(a.value.units) < 0 || operation == "max" && delta.units.Cmp(a.value.units) > 0 {
+		a.value = delta
+	}
+	return true, nil
+}
+
+func (a *extremeAggregate) deltas() [][2]string {
+	return [][2]string{{a.operation, a.String()}}
+}
+
+func (a *extremeAggregate) String() string {
+	if a.value == nil {
+		return newDecimal(a.scale).String()
+	}
+	return a.value.String()
+}
+
+// setAggregate is the union of the strings of the deltas
+type setAggregate struct {
+	elements map[string]bool
+}
+
+func (a *setAggregate) apply(operation string, value string) (bool, error) {
+	if operation != "add" {
+		return false, fmt.Errorf("Unrecognized operation %s", operation)
+	}
+
+	a.elements[value] = true
+	return true, nil
+}
+
+func (a *setAggregate) deltas() [][2]string {
+	deltas := make([][2]string, 0, len(a.elements))
+	for _, element := range a.sorted() {
+		deltas = append(deltas, [2]string{"add", element})
+	}
+	return deltas
+}
+
+// String returns the elements of the set as a sorted JSON array
+func (a *setAggregate) String() string {
+	elements, _ := json.Marshal(a.sorted())
+	return string(elements)
+}
+
+func (a *setAggregate) sorted() []string {
+	elements := make([]string, 0, len(a.elements))
+	for element := range a.elements {
+		elements = append(elements, element)
+	}
+	sort.Strings(elements)
+	return elements
+}
@@ -0,0 +1,248 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+)
+
+// transaction holds the writes of a valid transaction of a block
+type transaction struct {
+	BlockNumber uint64    `json:"blockNumber"`
+	Index       int       `json:"transactionIndex"`
+	TxID        string    `json:"txId"`
+	Timestamp   time.Time `json:"timestamp"`
+	Writes      []write   `json:"writes"`
+}
+
+// write is a key written or deleted by a transaction
+type write struct {
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+	IsDelete  bool   `json:"isDelete"`
+	Value     []byte `json:"value,omitempty"`
+}
+
+// ignoredNamespaces are the namespaces of the system chaincodes, whose writes are not stored
+var ignoredNamespaces = map[string]bool{
+	"lscc":       true,
+	"_lifecycle": true,
+}
+
+// blockTransactions returns the valid endorser transactions of block writing keys of the
+// chaincode namespaces
+func blockTransactions(block *common.Block) ([]*transaction, error) {
+	blockNumber := block.GetHeader().GetNumber()
+
+	var validationCodes []byte
+	if metadata := block.GetMetadata().GetMetadata(); len(metadata) > int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		validationCodes = metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+
+	var transactions []*transaction
+	for i, data := range block.GetData().GetData() {
+		// only the valid transactions update the world state
+		if i < len(validationCodes) && peer.TxValidationCode(validationCodes[i]) != peer.TxValidationCode_VALID {
+			continue
+		}
+
+		tx, err := parseTransaction(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse transaction %d of block %d: %v", i, blockNumber, err)
+		}
+		if tx == nil || len(tx.Writes) == 0 {
+			continue
+		}
+
+		tx.BlockNumber = blockNumber
+		tx.Index = i
+		transactions = append(transactions, tx)
+	}
+
+	return transactions, nil
+}
+
+// parseTransaction returns the transaction of the envelope data, or nil if it is not an
+// endorser transaction
+func parseTransaction(data []byte) (*transaction, error) {
+	envelope := &common.Envelope{}
+	err := proto.Unmarshal(data, envelope)
+	if err != nil {
+		return nil, err
+	}
+	payload := &common.Payload{}
+	err = proto.Unmarshal(envelope.Payload, payload)
+	if err != nil {
+		return nil, err
+	}
+	channelHeader := &common.ChannelHeader{}
+	err = proto.Unmarshal(payload.GetHeader().GetChannelHeader(), channelHeader)
+	if err != nil {
+		return nil, err
+	}
+	if channelHeader.Type != int32(common.HeaderType_ENDORSER_TRANSACTION) {
+		return nil, nil
+	}
+
+	tx := &transaction{TxID: channelHeader.TxId}
+	if channelHeader.Timestamp != nil {
+		tx.Timestamp, err = ptypes.Timestamp(channelHeader.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	endorserTransaction := &peer.Transaction{}
+	err = proto.Unmarshal(payload.Data, endorserTransaction)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, action := range endorserTransaction.Actions {
+		writes, err := actionWrites(action)
+		if err != nil {
+			return nil, err
+		}
+		tx.Writes = append(tx.Writes, writes...)
+	}
+
+	return tx, nil
+}
+
+// actionWrites returns the writes of the public read-write sets of action
+func actionWrites(action *peer.TransactionAction) ([]write, error) {
+	actionPayload := &peer.ChaincodeActionPayload{}
+	err := proto.Unmarshal(action.Payload, actionPayload)
+	if err != nil {
+		return nil, err
+	}
+	responsePayload := &peer.ProposalResponsePayload{}
+	err = proto.Unmarshal(actionPayload.GetAction().GetProposalResponsePayload(), responsePayload)
+	if err != nil {
+		return nil, err
+	}
+	chaincodeAction := &peer.ChaincodeAction{}
+	err = proto.Unmarshal(responsePayload.Extension, chaincodeAction)
+	if err != nil {
+		return nil, err
+	}
+	txReadWriteSet := &rwset.TxReadWriteSet{}
+	err = proto.Unmarshal(chaincodeAction.Results, txReadWriteSet)
+	if err != nil {
+		return nil, err
+	}
+
+	var writes []write
+	for _, nsReadWriteSet := range txReadWriteSet.NsRwset {
+		if ignoredNamespaces[nsReadWriteSet.Namespace] {
+			continue
+		}
+
+		kvReadWriteSet := &kvrwset.KVRWSet{}
+		err = proto.Unmarshal(nsReadWriteSet.Rwset, kvReadWriteSet)
+		if err != nil {
+			return nil, err
+		}
+		for _, kvWrite := range kvReadWriteSet.Writes {
+			writes = append(writes, write{
+				Namespace: nsReadWriteSet.Namespace,
+				Key:       kvWrite.Key,
+				IsDelete:  kvWrite.IsDelete,
+				Value:     kvWrite.Value,
+			})
+		}
+	}
+
+	return writes, nil
+}
+
+// blockSource delivers the blocks of a channel
+type blockSource interface {
+	// height returns the number of blocks of the channel
+	height() (uint64, error)
+	// block returns the block with the given number
+	block(blockNumber uint64) (*common.Block, error)
+	// subscribe returns the blocks committed from now on. The channel is closed when
+	// unsubscribe is called.
+	subscribe() (blocks <-chan *common.Block, unsubscribe func(), err error)
+}
+
+// gatewaySource is the blockSource of a gateway network. The past blocks are returned by the
+// query system chaincode.
+type gatewaySource struct {
+	network *gateway.Network
+	qscc    *gateway.Contract
+}
+
+func newGatewaySource(network *gateway.Network) *gatewaySource {
+	return &gatewaySource{
+		network: network,
+		qscc:    network.GetContract("qscc"),
+	}
+}
+
+func (s *gatewaySource) height() (uint64, error) {
+	result, err := s.qscc.EvaluateTransaction("GetChainInfo", s.network.Name())
+	if err != nil {
+		return 0, fmt.Errorf("failed to evaluate GetChainInfo: %v", err)
+	}
+
+	info := &common.BlockchainInfo{}
+	err = proto.Unmarshal(result, info)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse the chain information: %v", err)
+	}
+
+	return info.Height, nil
+}
+
+func (s *gatewaySource) block(blockNumber uint64) (*common.Block, error) {
+	result, err := s.qscc.EvaluateTransaction("GetBlockByNumber", s.network.Name(), strconv.FormatUint(blockNumber, 10))
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate GetBlockByNumber: %v", err)
+	}
+
+	block := &common.Block{}
+	err = proto.Unmarshal(result, block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse block %d: %v", blockNumber, err)
+	}
+
+	return block, nil
+}
+
+func (s *gatewaySource) subscribe() (<-chan *common.Block, func(), error) {
+	registration, blockEvents, err := s.network.RegisterBlockEvent()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to register block listener: %v", err)
+	}
+
+	blocks := make(chan *common.Block)
+	go func() {
+		defer close(blocks)
+		for blockEvent := range blockEvents {
+			blocks <- blockEvent.Block
+		}
+	}()
+
+	unsubscribe := func() {
+		s.network.Unregister(registration)
+		// the blocks received before the registration is removed are dropped
+		for range blocks {
+		}
+	}
+
+	return blocks, unsubscribe, nil
+}
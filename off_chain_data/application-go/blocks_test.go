@@ -0,0 +1,167 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+var testTime = time.Date(2020, 10, 27, 18, 1, 45, 0, time.UTC)
+
+func TestBlockTransactions(t *testing.T) {
+	block := newBlock(t, 5,
+		validTx(endorserTransaction(t, "tx1", map[string][]*kvrwset.KVWrite{
+			"basic": {
+				{Key: "asset1", Value: []byte(`{"ID":"asset1"}`)},
+				{Key: "asset2", IsDelete: true},
+			},
+			"_lifecycle": {{Key: "namespaces/fields/basic/Sequence", Value: []byte{1}}},
+		})),
+		invalidTx(endorserTransaction(t, "tx2", map[string][]*kvrwset.KVWrite{
+			"basic": {{Key: "asset3", Value: []byte(`{"ID":"asset3"}`)}},
+		})),
+		validTx(endorserTransaction(t, "tx3", map[string][]*kvrwset.KVWrite{
+			"lscc": {{Key: "basic", Value: []byte("definition")}},
+		})),
+		validTx(envelope(t, "config", common.HeaderType_CONFIG, nil)),
+		validTx(endorserTransaction(t, "tx5", map[string][]*kvrwset.KVWrite{
+			"basic": {{Key: "asset4", Value: []byte("plain")}},
+		})),
+	)
+
+	transactions, err := blockTransactions(block)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []*transaction{
+		{
+			BlockNumber: 5,
+			Index:       0,
+			TxID:        "tx1",
+			Timestamp:   testTime,
+			Writes: []write{
+				{Namespace: "basic", Key: "asset1", Value: []byte(`{"ID":"asset1"}`)},
+				{Namespace: "basic", Key: "asset2", IsDelete: true},
+			},
+		},
+		{
+			BlockNumber: 5,
+			Index:       4,
+			TxID:        "tx5",
+			Timestamp:   testTime,
+			Writes: []write{
+				{Namespace: "basic", Key: "asset4", Value: []byte("plain")},
+			},
+		},
+	}
+	if !reflect.DeepEqual(transactions, expected) {
+		t.Errorf("expected transactions %+v, got %+v", expected, transactions)
+	}
+
+	block.Data.Data = append(block.Data.Data, []byte("not a transaction"))
+	_, err = blockTransactions(block)
+	if err == nil {
+		t.Error("expected an error parsing an invalid transaction")
+	}
+}
+
+// blockTx is a transaction of a test block, with its validation code
+type blockTx struct {
+	data []byte
+	code peer.TxValidationCode
+}
+
+func validTx(data []byte) blockTx {
+	return blockTx{data: data, code: peer.TxValidationCode_VALID}
+}
+
+func invalidTx(data []byte) blockTx {
+	return blockTx{data: data, code: peer.TxValidationCode_MVCC_READ_CONFLICT}
+}
+
+func newBlock(t *testing.T, blockNumber uint64, transactions ...blockTx) *common.Block {
+	block := &common.Block{
+		Header:   &common.BlockHeader{Number: blockNumber},
+		Data:     &common.BlockData{},
+		Metadata: &common.BlockMetadata{Metadata: make([][]byte, common.BlockMetadataIndex_TRANSACTIONS_FILTER+1)},
+	}
+	for _, tx := range transactions {
+		block.Data.Data = append(block.Data.Data, tx.data)
+		block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = append(block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER], byte(tx.code))
+	}
+
+	return block
+}
+
+func endorserTransaction(t *testing.T, txID string, writes map[string][]*kvrwset.KVWrite) []byte {
+	results := &rwset.TxReadWriteSet{DataModel: rwset.TxReadWriteSet_KV}
+	for namespace, kvWrites := range writes {
+		results.NsRwset = append(results.NsRwset, &rwset.NsReadWriteSet{
+			Namespace: namespace,
+			Rwset:     marshal(t, &kvrwset.KVRWSet{Writes: kvWrites}),
+		})
+	}
+	// the namespaces are sorted, as in the read-write sets of the peers
+	for i := range results.NsRwset {
+		for j := i + 1; j < len(results.NsRwset); j++ {
+			if results.NsRwset[j].Namespace < results.NsRwset[i].Namespace {
+				results.NsRwset[i], results.NsRwset[j] = results.NsRwset[j], results.NsRwset[i]
+			}
+		}
+	}
+
+	transaction := &peer.Transaction{
+		Actions: []*peer.TransactionAction{
+			{
+				Payload: marshal(t, &peer.ChaincodeActionPayload{
+					Action: &peer.ChaincodeEndorsedAction{
+						ProposalResponsePayload: marshal(t, &peer.ProposalResponsePayload{
+							Extension: marshal(t, &peer.ChaincodeAction{
+								Results: marshal(t, results),
+							}),
+						}),
+					},
+				}),
+			},
+		},
+	}
+
+	return envelope(t, txID, common.HeaderType_ENDORSER_TRANSACTION, marshal(t, transaction))
+}
+
+func envelope(t *testing.T, txID string, headerType common.HeaderType, data []byte) []byte {
+	timestamp, err := ptypes.TimestampProto(testTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return marshal(t, &common.Envelope{
+		Payload: marshal(t, &common.Payload{
+			Header: &common.Header{
+				ChannelHeader: marshal(t, &common.ChannelHeader{Type: int32(headerType), TxId: txID, Timestamp: timestamp}),
+			},
+			Data: data,
+		}),
+	})
+}
+
+func marshal(t *testing.T, message proto.Message) []byte {
+	data, err := proto.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
@@ -0,0 +1,256 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// couchDBSink stores the writes in the databases of a CouchDB server, or of a server with the
+// same document API. The database <channel>_<chaincode> holds the latest value of the keys,
+// which emulates the world state, and the database <channel>_<chaincode>_history holds all the
+// writes, which emulates the history of the keys. A transaction written again replaces the
+// same documents.
+type couchDBSink struct {
+	address   *url.URL
+	channel   string
+	client    *http.Client
+	databases map[string]bool
+}
+
+// couchDBError is the body of the error responses of CouchDB
+type couchDBError struct {
+	Error  string `json:"error"`
+	Reason string `json:"reason"`
+}
+
+func newCouchDBSink(address string, channel string) (*couchDBSink, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CouchDB address: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid CouchDB address %q, expected an http or https URL", address)
+	}
+
+	return &couchDBSink{
+		address:   u,
+		channel:   channel,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		databases: make(map[string]bool),
+	}, nil
+}
+
+func (s *couchDBSink) write(tx *transaction) error {
+	for i, w := range tx.Writes {
+		stateDB := databaseName(s.channel, w.Namespace)
+		historyDB := stateDB + "_history"
+
+		var err error
+		if w.IsDelete {
+			err = s.deleteDocument(stateDB, documentID(w.Key))
+		} else {
+			err = s.putDocument(stateDB, documentID(w.Key), stateDocument(w.Value))
+		}
+		if err != nil {
+			return err
+		}
+
+		history := map[string]interface{}{
+			"key":              w.Key,
+			"is_delete":        w.IsDelete,
+			"timestamp":        tx.Timestamp,
+			"blocknumber":      tx.BlockNumber,
+			"transactionindex": tx.Index,
+			"sequence":         i,
+			"txid":             tx.TxID,
+		}
+		if !w.IsDelete {
+			history["value"] = jsonValue(w.Value)
+		}
+		// the ID of a history document is ordered by block, transaction and write
+		err = s.putDocument(historyDB, fmt.Sprintf("%020d_%06d_%06d", tx.BlockNumber, tx.Index, i), history)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *couchDBSink) close() error {
+	return nil
+}
+
+// stateDocument returns the document of a value of the world state. A JSON object is stored
+// as the document, any other value in the value field.
+func stateDocument(value []byte) map[string]interface{} {
+	document := make(map[string]interface{})
+	if json.Unmarshal(value, &document) == nil && document != nil {
+		return document
+	}
+
+	return map[string]interface{}{"value": jsonValue(value)}
+}
+
+// databaseName returns a valid CouchDB database name joining parts with underscores. A database
+// name is lower case, starts with a letter, and has no other characters than letters, digits
+// and _$()+-/.
+func databaseName(parts ...string) string {
+	name := strings.ToLower(strings.Join(parts, "_"))
+	name = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || strings.ContainsRune("_$()+-/", r) {
+			return r
+		}
+		return '_'
+	}, name)
+	if name == "" || name[0] < 'a' || name[0] > 'z' {
+		name = "db" + name
+	}
+
+	return name
+}
+
+// putDocument creates or replaces the document with the given ID in database
+func (s *couchDBSink) putDocument(database string, id string, document map[string]interface{}) error {
+	err := s.createDatabase(database)
+	if err != nil {
+		return err
+	}
+
+	rev, err := s.revision(database, id)
+	if err != nil {
+		return err
+	}
+	if rev != "" {
+		document["_rev"] = rev
+	}
+
+	body, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	return s.do(http.MethodPut, []string{database, id}, nil, body, http.StatusCreated, http.StatusAccepted)
+}
+
+// deleteDocument deletes the document with the given ID of database, if it exists
+func (s *couchDBSink) deleteDocument(database string, id string) error {
+	err := s.createDatabase(database)
+	if err != nil {
+		return err
+	}
+
+	rev, err := s.revision(database, id)
+	if err != nil || rev == "" {
+		return err
+	}
+
+	return s.do(http.MethodDelete, []string{database, id}, url.Values{"rev": {rev}}, nil, http.StatusOK, http.StatusAccepted)
+}
+
+// createDatabase creates database unless it exists
+func (s *couchDBSink) createDatabase(database string) error {
+	if s.databases[database] {
+		return nil
+	}
+
+	err := s.do(http.MethodPut, []string{database}, nil, nil, http.StatusCreated, http.StatusAccepted, http.StatusPreconditionFailed)
+	if err != nil {
+		return err
+	}
+
+	s.databases[database] = true
+	return nil
+}
+
+// revision returns the revision of the document with the given ID of database, or an empty
+// string if there is no such document
+func (s *couchDBSink) revision(database string, id string) (string, error) {
+	response, err := s.request(http.MethodHead, []string{database, id}, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		return strings.Trim(response.Header.Get("ETag"), `"`), nil
+	case http.StatusNotFound:
+		return "", nil
+	default:
+		return "", fmt.Errorf("failed to get document %s of CouchDB database %s: %s", id, database, response.Status)
+	}
+}
+
+// documentID returns the ID of the document of key. A document ID starting with an underscore
+// is reserved by CouchDB, so the keys starting with an underscore or a tilde are prefixed with
+// a tilde.
+func documentID(key string) string {
+	if strings.HasPrefix(key, "_") || strings.HasPrefix(key, "~") {
+		return "~" + key
+	}
+	return key
+}
+
+// do sends a request, and returns an error unless the response has one of the expected statuses
+func (s *couchDBSink) do(method string, path []string, query url.Values, body []byte, expected ...int) error {
+	response, err := s.request(method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	for _, status := range expected {
+		if response.StatusCode == status {
+			_, err = io.Copy(ioutil.Discard, response.Body)
+			return err
+		}
+	}
+
+	couchErr := couchDBError{}
+	_ = json.NewDecoder(response.Body).Decode(&couchErr)
+	return fmt.Errorf("CouchDB %s /%s failed: %s: %s %s", method, strings.Join(path, "/"), response.Status, couchErr.Error, couchErr.Reason)
+}
+
+// request sends a request to the path of the server, given as unescaped segments
+func (s *couchDBSink) request(method string, path []string, query url.Values, body []byte) (*http.Response, error) {
+	u := *s.address
+	escapedPath := strings.TrimSuffix(u.EscapedPath(), "/")
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	for _, segment := range path {
+		u.Path += "/" + segment
+		escapedPath += "/" + url.PathEscape(segment)
+	}
+	u.RawPath = escapedPath
+	u.RawQuery = query.Encode()
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequest(method, u.String(), bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("CouchDB %s /%s failed: %v", method, strings.Join(path, "/"), err)
+	}
+
+	return response, nil
+}
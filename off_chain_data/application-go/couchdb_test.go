@@ -0,0 +1,224 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// couchDBStandIn is an in-memory server implementing the part of the CouchDB document API used
+// by the couchdb sink, with the same revision checks
+type couchDBStandIn struct {
+	mutex     sync.Mutex
+	databases map[string]map[string]map[string]interface{}
+	username  string
+	password  string
+}
+
+func newCouchDBStandIn() *couchDBStandIn {
+	return &couchDBStandIn{
+		databases: make(map[string]map[string]map[string]interface{}),
+		username:  "admin",
+		password:  "password",
+	}
+}
+
+func (c *couchDBStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if username, password, ok := r.BasicAuth(); !ok || username != c.username || password != c.password {
+		c.reply(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized", "reason": "Name or password is incorrect."})
+		return
+	}
+
+	path := strings.SplitN(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/", 2)
+	database := unescape(path[0])
+	if len(path) == 1 {
+		if r.Method != http.MethodPut {
+			c.reply(w, http.StatusMethodNotAllowed, nil)
+			return
+		}
+		if _, ok := c.databases[database]; ok {
+			c.reply(w, http.StatusPreconditionFailed, map[string]string{"error": "file_exists"})
+			return
+		}
+		c.databases[database] = make(map[string]map[string]interface{})
+		c.reply(w, http.StatusCreated, map[string]bool{"ok": true})
+		return
+	}
+
+	documents, ok := c.databases[database]
+	if !ok {
+		c.reply(w, http.StatusNotFound, map[string]string{"error": "not_found", "reason": "Database does not exist."})
+		return
+	}
+	id := unescape(path[1])
+	document, exists := documents[id]
+
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		if !exists {
+			c.reply(w, http.StatusNotFound, map[string]string{"error": "not_found", "reason": "missing"})
+			return
+		}
+		w.Header().Set("ETag", fmt.Sprintf("%q", document["_rev"]))
+		c.reply(w, http.StatusOK, document)
+	case http.MethodPut:
+		body, _ := ioutil.ReadAll(r.Body)
+		newDocument := make(map[string]interface{})
+		err := json.Unmarshal(body, &newDocument)
+		if err != nil {
+			c.reply(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+			return
+		}
+		if exists && newDocument["_rev"] != document["_rev"] || !exists && newDocument["_rev"] != nil {
+			c.reply(w, http.StatusConflict, map[string]string{"error": "conflict", "reason": "Document update conflict."})
+			return
+		}
+		newDocument["_id"] = id
+		newDocument["_rev"] = fmt.Sprintf("%d-rev", revision(document)+1)
+		documents[id] = newDocument
+		c.reply(w, http.StatusCreated, map[string]interface{}{"ok": true, "id": id, "rev": newDocument["_rev"]})
+	case http.MethodDelete:
+		if !exists || r.URL.Query().Get("rev") != document["_rev"] {
+			c.reply(w, http.StatusConflict, map[string]string{"error": "conflict", "reason": "Document update conflict."})
+			return
+		}
+		delete(documents, id)
+		c.reply(w, http.StatusOK, map[string]bool{"ok": true})
+	default:
+		c.reply(w, http.StatusMethodNotAllowed, nil)
+	}
+}
+
+func (c *couchDBStandIn) reply(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if body != nil {
+		_ = json.NewEncoder(w).Encode(body)
+	}
+}
+
+func revision(document map[string]interface{}) int {
+	var n int
+	if rev, ok := document["_rev"].(string); ok {
+		fmt.Sscanf(rev, "%d-", &n)
+	}
+	return n
+}
+
+func unescape(segment string) string {
+	value, err := url.PathUnescape(segment)
+	if err != nil {
+		return segment
+	}
+	return value
+}
+
+func TestCouchDBSink(t *testing.T) {
+	standIn := newCouchDBStandIn()
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	s, err := newSink(&sinkOptions{
+		kind:           sinkCouchDB,
+		channel:        "mychannel",
+		couchDBAddress: strings.Replace(server.URL, "http://", "http://admin:password@", 1),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	transactions := []*transaction{
+		{
+			BlockNumber: 5,
+			TxID:        "tx1",
+			Timestamp:   testTime,
+			Writes: []write{
+				{Namespace: "basic", Key: "asset1", Value: []byte(`{"ID":"asset1","Owner":"Tom"}`)},
+				{Namespace: "basic", Key: "asset2", Value: []byte(`{"ID":"asset2"}`)},
+				{Namespace: "basic", Key: "_config", Value: []byte("plain")},
+			},
+		},
+		{
+			BlockNumber: 6,
+			Index:       1,
+			TxID:        "tx2",
+			Timestamp:   testTime,
+			Writes: []write{
+				{Namespace: "basic", Key: "asset1", Value: []byte(`{"ID":"asset1","Owner":"Max"}`)},
+				{Namespace: "basic", Key: "asset2", IsDelete: true},
+				{Namespace: "basic", Key: "asset3", IsDelete: true},
+			},
+		},
+	}
+	for _, tx := range transactions {
+		err = s.write(tx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// a transaction written again after a restart replaces its documents
+	err = s.write(transactions[1])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	state := standIn.databases["mychannel_basic"]
+	if len(state) != 2 {
+		t.Errorf("expected 2 documents in the state database, got %v", state)
+	}
+	if state["asset1"]["Owner"] != "Max" || state["asset1"]["_rev"] != "3-rev" {
+		t.Errorf("unexpected document %v", state["asset1"])
+	}
+	if !reflect.DeepEqual(state["~_config"], map[string]interface{}{"_id": "~_config", "_rev": "1-rev", "value": "plain"}) {
+		t.Errorf("unexpected document %v", state["~_config"])
+	}
+
+	history := standIn.databases["mychannel_basic_history"]
+	if len(history) != 6 {
+		t.Errorf("expected 6 documents in the history database, got %d", len(history))
+	}
+	deleted := history["00000000000000000006_000001_000001"]
+	if deleted["key"] != "asset2" || deleted["is_delete"] != true || deleted["txid"] != "tx2" || deleted["_rev"] != "2-rev" {
+		t.Errorf("unexpected history document %v", deleted)
+	}
+	if _, ok := deleted["value"]; ok {
+		t.Errorf("unexpected value in history document %v", deleted)
+	}
+
+	standIn.password = "changed"
+	err = s.write(transactions[0])
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to get document asset1 of CouchDB database mychannel_basic: 401 Unauthorized") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = newSink(&sinkOptions{kind: sinkCouchDB, couchDBAddress: "localhost:5990"})
+	if err == nil || err.Error() != `invalid CouchDB address "localhost:5990", expected an http or https URL` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDatabaseName(t *testing.T) {
+	for parts, expected := range map[[2]string]string{
+		{"mychannel", "basic"}:       "mychannel_basic",
+		{"MyChannel", "asset.basic"}: "mychannel_asset_basic",
+		{"1channel", "basic"}:        "db1channel_basic",
+	} {
+		if name := databaseName(parts[0], parts[1]); name != expected {
+			t.Errorf("expected database name %s for %v, got %s", expected, parts, name)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+module github.com/hyperledger/fabric-samples/off_chain_data/application-go
+
+go 1.14
+
+require (
+	github.com/golang/protobuf v1.3.3
+	github.com/hyperledger/fabric-protos-go v0.0.0-20200707132912-fee30f3ccd23
+	github.com/hyperledger/fabric-samples/test-application/go v0.0.0-00010101000000-000000000000
+	github.com/hyperledger/fabric-sdk-go v1.0.0-rc1
+	github.com/mattn/go-sqlite3 v1.14.5
+)
+
+replace github.com/hyperledger/fabric-samples/test-application/go => ../../test-application/go
@@ -0,0 +1,182 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+)
+
+// checkpoint records the progress of the processor. The blocks before BlockNumber are
+// processed, and the transactions of block BlockNumber before TransactionIndex.
+type checkpoint struct {
+	BlockNumber      uint64 `json:"blockNumber"`
+	TransactionIndex int    `json:"transactionIndex"`
+}
+
+// loadCheckpoint returns the checkpoint saved in file, or the checkpoint of the first block if
+// there is no such file
+func loadCheckpoint(file string) (*checkpoint, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(file))
+	if os.IsNotExist(err) {
+		return &checkpoint{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+
+	cp := &checkpoint{}
+	err = json.Unmarshal(data, cp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %v", file, err)
+	}
+
+	return cp, nil
+}
+
+// save replaces file with the checkpoint, a checkpoint is never partially written
+func (cp *checkpoint) save(file string) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmp := file + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+	err = os.Rename(tmp, file)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+
+	return nil
+}
+
+// processor writes the transactions of the blocks of a source to a sink in block order, and
+// records its progress in a checkpoint file so that a restarted processor resumes after the
+// last written transaction
+type processor struct {
+	source         blockSource
+	sink           sink
+	checkpointFile string
+	checkpoint     *checkpoint
+}
+
+// run processes the blocks until stop is closed. The blocks committed since the checkpoint
+// are read from the source before the new blocks.
+func (p *processor) run(stop <-chan struct{}) error {
+	// the processor subscribes before catching up, so that no block is committed unnoticed
+	// between the catch up and the subscription
+	blocks, unsubscribe, err := p.source.subscribe()
+	if err != nil {
+		return err
+	}
+	defer unsubscribe()
+
+	height, err := p.source.height()
+	if err != nil {
+		return err
+	}
+	err = p.catchUp(height, stop)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case block, ok := <-blocks:
+			if !ok {
+				return errors.New("the event service closed the subscription")
+			}
+
+			blockNumber := block.GetHeader().GetNumber()
+			if blockNumber < p.checkpoint.BlockNumber {
+				continue
+			}
+			// the blocks missed by the subscription are read from the source
+			err = p.catchUp(blockNumber, stop)
+			if err != nil {
+				return err
+			}
+			if p.checkpoint.BlockNumber != blockNumber {
+				// the catch up was stopped
+				return nil
+			}
+			err = p.processBlock(block)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// catchUp processes the blocks from the checkpoint to height, excluded
+func (p *processor) catchUp(height uint64, stop <-chan struct{}) error {
+	for p.checkpoint.BlockNumber < height {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+
+		block, err := p.source.block(p.checkpoint.BlockNumber)
+		if err != nil {
+			return err
+		}
+		err = p.processBlock(block)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// processBlock writes the transactions of block which are not processed yet, and moves the
+// checkpoint to the next block
+func (p *processor) processBlock(block *common.Block) error {
+	blockNumber := block.GetHeader().GetNumber()
+	if blockNumber != p.checkpoint.BlockNumber {
+		return fmt.Errorf("expected block %d, got block %d", p.checkpoint.BlockNumber, blockNumber)
+	}
+
+	transactions, err := blockTransactions(block)
+	if err != nil {
+		return err
+	}
+
+	for _, tx := range transactions {
+		if tx.Index < p.checkpoint.TransactionIndex {
+			continue
+		}
+
+		err = p.sink.write(tx)
+		if err != nil {
+			return fmt.Errorf("failed to write transaction %s of block %d: %v", tx.TxID, blockNumber, err)
+		}
+
+		p.checkpoint.TransactionIndex = tx.Index + 1
+		err = p.checkpoint.save(p.checkpointFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Processed block %d, %d transactions with writes", blockNumber, len(transactions))
+
+	p.checkpoint = &checkpoint{BlockNumber: blockNumber + 1}
+	return p.checkpoint.save(p.checkpointFile)
+}
@@ -0,0 +1,223 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+)
+
+// fakeSource returns the blocks committed before the subscription, and delivers the blocks
+// sent to live
+type fakeSource struct {
+	blocks    map[uint64]*common.Block
+	committed uint64
+	live      chan *common.Block
+}
+
+func (s *fakeSource) height() (uint64, error) {
+	return s.committed, nil
+}
+
+func (s *fakeSource) block(blockNumber uint64) (*common.Block, error) {
+	block, ok := s.blocks[blockNumber]
+	if !ok {
+		return nil, errors.New("no such block")
+	}
+	return block, nil
+}
+
+func (s *fakeSource) subscribe() (<-chan *common.Block, func(), error) {
+	return s.live, func() {}, nil
+}
+
+// recordingSink records the IDs of the transactions, fails on the transaction failTxID, and
+// stops the processor after the transaction stopTxID
+type recordingSink struct {
+	txIDs    []string
+	failTxID string
+	stopTxID string
+	stop     chan struct{}
+}
+
+func (s *recordingSink) write(tx *transaction) error {
+	if tx.TxID == s.failTxID {
+		return errors.New("sink unavailable")
+	}
+
+	s.txIDs = append(s.txIDs, tx.TxID)
+	if tx.TxID == s.stopTxID {
+		close(s.stop)
+	}
+
+	return nil
+}
+
+func (s *recordingSink) close() error {
+	return nil
+}
+
+func assetTx(t *testing.T, txID string) blockTx {
+	return validTx(endorserTransaction(t, txID, map[string][]*kvrwset.KVWrite{
+		"basic": {{Key: txID, Value: []byte(`{"ID":"` + txID + `"}`)}},
+	}))
+}
+
+func TestProcessor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offchain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	checkpointFile := filepath.Join(dir, "checkpoint.json")
+
+	source := &fakeSource{
+		blocks: map[uint64]*common.Block{
+			0: newBlock(t, 0, validTx(envelope(t, "config", common.HeaderType_CONFIG, nil))),
+			1: newBlock(t, 1, assetTx(t, "tx1"), assetTx(t, "tx2"), assetTx(t, "tx3")),
+			2: newBlock(t, 2, assetTx(t, "tx4")),
+			3: newBlock(t, 3, assetTx(t, "tx5")),
+			4: newBlock(t, 4, assetTx(t, "tx6")),
+		},
+		committed: 2,
+		live:      make(chan *common.Block, 3),
+	}
+	// block 1 is delivered by the subscription as well as by the catch up, and block 3 is
+	// missed by the subscription
+	source.live <- source.blocks[1]
+	source.live <- source.blocks[2]
+	source.live <- source.blocks[4]
+
+	sink := &recordingSink{failTxID: "tx5", stopTxID: "tx6", stop: make(chan struct{})}
+	p := &processor{
+		source:         source,
+		sink:           sink,
+		checkpointFile: checkpointFile,
+		checkpoint:     &checkpoint{BlockNumber: 1, TransactionIndex: 1},
+	}
+
+	err = p.run(sink.stop)
+	if err == nil || err.Error() != "failed to write transaction tx5 of block 3: sink unavailable" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(sink.txIDs, []string{"tx2", "tx3", "tx4"}) {
+		t.Errorf("unexpected transactions %v", sink.txIDs)
+	}
+	cp, err := loadCheckpoint(checkpointFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *cp != (checkpoint{BlockNumber: 3}) {
+		t.Errorf("unexpected checkpoint %+v", cp)
+	}
+
+	// the restarted processor writes the transaction which failed
+	sink.failTxID = ""
+	source.committed = 5
+	p.checkpoint = cp
+	err = p.run(sink.stop)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(sink.txIDs, []string{"tx2", "tx3", "tx4", "tx5", "tx6"}) {
+		t.Errorf("unexpected transactions %v", sink.txIDs)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offchain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	checkpointFile := filepath.Join(dir, "checkpoint.json")
+
+	cp, err := loadCheckpoint(checkpointFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *cp != (checkpoint{}) {
+		t.Errorf("expected the checkpoint of the first block, got %+v", cp)
+	}
+
+	err = (&checkpoint{BlockNumber: 12, TransactionIndex: 3}).save(checkpointFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := ioutil.ReadFile(checkpointFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"blockNumber":12,"transactionIndex":3}` {
+		t.Errorf("unexpected checkpoint file %s", data)
+	}
+
+	err = ioutil.WriteFile(checkpointFile, []byte("12"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = loadCheckpoint(checkpointFile)
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to parse checkpoint") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offchain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := newSink(&sinkOptions{kind: sinkFile, channel: "mychannel", logDir: dir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = s.write(&transaction{
+		BlockNumber: 5,
+		Index:       2,
+		TxID:        "tx1",
+		Timestamp:   testTime,
+		Writes: []write{
+			{Namespace: "basic", Key: "asset1", Value: []byte(`{"ID":"asset1"}`)},
+			{Namespace: "other", Key: "key1", Value: []byte("plain")},
+			{Namespace: "basic", Key: "asset2", IsDelete: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "mychannel_basic.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"blockNumber":5,"transactionIndex":2,"txId":"tx1","timestamp":"2020-10-27T18:01:45Z","chaincodeId":"basic",` +
+		`"writes":[{"key":"asset1","isDelete":false,"value":{"ID":"asset1"}},{"key":"asset2","isDelete":true}]}` + "\n"
+	if string(data) != expected {
+		t.Errorf("expected log:\n%s\ngot:\n%s", expected, data)
+	}
+
+	data, err = ioutil.ReadFile(filepath.Join(dir, "mychannel_other.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"writes":[{"key":"key1","isDelete":false,"value":"plain"}]`) {
+		t.Errorf("unexpected log %s", data)
+	}
+
+	_, err = newSink(&sinkOptions{kind: "kafka"})
+	if err == nil || err.Error() != `unknown sink "kafka", expected file, sqlite or couchdb` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Kinds of sink
+const (
+	sinkFile    = "file"
+	sinkSQLite  = "sqlite"
+	sinkCouchDB = "couchdb"
+)
+
+// sink stores the writes of the transactions off chain. A transaction is written again when
+// the processor stops after writing it but before recording it in the checkpoint.
+type sink interface {
+	write(tx *transaction) error
+	close() error
+}
+
+// sinkOptions configure the sinks
+type sinkOptions struct {
+	kind           string
+	channel        string
+	logDir         string
+	sqliteFile     string
+	couchDBAddress string
+}
+
+func newSink(opts *sinkOptions) (sink, error) {
+	switch opts.kind {
+	case sinkFile:
+		return newFileSink(opts.logDir, opts.channel), nil
+	case sinkSQLite:
+		return newSQLiteSink(opts.sqliteFile)
+	case sinkCouchDB:
+		return newCouchDBSink(opts.couchDBAddress, opts.channel)
+	default:
+		return nil, fmt.Errorf("unknown sink %q, expected %s, %s or %s", opts.kind, sinkFile, sinkSQLite, sinkCouchDB)
+	}
+}
+
+// jsonValue returns value if it is JSON, or value as a JSON string
+func jsonValue(value []byte) json.RawMessage {
+	if json.Valid(value) {
+		return value
+	}
+
+	data, _ := json.Marshal(string(value))
+	return data
+}
+
+// fileSink appends the writes of each chaincode to the log file <channel>_<chaincode>.log of a
+// directory, as the JavaScript listener does. A transaction written again is appended again.
+type fileSink struct {
+	dir     string
+	channel string
+}
+
+// logRecord is a line of a log file, holding the writes of a transaction to a chaincode
+type logRecord struct {
+	BlockNumber      uint64     `json:"blockNumber"`
+	TransactionIndex int        `json:"transactionIndex"`
+	TxID             string     `json:"txId"`
+	Timestamp        time.Time  `json:"timestamp"`
+	ChaincodeID      string     `json:"chaincodeId"`
+	Writes           []logWrite `json:"writes"`
+}
+
+type logWrite struct {
+	Key      string          `json:"key"`
+	IsDelete bool            `json:"isDelete"`
+	Value    json.RawMessage `json:"value,omitempty"`
+}
+
+func newFileSink(dir string, channel string) *fileSink {
+	return &fileSink{dir: dir, channel: channel}
+}
+
+func (s *fileSink) write(tx *transaction) error {
+	var namespaces []string
+	records := make(map[string]*logRecord)
+	for _, w := range tx.Writes {
+		record, ok := records[w.Namespace]
+		if !ok {
+			record = &logRecord{
+				BlockNumber:      tx.BlockNumber,
+				TransactionIndex: tx.Index,
+				TxID:             tx.TxID,
+				Timestamp:        tx.Timestamp,
+				ChaincodeID:      w.Namespace,
+			}
+			records[w.Namespace] = record
+			namespaces = append(namespaces, w.Namespace)
+		}
+
+		lw := logWrite{Key: w.Key, IsDelete: w.IsDelete}
+		if !w.IsDelete {
+			lw.Value = jsonValue(w.Value)
+		}
+		record.Writes = append(record.Writes, lw)
+	}
+
+	for _, namespace := range namespaces {
+		err := s.append(namespace, records[namespace])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *fileSink) append(namespace string, record *logRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Join(s.dir, s.channel+"_"+strings.Replace(namespace, string(filepath.Separator), "_", -1)+".log")
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+
+	_, err = file.Write(append(data, '\n'))
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write log file %s: %v", name, err)
+	}
+
+	return file.Close()
+}
+
+func (s *fileSink) close() error {
+	return nil
+}
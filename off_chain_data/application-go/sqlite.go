@@ -0,0 +1,106 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	// registers the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteSchema creates the table of the latest value of the keys, which emulates the world
+// state, and the table of all the writes, which emulates the history of the keys
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS state (
+	namespace TEXT NOT NULL,
+	key TEXT NOT NULL,
+	value BLOB,
+	block_number INTEGER NOT NULL,
+	tx_id TEXT NOT NULL,
+	PRIMARY KEY (namespace, key)
+);
+CREATE TABLE IF NOT EXISTS history (
+	block_number INTEGER NOT NULL,
+	transaction_index INTEGER NOT NULL,
+	write_index INTEGER NOT NULL,
+	tx_id TEXT NOT NULL,
+	timestamp TEXT NOT NULL,
+	namespace TEXT NOT NULL,
+	key TEXT NOT NULL,
+	is_delete INTEGER NOT NULL,
+	value BLOB,
+	PRIMARY KEY (block_number, transaction_index, write_index)
+);
+`
+
+// sqliteSink stores the writes in an SQLite database. The writes of a transaction are stored
+// in a database transaction, and a transaction written again replaces the same rows.
+type sqliteSink struct {
+	db *sql.DB
+}
+
+func newSQLiteSink(file string) (*sqliteSink, error) {
+	db, err := sql.Open("sqlite3", file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database %s: %v", file, err)
+	}
+
+	_, err = db.Exec(sqliteSchema)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create the tables of SQLite database %s: %v", file, err)
+	}
+
+	return &sqliteSink{db: db}, nil
+}
+
+func (s *sqliteSink) write(tx *transaction) error {
+	dbTx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = s.writeRows(dbTx, tx)
+	if err != nil {
+		dbTx.Rollback()
+		return err
+	}
+
+	return dbTx.Commit()
+}
+
+func (s *sqliteSink) writeRows(dbTx *sql.Tx, tx *transaction) error {
+	for i, w := range tx.Writes {
+		_, err := dbTx.Exec(
+			`INSERT OR REPLACE INTO history (block_number, transaction_index, write_index, tx_id, timestamp, namespace, key, is_delete, value)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			tx.BlockNumber, tx.Index, i, tx.TxID, tx.Timestamp.UTC().Format(time.RFC3339Nano), w.Namespace, w.Key, w.IsDelete, w.Value,
+		)
+		if err != nil {
+			return err
+		}
+
+		if w.IsDelete {
+			_, err = dbTx.Exec(`DELETE FROM state WHERE namespace = ? AND key = ?`, w.Namespace, w.Key)
+		} else {
+			_, err = dbTx.Exec(
+				`INSERT OR REPLACE INTO state (namespace, key, value, block_number, tx_id) VALUES (?, ?, ?, ?, ?)`,
+				w.Namespace, w.Key, w.Value, tx.BlockNumber, tx.TxID,
+			)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sqliteSink) close() error {
+	return s.db.Close()
+}
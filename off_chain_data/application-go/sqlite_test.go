@@ -0,0 +1,107 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSQLiteSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offchain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := newSink(&sinkOptions{kind: sinkSQLite, sqliteFile: filepath.Join(dir, "offchain.db")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer s.close()
+
+	transactions := []*transaction{
+		{
+			BlockNumber: 5,
+			Index:       0,
+			TxID:        "tx1",
+			Timestamp:   testTime,
+			Writes: []write{
+				{Namespace: "basic", Key: "asset1", Value: []byte(`{"ID":"asset1","Owner":"Tom"}`)},
+				{Namespace: "basic", Key: "asset2", Value: []byte(`{"ID":"asset2"}`)},
+			},
+		},
+		{
+			BlockNumber: 6,
+			Index:       1,
+			TxID:        "tx2",
+			Timestamp:   testTime,
+			Writes: []write{
+				{Namespace: "basic", Key: "asset1", Value: []byte(`{"ID":"asset1","Owner":"Max"}`)},
+				{Namespace: "basic", Key: "asset2", IsDelete: true},
+			},
+		},
+	}
+	for _, tx := range transactions {
+		err = s.write(tx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// a transaction written again after a restart replaces its rows
+	err = s.write(transactions[1])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	db := s.(*sqliteSink).db
+	rows, err := db.Query(`SELECT namespace, key, value, block_number, tx_id FROM state ORDER BY key`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state []string
+	for rows.Next() {
+		var namespace, key, value, txID string
+		var blockNumber int
+		err = rows.Scan(&namespace, &key, &value, &blockNumber, &txID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state = append(state, namespace+" "+key+" "+value+" "+txID)
+	}
+	rows.Close()
+	if !reflect.DeepEqual(state, []string{`basic asset1 {"ID":"asset1","Owner":"Max"} tx2`}) {
+		t.Errorf("unexpected state %q", state)
+	}
+
+	rows, err = db.Query(`SELECT block_number, transaction_index, write_index, key, is_delete, timestamp FROM history ORDER BY block_number, transaction_index, write_index`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var history [][]interface{}
+	for rows.Next() {
+		var blockNumber, transactionIndex, writeIndex int
+		var key, timestamp string
+		var isDelete bool
+		err = rows.Scan(&blockNumber, &transactionIndex, &writeIndex, &key, &isDelete, &timestamp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		history = append(history, []interface{}{blockNumber, transactionIndex, writeIndex, key, isDelete, timestamp})
+	}
+	rows.Close()
+	expected := [][]interface{}{
+		{5, 0, 0, "asset1", false, "2020-10-27T18:01:45Z"},
+		{5, 0, 1, "asset2", false, "2020-10-27T18:01:45Z"},
+		{6, 1, 0, "asset1", false, "2020-10-27T18:01:45Z"},
+		{6, 1, 1, "asset2", true, "2020-10-27T18:01:45Z"},
+	}
+	if !reflect.DeepEqual(history, expected) {
+		t.Errorf("expected history %v, got %v", expected, history)
+	}
+}
@@ -15,6 +15,7 @@ popd
 # clean out any old identites in the wallets
 rm -rf wallet
 rm -rf addAssets.json mychannel_basic.log mychannel__lifecycle.log nextblock.txt
+rm -rf application-go/checkpoint.json application-go/*.log application-go/offchain.db
 
 docker stop offchaindb
 docker rm offchaindb
@@ -0,0 +1,148 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"google.golang.org/grpc/codes"
+)
+
+// Class is the class of a failed transaction
+type Class int
+
+// Classes of failed transactions
+const (
+	// Unknown is a failure which is not classified
+	Unknown Class = iota
+	// Conflict is a transaction invalidated by an MVCC read conflict or a phantom read
+	// conflict, because a key it read was written by a transaction committed before it
+	Conflict
+	// Transient is a failure to reach the peers or the orderers, before the transaction was
+	// ordered
+	Transient
+	// Timeout is a call which did not complete before its deadline. A submitted transaction
+	// may still be committed.
+	Timeout
+	// EndorsementMismatch is a transaction whose endorsing peers returned different results
+	EndorsementMismatch
+	// Rejected is a transaction whose chaincode returned an error
+	Rejected
+	// Invalid is a transaction invalidated for another reason than a read conflict, for
+	// example an endorsement policy failure
+	Invalid
+)
+
+var classNames = map[Class]string{
+	Unknown:             "unknown error",
+	Conflict:            "read conflict",
+	Transient:           "transient error",
+	Timeout:             "timeout",
+	EndorsementMismatch: "endorsement mismatch",
+	Rejected:            "rejected by the chaincode",
+	Invalid:             "invalid transaction",
+}
+
+func (c Class) String() string {
+	if name, ok := classNames[c]; ok {
+		return name
+	}
+	return classNames[Unknown]
+}
+
+// Classify returns the class of an error returned by the SubmitTransaction or the
+// EvaluateTransaction functions of a gateway contract or of a Client
+func Classify(err error) Class {
+	var clientErr *Error
+	if errors.As(err, &clientErr) {
+		return clientErr.Class
+	}
+	var deadlineErr *deadlineError
+	if errors.As(err, &deadlineErr) || errors.Is(err, context.DeadlineExceeded) {
+		return Timeout
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return Unknown
+	}
+
+	switch {
+	case s.Group == status.EventServerStatus:
+		code := peer.TxValidationCode(s.Code)
+		if code == peer.TxValidationCode_MVCC_READ_CONFLICT || code == peer.TxValidationCode_PHANTOM_READ_CONFLICT {
+			return Conflict
+		}
+		return Invalid
+	case s.Group == status.GRPCTransportStatus:
+		switch codes.Code(s.Code) {
+		case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+			return Transient
+		case codes.DeadlineExceeded:
+			return Timeout
+		}
+		return Unknown
+	case s.Group == status.EndorserServerStatus || s.Group == status.ChaincodeStatus:
+		return Rejected
+	case s.Code == status.EndorsementMismatch.ToInt32():
+		return EndorsementMismatch
+	case s.Code == status.Timeout.ToInt32():
+		return Timeout
+	case s.Code == status.ConnectionFailed.ToInt32() || s.Code == status.GenericTransient.ToInt32() || s.Code == status.NoPeersFound.ToInt32():
+		return Transient
+	case s.Code == status.MultipleErrors.ToInt32():
+		return classifyAll(s.Details)
+	}
+
+	return Unknown
+}
+
+// beforeEndorsement returns true if err is a failure to reach the endorsing peers of a
+// transaction. Such a transaction was not endorsed, so it cannot have been sent to the orderers.
+// The gRPC transport errors are not included, as they are returned by the orderers as well.
+func beforeEndorsement(err error) bool {
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+
+	switch {
+	case s.Group == status.EndorserClientStatus:
+		return s.Code == status.ConnectionFailed.ToInt32()
+	case s.Group == status.ClientStatus && s.Code == status.NoPeersFound.ToInt32():
+		return true
+	case s.Group == status.ClientStatus && s.Code == status.MultipleErrors.ToInt32():
+		for _, detail := range s.Details {
+			detailErr, ok := detail.(error)
+			if !ok || !beforeEndorsement(detailErr) {
+				return false
+			}
+		}
+		return len(s.Details) > 0
+	}
+
+	return false
+}
+
+// classifyAll returns the class of the errors returned by several peers, which is Transient
+// when they are all transient, and otherwise the class of the first error which is not
+func classifyAll(details []interface{}) Class {
+	class := Unknown
+	for _, detail := range details {
+		err, ok := detail.(error)
+		if !ok {
+			return Unknown
+		}
+		class = Classify(err)
+		if class != Transient {
+			return class
+		}
+	}
+
+	return class
+}
@@ -0,0 +1,223 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package client calls the transaction functions of a gateway contract for the Go gateway
+// applications, with a deadline for each call. The submitted transactions invalidated by a read
+// conflict or which fail to reach the endorsing peers, and the evaluations which fail to reach
+// the network, are retried with a jittered exponential backoff until the attempts or the time
+// budget are exhausted.
+package client
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+)
+
+// Contract is the contract whose transaction functions are called, implemented by
+// gateway.Contract
+type Contract interface {
+	EvaluateTransaction(name string, args ...string) ([]byte, error)
+	SubmitTransaction(name string, args ...string) ([]byte, error)
+}
+
+// Options set the deadline of the calls and their retries
+type Options struct {
+	// Timeout is the deadline of each call, or zero for no deadline
+	Timeout time.Duration
+	// Attempts is the maximum number of calls of a transaction function, including the first one
+	Attempts int
+	// Budget is the maximum time spent calling a transaction function, including the retries, or
+	// zero for no limit
+	Budget time.Duration
+	// MinBackoff and MaxBackoff bound the backoff before a retry, which doubles with each attempt
+	// and is chosen at random up to that bound
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultOptions returns the options retrying a call up to 5 times within a minute, with a
+// deadline of 30 seconds for each call
+func DefaultOptions() Options {
+	return Options{
+		Timeout:    30 * time.Second,
+		Attempts:   5,
+		Budget:     time.Minute,
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: 5 * time.Second,
+	}
+}
+
+// Flags registers the flags setting the deadline and the retries on flags, and returns the
+// options they set
+func Flags(flags *flag.FlagSet, defaults Options) *Options {
+	opts := defaults
+
+	flags.DurationVar(&opts.Timeout, "timeout", opts.Timeout, "deadline of each transaction call, 0 for none")
+	flags.IntVar(&opts.Attempts, "attempts", opts.Attempts, "maximum number of calls of a transaction, including the retries")
+	flags.DurationVar(&opts.Budget, "retry-budget", opts.Budget, "maximum time spent calling a transaction, including the retries, 0 for no limit")
+
+	return &opts
+}
+
+// WithTimeout returns the option connecting a gateway with the timeout of the options, so that
+// the gateway abandons the calls at their deadline as well
+func (o *Options) WithTimeout() gateway.Option {
+	if o.Timeout <= 0 {
+		return func(*gateway.Gateway) error {
+			return nil
+		}
+	}
+	return gateway.WithTimeout(o.Timeout)
+}
+
+// Error is the error of a transaction function call which failed, after its retries
+type Error struct {
+	// Name is the name of the transaction function
+	Name string
+	// Class is the class of the last failure
+	Class Class
+	// Attempts is the number of calls
+	Attempts int
+	// Err is the error of the last call
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Attempts == 1 {
+		return fmt.Sprintf("%s: %v", e.Class, e.Err)
+	}
+	return fmt.Sprintf("%s after %d attempts: %v", e.Class, e.Attempts, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// deadlineError is returned by a call which did not complete before its deadline
+type deadlineError struct {
+	timeout time.Duration
+}
+
+func (e *deadlineError) Error() string {
+	return fmt.Sprintf("no response within %v", e.timeout)
+}
+
+// Client calls the transaction functions of a contract. It implements Contract.
+type Client struct {
+	contract Contract
+	opts     Options
+
+	// sleep waits for the backoff before a retry
+	sleep func(time.Duration)
+
+	mutex  sync.Mutex
+	random *rand.Rand
+}
+
+// New returns a client calling the transaction functions of contract
+func New(contract Contract, opts Options) *Client {
+	return &Client{
+		contract: contract,
+		opts:     opts,
+		sleep:    time.Sleep,
+		random:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// SubmitTransaction submits a transaction, and retries it when it is invalidated by a read
+// conflict or it fails to reach the endorsing peers. Each retry is a new transaction, so only
+// the failures after which the transaction cannot be committed are retried: a transaction
+// which times out, or fails to reach the orderers, may still be committed.
+func (c *Client) SubmitTransaction(name string, args ...string) ([]byte, error) {
+	return c.call(name, func(class Class, err error) bool {
+		return class == Conflict || (class == Transient && beforeEndorsement(err))
+	}, func() ([]byte, error) {
+		return c.contract.SubmitTransaction(name, args...)
+	})
+}
+
+// EvaluateTransaction evaluates a transaction, and retries it when it fails to reach the network
+// or it times out
+func (c *Client) EvaluateTransaction(name string, args ...string) ([]byte, error) {
+	return c.call(name, func(class Class, _ error) bool {
+		return class == Transient || class == Timeout
+	}, func() ([]byte, error) {
+		return c.contract.EvaluateTransaction(name, args...)
+	})
+}
+
+// call calls f until it succeeds, it fails with an error whose class is not retried, or the
+// attempts or the budget are exhausted
+func (c *Client) call(name string, retried func(Class, error) bool, f func() ([]byte, error)) ([]byte, error) {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		result, err := c.callWithDeadline(f)
+		if err == nil {
+			return result, nil
+		}
+
+		class := Classify(err)
+		if !retried(class, err) || attempt >= c.opts.Attempts {
+			return nil, &Error{Name: name, Class: class, Attempts: attempt, Err: err}
+		}
+
+		backoff := c.backoff(attempt)
+		if c.opts.Budget > 0 && time.Since(start)+backoff >= c.opts.Budget {
+			return nil, &Error{Name: name, Class: class, Attempts: attempt, Err: err}
+		}
+		c.sleep(backoff)
+	}
+}
+
+// callWithDeadline calls f, and returns a deadlineError if it does not return before the
+// timeout. The call itself is abandoned, and ends with the timeout of the gateway.
+func (c *Client) callWithDeadline(f func() ([]byte, error)) ([]byte, error) {
+	if c.opts.Timeout <= 0 {
+		return f()
+	}
+
+	type response struct {
+		result []byte
+		err    error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		result, err := f()
+		responses <- response{result, err}
+	}()
+
+	timer := time.NewTimer(c.opts.Timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-responses:
+		return r.result, r.err
+	case <-timer.C:
+		return nil, &deadlineError{timeout: c.opts.Timeout}
+	}
+}
+
+// backoff returns a random backoff before the retry following attempt, between MinBackoff and
+// MinBackoff doubled for each previous attempt, up to MaxBackoff
+func (c *Client) backoff(attempt int) time.Duration {
+	bound := c.opts.MinBackoff
+	for i := 1; i < attempt && bound < c.opts.MaxBackoff; i++ {
+		bound *= 2
+	}
+	if bound > c.opts.MaxBackoff {
+		bound = c.opts.MaxBackoff
+	}
+	if bound <= c.opts.MinBackoff {
+		return c.opts.MinBackoff
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.opts.MinBackoff + time.Duration(c.random.Int63n(int64(bound-c.opts.MinBackoff)))
+}
@@ -0,0 +1,182 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+import (
+	"errors"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/multi"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+var (
+	mvccConflict = pkgerrors.Wrap(status.New(status.EventServerStatus, int32(peer.TxValidationCode_MVCC_READ_CONFLICT), "received invalid transaction", nil), "Failed to submit")
+	unavailable  = pkgerrors.Wrap(status.NewFromGRPCStatus(grpcstatus.New(codes.Unavailable, "connection refused")), "Failed to submit")
+	commitWait   = pkgerrors.Wrap(status.New(status.ClientStatus, status.Timeout.ToInt32(), "Execute didn't receive block event", nil), "Failed to submit")
+	noEndorser   = pkgerrors.Wrap(status.New(status.EndorserClientStatus, status.ConnectionFailed.ToInt32(), "connection failed", nil), "Failed to submit")
+)
+
+// fakeContract returns the errors in turn, and then the result
+type fakeContract struct {
+	errs  []error
+	delay time.Duration
+	calls int
+}
+
+func (c *fakeContract) EvaluateTransaction(name string, args ...string) ([]byte, error) {
+	return c.call()
+}
+
+func (c *fakeContract) SubmitTransaction(name string, args ...string) ([]byte, error) {
+	return c.call()
+}
+
+func (c *fakeContract) call() ([]byte, error) {
+	c.calls++
+	time.Sleep(c.delay)
+	if c.calls <= len(c.errs) {
+		return nil, c.errs[c.calls-1]
+	}
+	return []byte("result"), nil
+}
+
+// newTestClient returns a client recording its backoffs instead of sleeping
+func newTestClient(contract Contract, opts Options, backoffs *[]time.Duration) *Client {
+	c := New(contract, opts)
+	c.sleep = func(d time.Duration) {
+		*backoffs = append(*backoffs, d)
+	}
+	return c
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected Class
+	}{
+		{mvccConflict, Conflict},
+		{status.New(status.EventServerStatus, int32(peer.TxValidationCode_PHANTOM_READ_CONFLICT), "received invalid transaction", nil), Conflict},
+		{status.New(status.EventServerStatus, int32(peer.TxValidationCode_ENDORSEMENT_POLICY_FAILURE), "received invalid transaction", nil), Invalid},
+		{unavailable, Transient},
+		{status.New(status.EndorserClientStatus, status.ConnectionFailed.ToInt32(), "connection failed", nil), Transient},
+		{status.NewFromGRPCStatus(grpcstatus.New(codes.DeadlineExceeded, "deadline exceeded")), Timeout},
+		{commitWait, Timeout},
+		{&deadlineError{timeout: time.Second}, Timeout},
+		{pkgerrors.Wrap(status.New(status.EndorserClientStatus, status.EndorsementMismatch.ToInt32(), "ProposalResponsePayloads do not match", nil), "Failed to submit"), EndorsementMismatch},
+		{status.New(status.EndorserServerStatus, 500, "asset asset1 already exists", nil), Rejected},
+		{status.NewFromExtractedChaincodeError(500, "asset asset1 already exists"), Rejected},
+		{multi.New(unavailable, status.New(status.EndorserServerStatus, 500, "asset asset1 already exists", nil)), Rejected},
+		{multi.New(unavailable, status.New(status.EndorserClientStatus, status.ConnectionFailed.ToInt32(), "connection failed", nil)), Transient},
+		{errors.New("failed to get network"), Unknown},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.expected, Classify(test.err), test.err.Error())
+	}
+}
+
+func TestSubmitRetries(t *testing.T) {
+	opts := Options{Attempts: 5, MinBackoff: 10 * time.Millisecond, MaxBackoff: 30 * time.Millisecond}
+
+	var backoffs []time.Duration
+	contract := &fakeContract{errs: []error{mvccConflict, noEndorser, mvccConflict, mvccConflict}}
+	result, err := newTestClient(contract, opts, &backoffs).SubmitTransaction("update")
+	require.NoError(t, err)
+	require.Equal(t, "result", string(result))
+	require.Equal(t, 5, contract.calls)
+	require.Len(t, backoffs, 4)
+	for i, bound := range []time.Duration{10, 20, 30, 30} {
+		require.True(t, backoffs[i] >= opts.MinBackoff && backoffs[i] <= bound*time.Millisecond, "backoff %d is %v", i, backoffs[i])
+	}
+
+	backoffs = nil
+	contract = &fakeContract{errs: []error{mvccConflict, mvccConflict, mvccConflict, mvccConflict, mvccConflict}}
+	_, err = newTestClient(contract, opts, &backoffs).SubmitTransaction("update")
+	require.Equal(t, 5, contract.calls)
+	var clientErr *Error
+	require.True(t, errors.As(err, &clientErr))
+	require.Equal(t, &Error{Name: "update", Class: Conflict, Attempts: 5, Err: mvccConflict}, clientErr)
+	require.EqualError(t, err, "read conflict after 5 attempts: "+mvccConflict.Error())
+
+	// a commit timeout is not retried, as the transaction may be committed
+	contract = &fakeContract{errs: []error{commitWait}}
+	_, err = newTestClient(contract, opts, &backoffs).SubmitTransaction("update")
+	require.Equal(t, 1, contract.calls)
+	require.EqualError(t, err, "timeout: "+commitWait.Error())
+
+	// a transport error is not retried, as the orderers may have received the transaction
+	contract = &fakeContract{errs: []error{unavailable}}
+	_, err = newTestClient(contract, opts, &backoffs).SubmitTransaction("update")
+	require.Equal(t, 1, contract.calls)
+	require.Equal(t, Transient, Classify(err))
+
+	ordererDown := status.New(status.OrdererClientStatus, status.ConnectionFailed.ToInt32(), "connection failed", nil)
+	contract = &fakeContract{errs: []error{ordererDown}}
+	_, err = newTestClient(contract, opts, &backoffs).SubmitTransaction("update")
+	require.Equal(t, 1, contract.calls)
+
+	noPeers := multi.New(noEndorser, status.New(status.ClientStatus, status.NoPeersFound.ToInt32(), "targets were not provided", nil))
+	contract = &fakeContract{errs: []error{noPeers}}
+	_, err = newTestClient(contract, opts, &backoffs).SubmitTransaction("update")
+	require.NoError(t, err)
+	require.Equal(t, 2, contract.calls)
+
+	rejected := status.New(status.EndorserServerStatus, 500, "asset asset1 already exists", nil)
+	contract = &fakeContract{errs: []error{rejected}}
+	_, err = newTestClient(contract, opts, &backoffs).SubmitTransaction("create")
+	require.Equal(t, 1, contract.calls)
+	require.Equal(t, Rejected, Classify(err))
+}
+
+func TestEvaluateRetries(t *testing.T) {
+	var backoffs []time.Duration
+	contract := &fakeContract{errs: []error{unavailable, &deadlineError{timeout: time.Second}}}
+	result, err := newTestClient(contract, Options{Attempts: 3}, &backoffs).EvaluateTransaction("get")
+	require.NoError(t, err)
+	require.Equal(t, "result", string(result))
+	require.Equal(t, 3, contract.calls)
+
+	contract = &fakeContract{errs: []error{mvccConflict}}
+	_, err = newTestClient(contract, Options{Attempts: 3}, &backoffs).EvaluateTransaction("get")
+	require.Equal(t, 1, contract.calls)
+	require.Error(t, err)
+}
+
+func TestBudget(t *testing.T) {
+	var backoffs []time.Duration
+	contract := &fakeContract{errs: []error{noEndorser, noEndorser, noEndorser}, delay: 20 * time.Millisecond}
+	opts := Options{Attempts: 10, Budget: 50 * time.Millisecond, MinBackoff: 10 * time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	_, err := newTestClient(contract, opts, &backoffs).SubmitTransaction("update")
+	require.EqualError(t, err, "transient error after 2 attempts: "+noEndorser.Error())
+	require.Equal(t, []time.Duration{10 * time.Millisecond}, backoffs)
+}
+
+func TestTimeout(t *testing.T) {
+	var backoffs []time.Duration
+	contract := &fakeContract{delay: time.Second}
+	start := time.Now()
+	_, err := newTestClient(contract, Options{Timeout: 20 * time.Millisecond, Attempts: 3}, &backoffs).SubmitTransaction("update")
+	require.True(t, time.Since(start) < time.Second)
+	require.EqualError(t, err, "timeout: no response within 20ms")
+	require.Equal(t, Timeout, Classify(err))
+}
+
+func TestFlags(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	opts := Flags(flags, DefaultOptions())
+	require.NoError(t, flags.Parse([]string{"-timeout", "5s", "-attempts", "2"}))
+
+	expected := DefaultOptions()
+	expected.Timeout = 5 * time.Second
+	expected.Attempts = 2
+	require.Equal(t, expected, *opts)
+}
@@ -0,0 +1,336 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package identity loads the X.509 identity of the Go gateway applications, from an MSP
+// directory, a pair of PEM files, a certificate and a PKCS#8 key, or an existing wallet.
+package identity
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+)
+
+// Sources of an identity
+const (
+	// SourceMSP loads the certificate in the signcerts directory of an MSP directory, and
+	// the matching private key in its keystore directory
+	SourceMSP = "msp"
+	// SourcePEM loads a PEM certificate and a PEM private key
+	SourcePEM = "pem"
+	// SourcePKCS8 loads a PEM certificate and a PKCS#8 private key, in DER or PEM format
+	SourcePKCS8 = "pkcs8"
+	// SourceWallet loads an identity stored in a file system wallet
+	SourceWallet = "wallet"
+)
+
+// Options select the identity of an application
+type Options struct {
+	// Source is SourceMSP, SourcePEM, SourcePKCS8 or SourceWallet
+	Source string
+	// MSPID is the MSP of the identity, unless it is loaded from a wallet
+	MSPID string
+	// MSPDir is the MSP directory of SourceMSP
+	MSPDir string
+	// Cert and Key are the certificate and private key files of SourcePEM and SourcePKCS8
+	Cert string
+	Key  string
+	// Wallet is the wallet directory of SourceWallet
+	Wallet string
+	// Label is the label of the identity in the wallet
+	Label string
+}
+
+// TestNetworkUser returns the options loading the identity of User1 of Org1 from the MSP
+// directory of the test network, at the given path relative to the root of fabric-samples
+func TestNetworkUser(root string) Options {
+	return Options{
+		Source: SourceMSP,
+		MSPID:  "Org1MSP",
+		MSPDir: filepath.Join(
+			root,
+			"test-network",
+			"organizations",
+			"peerOrganizations",
+			"org1.example.com",
+			"users",
+			"User1@org1.example.com",
+			"msp",
+		),
+		Wallet: "wallet",
+		Label:  "appUser",
+	}
+}
+
+// Flags registers the flags selecting the identity on flags, and returns the options they set.
+// The defaults are overridden by the IDENTITY_ environment variables, which are overridden by
+// the flags.
+func Flags(flags *flag.FlagSet, defaults Options) *Options {
+	opts := defaults
+	opts.applyEnv(os.LookupEnv)
+
+	flags.StringVar(&opts.Source, "identity-source", opts.Source, "source of the identity, msp, pem, pkcs8 or wallet (IDENTITY_SOURCE)")
+	flags.StringVar(&opts.MSPID, "msp-id", opts.MSPID, "MSP ID of the identity (IDENTITY_MSP_ID)")
+	flags.StringVar(&opts.MSPDir, "msp-dir", opts.MSPDir, "MSP directory of the identity (IDENTITY_MSP_DIR)")
+	flags.StringVar(&opts.Cert, "cert", opts.Cert, "certificate file of the identity (IDENTITY_CERT)")
+	flags.StringVar(&opts.Key, "key", opts.Key, "private key file of the identity (IDENTITY_KEY)")
+	flags.StringVar(&opts.Wallet, "wallet", opts.Wallet, "wallet directory (IDENTITY_WALLET)")
+	flags.StringVar(&opts.Label, "identity", opts.Label, "label of the identity in the wallet (IDENTITY_LABEL)")
+
+	return &opts
+}
+
+func (o *Options) applyEnv(lookupEnv func(string) (string, bool)) {
+	for name, field := range map[string]*string{
+		"IDENTITY_SOURCE":  &o.Source,
+		"IDENTITY_MSP_ID":  &o.MSPID,
+		"IDENTITY_MSP_DIR": &o.MSPDir,
+		"IDENTITY_CERT":    &o.Cert,
+		"IDENTITY_KEY":     &o.Key,
+		"IDENTITY_WALLET":  &o.Wallet,
+		"IDENTITY_LABEL":   &o.Label,
+	} {
+		if value, ok := lookupEnv(name); ok {
+			*field = value
+		}
+	}
+}
+
+// WithIdentity returns the option connecting a gateway with the identity. An identity which is
+// not loaded from a wallet is put in an in-memory wallet.
+func (o *Options) WithIdentity() (gateway.IdentityOption, error) {
+	if o.Source == SourceWallet {
+		wallet, err := o.fileSystemWallet()
+		if err != nil {
+			return nil, err
+		}
+		return gateway.WithIdentity(wallet, o.Label), nil
+	}
+
+	identity, err := o.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	wallet := gateway.NewInMemoryWallet()
+	err = wallet.Put(o.Label, identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to put identity in wallet: %v", err)
+	}
+
+	return gateway.WithIdentity(wallet, o.Label), nil
+}
+
+// Load loads the identity from its source
+func (o *Options) Load() (*gateway.X509Identity, error) {
+	switch o.Source {
+	case SourceMSP:
+		return loadMSP(o.MSPID, o.MSPDir)
+	case SourcePEM, SourcePKCS8:
+		if o.Cert == "" || o.Key == "" {
+			return nil, fmt.Errorf("the certificate and the private key files are required with the %s identity source", o.Source)
+		}
+		return loadFiles(o.MSPID, o.Cert, o.Key, o.Source == SourcePKCS8)
+	case SourceWallet:
+		wallet, err := o.fileSystemWallet()
+		if err != nil {
+			return nil, err
+		}
+		identity, err := wallet.Get(o.Label)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get identity %s from wallet %s: %v", o.Label, o.Wallet, err)
+		}
+		x509Identity, ok := identity.(*gateway.X509Identity)
+		if !ok {
+			return nil, fmt.Errorf("the identity %s of wallet %s is not an X.509 identity", o.Label, o.Wallet)
+		}
+		return x509Identity, nil
+	default:
+		return nil, fmt.Errorf("unknown identity source %q, expected %s, %s, %s or %s", o.Source, SourceMSP, SourcePEM, SourcePKCS8, SourceWallet)
+	}
+}
+
+func (o *Options) fileSystemWallet() (*gateway.Wallet, error) {
+	wallet, err := gateway.NewFileSystemWallet(o.Wallet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open wallet %s: %v", o.Wallet, err)
+	}
+	if !wallet.Exists(o.Label) {
+		return nil, fmt.Errorf("the wallet %s has no identity %s", o.Wallet, o.Label)
+	}
+
+	return wallet, nil
+}
+
+// loadMSP loads the certificate of the signcerts directory of mspDir, and the private key of
+// its keystore directory matching the certificate
+func loadMSP(mspID, mspDir string) (*gateway.X509Identity, error) {
+	if mspDir == "" {
+		return nil, fmt.Errorf("the MSP directory is required with the %s identity source", SourceMSP)
+	}
+
+	signcerts := filepath.Join(mspDir, "signcerts")
+	certFiles, err := listFiles(signcerts)
+	if err != nil {
+		return nil, err
+	}
+	if len(certFiles) != 1 {
+		return nil, fmt.Errorf("the signcerts directory %s must contain one certificate, found %d", signcerts, len(certFiles))
+	}
+	certPEM, cert, err := readCertificate(certFiles[0])
+	if err != nil {
+		return nil, err
+	}
+
+	keystore := filepath.Join(mspDir, "keystore")
+	keyFiles, err := listFiles(keystore)
+	if err != nil {
+		return nil, err
+	}
+	if len(keyFiles) == 0 {
+		return nil, fmt.Errorf("the keystore directory %s contains no private key", keystore)
+	}
+
+	// a keystore can keep the keys of previous certificates, the key of the certificate is used
+	var mismatched []string
+	for _, keyFile := range keyFiles {
+		keyPEM, key, err := readPrivateKey(keyFile, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load keystore %s: %v", keystore, err)
+		}
+		if matches(cert, key) {
+			return newIdentity(mspID, certPEM, keyPEM)
+		}
+		mismatched = append(mismatched, filepath.Base(keyFile))
+	}
+
+	return nil, fmt.Errorf("none of the private keys in keystore %s matches the certificate %s: %s", keystore, certFiles[0], strings.Join(mismatched, ", "))
+}
+
+// loadFiles loads the certificate of certFile, and the private key of keyFile
+func loadFiles(mspID, certFile, keyFile string, pkcs8 bool) (*gateway.X509Identity, error) {
+	certPEM, cert, err := readCertificate(certFile)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, key, err := readPrivateKey(keyFile, pkcs8)
+	if err != nil {
+		return nil, err
+	}
+	if !matches(cert, key) {
+		return nil, fmt.Errorf("the private key %s does not match the certificate %s", keyFile, certFile)
+	}
+
+	return newIdentity(mspID, certPEM, keyPEM)
+}
+
+func newIdentity(mspID string, certPEM, keyPEM []byte) (*gateway.X509Identity, error) {
+	if mspID == "" {
+		return nil, errors.New("the MSP ID of the identity is required")
+	}
+
+	return gateway.NewX509Identity(mspID, string(certPEM), string(keyPEM)), nil
+}
+
+// listFiles returns the files of dir
+func listFiles(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %v", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	return files, nil
+}
+
+// readCertificate returns the PEM certificate of file, and the parsed certificate
+func readCertificate(file string) ([]byte, *x509.Certificate, error) {
+	certPEM, err := ioutil.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read certificate: %v", err)
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, nil, fmt.Errorf("the file %s is not a PEM certificate", file)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse certificate %s: %v", file, err)
+	}
+
+	return certPEM, cert, nil
+}
+
+// readPrivateKey returns the PEM private key of file, and the parsed key. A PKCS#8 key can be
+// DER encoded, it is then converted to PEM.
+func readPrivateKey(file string, pkcs8 bool) ([]byte, crypto.Signer, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read private key: %v", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		if !pkcs8 {
+			return nil, nil, fmt.Errorf("the file %s is not a PEM private key", file)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: data}
+	}
+	if pkcs8 && block.Type != "PRIVATE KEY" {
+		return nil, nil, fmt.Errorf("the file %s is not a PKCS#8 private key", file)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, nil, fmt.Errorf("the file %s is not a private key, found a %s", file, block.Type)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse private key %s: %v", file, err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported private key type in %s", file)
+	}
+
+	return pem.EncodeToMemory(block), signer, nil
+}
+
+// matches returns true if key is the private key of the public key of cert
+func matches(cert *x509.Certificate, key crypto.Signer) bool {
+	certPublicKey, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return false
+	}
+	keyPublicKey, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(certPublicKey, keyPublicKey)
+}
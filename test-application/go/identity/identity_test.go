@@ -0,0 +1,187 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package identity
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMSP(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	certPEM, keyDER := newCredentials(t, "User1")
+	_, otherKeyDER := newCredentials(t, "User2")
+	writeFile(t, dir, "msp/signcerts/cert.pem", certPEM)
+	writeFile(t, dir, "msp/keystore/a_sk", pemKey(otherKeyDER))
+	writeFile(t, dir, "msp/keystore/b_sk", pemKey(keyDER))
+
+	opts := &Options{Source: SourceMSP, MSPID: "Org1MSP", MSPDir: filepath.Join(dir, "msp")}
+	identity, err := opts.Load()
+	require.NoError(t, err)
+	require.Equal(t, "Org1MSP", identity.MspID)
+	require.Equal(t, string(certPEM), identity.Certificate())
+	require.Equal(t, string(pemKey(keyDER)), identity.Key())
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "msp/keystore/b_sk")))
+	_, err = opts.Load()
+	require.EqualError(t, err, "none of the private keys in keystore "+filepath.Join(dir, "msp/keystore")+
+		" matches the certificate "+filepath.Join(dir, "msp/signcerts/cert.pem")+": a_sk")
+
+	writeFile(t, dir, "msp/keystore/c_sk", []byte("not a key"))
+	_, err = opts.Load()
+	require.EqualError(t, err, "failed to load keystore "+filepath.Join(dir, "msp/keystore")+
+		": the file "+filepath.Join(dir, "msp/keystore/c_sk")+" is not a PEM private key")
+
+	writeFile(t, dir, "msp/signcerts/cert2.pem", certPEM)
+	_, err = opts.Load()
+	require.EqualError(t, err, "the signcerts directory "+filepath.Join(dir, "msp/signcerts")+" must contain one certificate, found 2")
+
+	opts.MSPID = ""
+	require.NoError(t, os.Remove(filepath.Join(dir, "msp/signcerts/cert2.pem")))
+	require.NoError(t, os.Remove(filepath.Join(dir, "msp/keystore/c_sk")))
+	writeFile(t, dir, "msp/keystore/b_sk", pemKey(keyDER))
+	_, err = opts.Load()
+	require.EqualError(t, err, "the MSP ID of the identity is required")
+}
+
+func TestLoadFiles(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	certPEM, keyDER := newCredentials(t, "User1")
+	_, otherKeyDER := newCredentials(t, "User2")
+	cert := writeFile(t, dir, "cert.pem", certPEM)
+	keyPEM := writeFile(t, dir, "key.pem", pemKey(keyDER))
+	keyDERFile := writeFile(t, dir, "key.der", keyDER)
+	otherKey := writeFile(t, dir, "other.pem", pemKey(otherKeyDER))
+
+	identity, err := (&Options{Source: SourcePEM, MSPID: "Org1MSP", Cert: cert, Key: keyPEM}).Load()
+	require.NoError(t, err)
+	require.Equal(t, string(pemKey(keyDER)), identity.Key())
+
+	identity, err = (&Options{Source: SourcePKCS8, MSPID: "Org1MSP", Cert: cert, Key: keyDERFile}).Load()
+	require.NoError(t, err)
+	require.Equal(t, string(pemKey(keyDER)), identity.Key())
+
+	_, err = (&Options{Source: SourcePEM, MSPID: "Org1MSP", Cert: cert, Key: keyDERFile}).Load()
+	require.EqualError(t, err, "the file "+keyDERFile+" is not a PEM private key")
+
+	_, err = (&Options{Source: SourcePEM, MSPID: "Org1MSP", Cert: cert, Key: otherKey}).Load()
+	require.EqualError(t, err, "the private key "+otherKey+" does not match the certificate "+cert)
+
+	_, err = (&Options{Source: SourcePEM, MSPID: "Org1MSP", Cert: keyPEM, Key: keyPEM}).Load()
+	require.EqualError(t, err, "the file "+keyPEM+" is not a PEM certificate")
+
+	_, err = (&Options{Source: SourcePKCS8, MSPID: "Org1MSP", Cert: cert}).Load()
+	require.EqualError(t, err, "the certificate and the private key files are required with the pkcs8 identity source")
+
+	_, err = (&Options{Source: "hsm"}).Load()
+	require.EqualError(t, err, `unknown identity source "hsm", expected msp, pem, pkcs8 or wallet`)
+}
+
+func TestLoadWallet(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	certPEM, keyDER := newCredentials(t, "User1")
+	wallet, err := gateway.NewFileSystemWallet(dir)
+	require.NoError(t, err)
+	require.NoError(t, wallet.Put("appUser", gateway.NewX509Identity("Org2MSP", string(certPEM), string(pemKey(keyDER)))))
+
+	opts := &Options{Source: SourceWallet, Wallet: dir, Label: "appUser"}
+	identity, err := opts.Load()
+	require.NoError(t, err)
+	require.Equal(t, "Org2MSP", identity.MspID)
+	_, err = opts.WithIdentity()
+	require.NoError(t, err)
+
+	opts.Label = "admin"
+	_, err = opts.Load()
+	require.EqualError(t, err, "the wallet "+dir+" has no identity admin")
+	_, err = opts.WithIdentity()
+	require.EqualError(t, err, "the wallet "+dir+" has no identity admin")
+}
+
+func TestFlags(t *testing.T) {
+	defaults := TestNetworkUser("..")
+	require.Equal(t, filepath.Join("..", "test-network/organizations/peerOrganizations/org1.example.com/users/User1@org1.example.com/msp"), defaults.MSPDir)
+
+	opts := defaults
+	opts.applyEnv(func(name string) (string, bool) {
+		if name == "IDENTITY_SOURCE" {
+			return SourcePEM, true
+		}
+		return "", false
+	})
+	require.Equal(t, SourcePEM, opts.Source)
+	require.Equal(t, "Org1MSP", opts.MSPID)
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	parsed := Flags(flags, defaults)
+	require.NoError(t, flags.Parse([]string{"-identity-source", "pkcs8", "-cert", "cert.pem", "-key", "key.der", "-msp-id", "Org2MSP"}))
+	require.Equal(t, &Options{
+		Source: SourcePKCS8,
+		MSPID:  "Org2MSP",
+		MSPDir: defaults.MSPDir,
+		Cert:   "cert.pem",
+		Key:    "key.der",
+		Wallet: "wallet",
+		Label:  "appUser",
+	}, parsed)
+}
+
+// newCredentials returns a new self-signed PEM certificate for commonName, and its DER PKCS#8 key
+func newCredentials(t *testing.T, commonName string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), keyDER
+}
+
+func pemKey(keyDER []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "identity")
+	require.NoError(t, err)
+
+	return dir
+}
+
+func writeFile(t *testing.T, dir, name string, content []byte) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+	require.NoError(t, ioutil.WriteFile(path, content, 0600))
+
+	return path
+}